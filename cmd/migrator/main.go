@@ -32,12 +32,12 @@ func main() {
 
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
-		fmt.Printf("failed to open connection with db: %s", err.Error())
+		panic(fmt.Errorf("failed to open connection with db: %w", err))
 	}
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		fmt.Printf("failed to get postgres driver with instance: %s", err.Error())
+		panic(fmt.Errorf("failed to get postgres driver with instance: %w", err))
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -8,7 +9,11 @@ import (
 
 	"github.com/finaptica/sso/internal/app"
 	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/lib/logger/handlers/slogctx"
 	"github.com/finaptica/sso/internal/lib/logger/handlers/slogpretty"
+	"github.com/finaptica/sso/internal/lib/logger/handlers/slogredact"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
 )
 
 const (
@@ -18,8 +23,17 @@ const (
 
 func main() {
 	cfg := config.MustLoad()
-	log := setupLogger(cfg.Env)
+	log := setupLogger(cfg)
 	log.Info("Starting application...")
+	if cfg.Logging.RedactMode == slogredact.ModeHash && cfg.Logging.RedactHashKey == "" {
+		log.Warn("logging.redact_hash_key is not set, masking redacted values instead of hashing them")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Error("failed to set up tracing", sl.Err(err))
+		os.Exit(1)
+	}
 
 	application := app.New(log, cfg)
 
@@ -28,33 +42,44 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 	<-stop
-	application.Stop()
+	if err := application.Stop(); err != nil {
+		log.Error("failed to stop application gracefully", sl.Err(err))
+	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Error("failed to flush traces", sl.Err(err))
+	}
 	log.Info("Application stopped")
 }
 
-func setupLogger(env string) *slog.Logger {
-	var log *slog.Logger
+func setupLogger(cfg *config.Config) *slog.Logger {
+	var handler slog.Handler
 
-	switch env {
+	switch cfg.Env {
 	case envLocal:
-		log = setupPrettySlog()
+		handler = setupPrettySlog()
 	case envProd:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})
 	default:
-		log = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		handler = slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
+	}
+
+	if cfg.Env != envLocal || !cfg.Logging.AllowPlaintextPII {
+		handler = slogredact.NewHandler(handler, slogredact.Options{
+			Keys:    cfg.Logging.RedactKeys,
+			Mode:    cfg.Logging.RedactMode,
+			HashKey: []byte(cfg.Logging.RedactHashKey),
+		})
 	}
 
-	return log
+	return slog.New(slogctx.NewHandler(handler))
 }
 
-func setupPrettySlog() *slog.Logger {
+func setupPrettySlog() slog.Handler {
 	opts := slogpretty.PrettyHandlerOptions{
 		SlogOpts: &slog.HandlerOptions{
 			Level: slog.LevelDebug,
 		},
 	}
 
-	handler := opts.NewPrettyHandler(os.Stdout)
-
-	return slog.New(handler)
+	return opts.NewPrettyHandler(os.Stdout)
 }
@@ -1,23 +1,60 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/grpcapi"
 	"github.com/finaptica/sso/internal/handlers"
+	"github.com/finaptica/sso/internal/lib/blob"
+	"github.com/finaptica/sso/internal/lib/clientauth"
+	"github.com/finaptica/sso/internal/lib/health"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/mailer"
+	"github.com/finaptica/sso/internal/lib/metrics"
 	"github.com/finaptica/sso/internal/lib/middlewares"
+	"github.com/finaptica/sso/internal/lib/schedule"
+	"github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/webhook"
 	"github.com/finaptica/sso/internal/services"
 	"github.com/finaptica/sso/internal/storage"
 	"github.com/finaptica/sso/internal/storage/repository"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+const (
+	readinessTimeout = 2 * time.Second
+	envLocal         = "local"
 )
 
 type App struct {
-	log    *slog.Logger
-	router *chi.Mux
-	port   int
+	log             *slog.Logger
+	server          *http.Server
+	db              *pgxpool.Pool
+	checker         *health.Checker
+	port            int
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	grpcServer      *grpc.Server
+	grpcPort        int
+
+	scheduler        *services.SchedulerService
+	schedulerEnabled bool
+	background       context.Context
+	stopBackground   context.CancelFunc
+	backgroundRuns   sync.WaitGroup
 }
 
 func New(log *slog.Logger, cfg *config.Config) *App {
@@ -27,32 +64,362 @@ func New(log *slog.Logger, cfg *config.Config) *App {
 		panic(err)
 	}
 	repositoryContainer := services.RepositoriesContainer{
-		UserRepo: repository.NewUserRepository(log, db),
-		RtsRepo:  repository.NewRefreshTokenRepository(log, db),
-		AppRepo:  repository.NewAppRepository(log, db),
-		Uow:      storage.NewUnitOfWork(db),
+		UserRepo:        repository.NewUserRepository(log, db),
+		RtsRepo:         repository.NewRefreshTokenRepository(log, db),
+		SessionRepo:     repository.NewSessionRepository(log, db),
+		AppRepo:         repository.NewAppRepository(log, db),
+		EmailChangeRepo: repository.NewEmailChangeRepository(log, db),
+		AuditRepo:       repository.NewAuditRepository(log, db),
+		OutboxRepo:      repository.NewOutboxRepository(log, db),
+		WebhookRepo:     repository.NewWebhookRepository(log, db),
+		RBACRepo:        repository.NewRBACRepository(log, db),
+		RelationRepo:    repository.NewRelationRepository(log, db),
+		OrgRepo:         repository.NewOrgRepository(log, db),
+		JanitorRepo:     repository.NewJanitorRepository(log, db),
+		JobRepo:         repository.NewJobRepository(log, db),
+		Uow:             storage.NewUnitOfWork(db),
+	}
+
+	signer, err := newSigner(log, cfg)
+	if err != nil {
+		log.Error("failed to load signing keys", sl.Err(err))
+		panic(err)
+	}
+
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		log.Error("failed to init blob storage", sl.Err(err))
+		panic(err)
+	}
+
+	mail, err := newMailer(log, cfg)
+	if err != nil {
+		log.Error("failed to init mailer", sl.Err(err))
+		panic(err)
+	}
+
+	appService := services.NewAppService(log, repositoryContainer)
+	auditService := services.NewAuditService(log, repositoryContainer, signer)
+	relationService := services.NewRelationService(log, repositoryContainer, cfg)
+	webhookService := services.NewWebhookService(log, repositoryContainer, webhook.NewClient(cfg.Webhooks.Timeout), cfg)
+	janitorService := services.NewJanitorService(log, repositoryContainer, cfg)
+
+	jobs, err := backgroundJobs(cfg, auditService, webhookService, janitorService)
+	if err != nil {
+		log.Error("failed to init background jobs", sl.Err(err))
+		panic(err)
 	}
+	scheduler := services.NewSchedulerService(log, repositoryContainer, storage.NewAdvisoryLocker(db), cfg, jobs...)
 
 	servicesContainer := handlers.ServicesContainer{
-		AuthService: services.NewAuthService(log, repositoryContainer, cfg),
-		RtsService:  services.NewRefreshTokenService(log, repositoryContainer, cfg),
+		AuthService:       services.NewAuthService(log, repositoryContainer, signer, cfg),
+		RtsService:        services.NewRefreshTokenService(log, repositoryContainer, signer, cfg),
+		SessionService:    services.NewSessionService(log, repositoryContainer),
+		AppService:        appService,
+		UserService:       services.NewUserService(log, repositoryContainer),
+		ProfileService:    services.NewProfileService(log, repositoryContainer, blobs, mail, cfg),
+		AuditService:      auditService,
+		WebhookService:    webhookService,
+		RBACService:       services.NewRBACService(log, repositoryContainer),
+		RelationService:   relationService,
+		OrgService:        services.NewOrgService(log, repositoryContainer, mail, cfg),
+		InvitationService: services.NewInvitationService(log, repositoryContainer, signer, cfg),
+		SchedulerService:  scheduler,
+	}
+	clients := clientauth.NewCache(appService, cfg.ClientAuth.CacheTTL)
+
+	prometheus.MustRegister(metrics.NewPoolCollector(db))
+
+	checker := health.New(readinessTimeout)
+	checker.Register("database", db.Ping)
+	checker.Register("migrations", func(ctx context.Context) error {
+		return storage.CheckSchemaVersion(ctx, db)
+	})
+	checker.Register("signing_keys", func(context.Context) error {
+		if signer.KeyCount() == 0 {
+			return errors.New("no signing keys loaded")
+		}
+		return nil
+	})
+	if s3, ok := blobs.(*blob.S3Store); ok {
+		checker.Register("blob_storage", s3.Ping)
 	}
 
 	authHandler := handlers.NewAuthHandler(servicesContainer)
+	adminAppsHandler := handlers.NewAdminAppsHandler(servicesContainer)
+	adminUsersHandler := handlers.NewAdminUsersHandler(servicesContainer)
+	profileHandler := handlers.NewProfileHandler(servicesContainer)
+	sessionsHandler := handlers.NewSessionsHandler(servicesContainer)
+	adminAuditHandler := handlers.NewAdminAuditHandler(servicesContainer)
+	adminWebhooksHandler := handlers.NewAdminWebhooksHandler(servicesContainer)
+	adminRBACHandler := handlers.NewAdminRBACHandler(servicesContainer)
+	adminRelationsHandler := handlers.NewAdminRelationsHandler(servicesContainer)
+	relationsHandler := handlers.NewRelationsHandler(servicesContainer)
+	adminOrgsHandler := handlers.NewAdminOrgsHandler(servicesContainer)
+	orgsHandler := handlers.NewOrgsHandler(servicesContainer)
+	adminInvitationsHandler := handlers.NewAdminInvitationsHandler(servicesContainer)
+	adminJobsHandler := handlers.NewAdminJobsHandler(servicesContainer)
+	jwksHandler := handlers.NewJWKSHandler(signer)
+	healthHandler := handlers.NewHealthHandler(checker, func() handlers.DBStats {
+		s := db.Stat()
+		return handlers.DBStats{
+			MaxConns:      s.MaxConns(),
+			TotalConns:    s.TotalConns(),
+			IdleConns:     s.IdleConns(),
+			AcquiredConns: s.AcquiredConns(),
+		}
+	})
 
 	r := chi.NewRouter()
+	r.Use(middlewares.RequestID(cfg.Http.TrustProxyHeaders))
+	r.Use(middlewares.Tracing)
+	r.Use(middlewares.AccessLog(log))
+	r.Use(middlewares.Metrics)
 	r.Use(middlewares.Recoverer(log))
+	r.Get("/healthz", healthHandler.Liveness)
+	r.Get("/readyz", healthHandler.Readiness)
+	r.With(middlewares.AdminAuth(log, cfg.Admin.Tokens)).Get("/status", healthHandler.Status)
+	r.Method(http.MethodGet, "/metrics", promhttp.Handler())
+	r.Get("/.well-known/jwks.json", jwksHandler.Keys)
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/register", authHandler.Register)
 		r.Post("/login", authHandler.Login)
 		r.Post("/refresh", authHandler.Refresh)
+		r.Post("/password", authHandler.ChangePassword)
+		r.Post("/email/confirm", profileHandler.ConfirmEmail)
+	})
+	r.Route("/me", func(r chi.Router) {
+		r.Use(middlewares.UserAuth(log, signer))
+		r.Get("/", profileHandler.Get)
+		r.Patch("/", profileHandler.Update)
+		r.Get("/avatar", profileHandler.GetAvatar)
+		r.Put("/avatar", profileHandler.UploadAvatar)
+		r.Delete("/avatar", profileHandler.DeleteAvatar)
+		r.Post("/email", profileHandler.ChangeEmail)
+		r.Get("/sessions", sessionsHandler.ListMine)
+		r.Delete("/sessions/{sessionID}", sessionsHandler.RevokeMine)
+		r.Get("/orgs", orgsHandler.ListMine)
+		r.Post("/org-invitations/accept", orgsHandler.AcceptInvitation)
+	})
+	orgMembers := func(r chi.Router) {
+		r.Get("/{orgID}/members", orgsHandler.ListMembers)
+		r.Patch("/{orgID}/members/{userID}", orgsHandler.UpdateMember)
+		r.Delete("/{orgID}/members/{userID}", orgsHandler.RemoveMember)
+		r.Get("/{orgID}/invitations", orgsHandler.ListInvitations)
+		r.Post("/{orgID}/invitations", orgsHandler.CreateInvitation)
+		r.Delete("/{orgID}/invitations/{invitationID}", orgsHandler.RevokeInvitation)
+	}
+	r.Route("/orgs", func(r chi.Router) {
+		r.Use(middlewares.UserAuth(log, signer))
+		orgMembers(r)
+	})
+	r.Route("/relations", func(r chi.Router) {
+		r.Use(middlewares.AppAuth(log, clients))
+		r.Post("/write", relationsHandler.Write)
+		r.Post("/check", relationsHandler.Check)
+		r.Post("/expand", relationsHandler.Expand)
+		r.Post("/list-objects", relationsHandler.ListObjects)
 	})
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(middlewares.AdminAuth(log, cfg.Admin.Tokens))
+		r.Route("/apps", func(r chi.Router) {
+			r.Get("/", adminAppsHandler.List)
+			r.Post("/", adminAppsHandler.Create)
+			r.Get("/{appID}", adminAppsHandler.Get)
+			r.Patch("/{appID}", adminAppsHandler.Update)
+			r.Delete("/{appID}", adminAppsHandler.Delete)
+			r.Post("/{appID}/disable", adminAppsHandler.Disable)
+			r.Post("/{appID}/enable", adminAppsHandler.Enable)
+			r.Get("/{appID}/secrets", adminAppsHandler.ListSecrets)
+			r.Post("/{appID}/secrets/rotate", adminAppsHandler.RotateSecret)
+			r.Delete("/{appID}/secrets/{secretID}", adminAppsHandler.DeleteSecret)
+			r.Get("/{appID}/invitations", adminInvitationsHandler.List)
+			r.Post("/{appID}/invitations", adminInvitationsHandler.Create)
+			r.Delete("/{appID}/invitations/{invitationID}", adminInvitationsHandler.Revoke)
+			r.Get("/{appID}/webhooks", adminWebhooksHandler.ListEndpoints)
+			r.Post("/{appID}/webhooks", adminWebhooksHandler.CreateEndpoint)
+			r.Delete("/{appID}/webhooks/{endpointID}", adminWebhooksHandler.DeleteEndpoint)
+			r.Post("/{appID}/webhooks/{endpointID}/disable", adminWebhooksHandler.DisableEndpoint)
+			r.Post("/{appID}/webhooks/{endpointID}/enable", adminWebhooksHandler.EnableEndpoint)
+			r.Get("/{appID}/permissions", adminRBACHandler.ListPermissions)
+			r.Post("/{appID}/permissions", adminRBACHandler.CreatePermission)
+			r.Delete("/{appID}/permissions/{permissionID}", adminRBACHandler.DeletePermission)
+			r.Get("/{appID}/roles", adminRBACHandler.ListRoles)
+			r.Post("/{appID}/roles", adminRBACHandler.CreateRole)
+			r.Get("/{appID}/roles/{roleID}", adminRBACHandler.GetRole)
+			r.Patch("/{appID}/roles/{roleID}", adminRBACHandler.UpdateRole)
+			r.Delete("/{appID}/roles/{roleID}", adminRBACHandler.DeleteRole)
+			r.Get("/{appID}/relation-namespaces", adminRelationsHandler.ListNamespaces)
+			r.Put("/{appID}/relation-namespaces/{namespace}", adminRelationsHandler.PutNamespace)
+			r.Delete("/{appID}/relation-namespaces/{namespace}", adminRelationsHandler.DeleteNamespace)
+		})
+		r.Route("/webhook-deliveries", func(r chi.Router) {
+			r.Get("/", adminWebhooksHandler.ListDeliveries)
+			r.Get("/{deliveryID}", adminWebhooksHandler.GetDelivery)
+			r.Post("/{deliveryID}/replay", adminWebhooksHandler.ReplayDelivery)
+		})
+		r.Route("/orgs", func(r chi.Router) {
+			r.Get("/", adminOrgsHandler.List)
+			r.Post("/", adminOrgsHandler.Create)
+			r.Get("/{orgID}", adminOrgsHandler.Get)
+			r.Patch("/{orgID}", adminOrgsHandler.Update)
+			r.Delete("/{orgID}", adminOrgsHandler.Delete)
+			r.Post("/{orgID}/members", orgsHandler.AddMember)
+			orgMembers(r)
+		})
+		r.Get("/audit-events", adminAuditHandler.List)
+		r.Route("/jobs", func(r chi.Router) {
+			r.Get("/", adminJobsHandler.List)
+			r.Get("/{job}/runs", adminJobsHandler.ListRuns)
+			r.Post("/{job}/run", adminJobsHandler.Trigger)
+		})
+		r.Route("/users", func(r chi.Router) {
+			r.Get("/", adminUsersHandler.List)
+			r.Get("/{userID}", adminUsersHandler.Get)
+			r.Patch("/{userID}", adminUsersHandler.Update)
+			r.Delete("/{userID}", adminUsersHandler.Delete)
+			r.Post("/{userID}/disable", adminUsersHandler.Disable)
+			r.Post("/{userID}/enable", adminUsersHandler.Enable)
+			r.Post("/{userID}/status", adminUsersHandler.SetStatus)
+			r.Get("/{userID}/status-history", adminUsersHandler.StatusHistory)
+			r.Post("/{userID}/force-password-reset", adminUsersHandler.ForcePasswordReset)
+			r.Get("/{userID}/sessions", sessionsHandler.List)
+			r.Delete("/{userID}/sessions", sessionsHandler.RevokeAll)
+			r.Delete("/{userID}/sessions/{sessionID}", sessionsHandler.Revoke)
+			r.Get("/{userID}/roles", adminRBACHandler.ListUserRoles)
+			r.Post("/{userID}/roles", adminRBACHandler.AssignUserRole)
+			r.Delete("/{userID}/roles/{roleID}", adminRBACHandler.UnassignUserRole)
+			r.Get("/{userID}/authorization", adminRBACHandler.GetUserAuthorization)
+		})
+		r.Route("/groups", func(r chi.Router) {
+			r.Get("/", adminRBACHandler.ListGroups)
+			r.Post("/", adminRBACHandler.CreateGroup)
+			r.Get("/{groupID}", adminRBACHandler.GetGroup)
+			r.Delete("/{groupID}", adminRBACHandler.DeleteGroup)
+			r.Get("/{groupID}/members", adminRBACHandler.ListGroupMembers)
+			r.Put("/{groupID}/members/{userID}", adminRBACHandler.AddGroupMember)
+			r.Delete("/{groupID}/members/{userID}", adminRBACHandler.RemoveGroupMember)
+			r.Get("/{groupID}/roles", adminRBACHandler.ListGroupRoles)
+			r.Post("/{groupID}/roles", adminRBACHandler.AssignGroupRole)
+			r.Delete("/{groupID}/roles/{roleID}", adminRBACHandler.UnassignGroupRole)
+		})
+	})
+
+	background, stopBackground := context.WithCancel(context.Background())
 
 	return &App{
-		router: r,
-		port:   cfg.Http.Port,
-		log:    log,
+		server: &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.Http.Port),
+			Handler:      r,
+			ReadTimeout:  cfg.Http.Timeout,
+			WriteTimeout: cfg.Http.Timeout,
+		},
+		db:              db,
+		checker:         checker,
+		port:            cfg.Http.Port,
+		shutdownTimeout: cfg.Http.ShutdownTimeout,
+		drainDelay:      cfg.Http.DrainDelay,
+		log:             log,
+		grpcServer:      grpcapi.NewServer(log, clients, relationService),
+		grpcPort:        cfg.GRPC.Port,
+
+		scheduler:        scheduler,
+		schedulerEnabled: cfg.Scheduler.Enabled,
+		background:       background,
+		stopBackground:   stopBackground,
+	}
+}
+
+// newSigner loads the configured signing keys. Local runs without key files
+// get an ephemeral key so tokens do not survive a restart.
+func newSigner(log *slog.Logger, cfg *config.Config) (*token.Signer, error) {
+	if len(cfg.JWT.KeyFiles) == 0 && cfg.Env == envLocal {
+		log.Warn("no jwt key files configured, using an ephemeral signing key")
+		key, err := token.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		return token.NewSigner(cfg.JWT.Issuer, key)
+	}
+
+	keys, err := token.LoadKeyFiles(cfg.JWT.KeyFiles)
+	if err != nil {
+		return nil, err
 	}
+
+	return token.NewSigner(cfg.JWT.Issuer, keys...)
+}
+
+func newBlobStore(cfg *config.Config) (services.BlobStore, error) {
+	switch cfg.Blob.Driver {
+	case "local":
+		return blob.NewLocalStore(cfg.Blob.Dir)
+	case "s3":
+		return blob.NewS3Store(blob.S3Options{
+			Endpoint:        cfg.Blob.S3.Endpoint,
+			Region:          cfg.Blob.S3.Region,
+			Bucket:          cfg.Blob.S3.Bucket,
+			AccessKeyID:     cfg.Blob.S3.AccessKeyID,
+			SecretAccessKey: cfg.Blob.S3.SecretAccessKey,
+			UseSSL:          cfg.Blob.S3.UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown blob driver %q", cfg.Blob.Driver)
+	}
+}
+
+func newMailer(log *slog.Logger, cfg *config.Config) (services.Mailer, error) {
+	switch cfg.Mail.Driver {
+	case "log":
+		// Messages carry confirmation and invitation tokens, which must not
+		// end up in the logs of a shared environment.
+		if cfg.Env != envLocal {
+			return nil, fmt.Errorf("mail driver %q is only allowed in the %s env", cfg.Mail.Driver, envLocal)
+		}
+		log.Warn("mail driver is log, emails are written to the log instead of being sent")
+		return mailer.NewLogMailer(log), nil
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPOptions{
+			Host:     cfg.Mail.SMTP.Host,
+			Port:     cfg.Mail.SMTP.Port,
+			Username: cfg.Mail.SMTP.Username,
+			Password: cfg.Mail.SMTP.Password,
+			From:     cfg.Mail.From,
+			Timeout:  cfg.Mail.SMTP.Timeout,
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+}
+
+// backgroundJobs lists the jobs the scheduler runs. A job left without a
+// schedule by the configuration still runs when an admin triggers it.
+func backgroundJobs(cfg *config.Config, audit *services.AuditService, webhooks *services.WebhookService, janitor *services.JanitorService) ([]services.Job, error) {
+	jobs := []services.Job{
+		{Name: models.JobAuditChain, Run: audit.Chain, Quiet: true},
+		{Name: models.JobAuditCheckpoint, Run: audit.Checkpoint},
+		{Name: models.JobWebhookDispatch, Run: webhooks.Dispatch, Quiet: true},
+		{Name: models.JobJanitor, Run: janitor.Sweep},
+	}
+
+	if cfg.Audit.ChainInterval > 0 {
+		jobs[0].Schedule = schedule.Every(cfg.Audit.ChainInterval)
+	}
+	if cfg.Audit.CheckpointInterval > 0 {
+		jobs[1].Schedule = schedule.Every(cfg.Audit.CheckpointInterval)
+	}
+	if cfg.Webhooks.PollInterval > 0 {
+		jobs[2].Schedule = schedule.Every(cfg.Webhooks.PollInterval)
+	}
+	if cfg.Janitor.Schedule != "" {
+		sched, err := schedule.Parse(cfg.Janitor.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("janitor: %w", err)
+		}
+		jobs[3].Schedule = sched
+	}
+
+	return jobs, nil
 }
 
 func (a *App) MustRun() {
@@ -68,8 +435,23 @@ func (a *App) Run() error {
 		slog.Int("port", a.port),
 	)
 
-	err := http.ListenAndServe(fmt.Sprintf(":%d", a.port), a.router)
-	if err != nil {
+	if a.grpcPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.grpcPort))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		log.Info("grpc server is listening", slog.Int("grpc_port", a.grpcPort))
+		go func() {
+			if err := a.grpcServer.Serve(lis); err != nil {
+				log.Error("grpc server stopped", sl.Err(err))
+			}
+		}()
+	}
+
+	a.startBackground()
+
+	err := a.server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Error("failed to listen and serve")
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -77,6 +459,73 @@ func (a *App) Run() error {
 	return nil
 }
 
+// startBackground lets this replica stand for scheduler leader. The jobs
+// stop when the app stops.
+func (a *App) startBackground() {
+	if a.schedulerEnabled {
+		a.backgroundRuns.Add(1)
+		go func() {
+			defer a.backgroundRuns.Done()
+			a.scheduler.Run(a.background)
+		}()
+	}
+}
+
+// stopBackgroundJobs cancels the scheduler and waits until ctx is done for it and
+// any triggered job runs to finish, so none is left using the database pool.
+func (a *App) stopBackgroundJobs(ctx context.Context) {
+	a.stopBackground()
+
+	stopped := make(chan struct{})
+	go func() {
+		a.backgroundRuns.Wait()
+		a.scheduler.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		a.log.Warn("background jobs still running at shutdown")
+	}
+}
+
+// Stop fails readiness, waits for the drain delay so the orchestrator stops
+// routing traffic, then shuts the listener down, waits for the background jobs
+// and closes the database pool.
 func (a *App) Stop() error {
+	const op = "httpapp.Stop"
+	log := a.log.With(slog.String("op", op))
+
+	a.checker.SetShuttingDown()
+	log.Info("readiness set to failing, draining", slog.Duration("drain_delay", a.drainDelay))
+	time.Sleep(a.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+
+	err := a.server.Shutdown(ctx)
+	a.stopGRPC(ctx)
+	a.stopBackgroundJobs(ctx)
+	a.db.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
+
+// stopGRPC lets in-flight calls finish until ctx is done, then cuts them off.
+func (a *App) stopGRPC(ctx context.Context) {
+	stopped := make(chan struct{})
+	go func() {
+		a.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		a.grpcServer.Stop()
+	}
+}
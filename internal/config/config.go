@@ -13,13 +13,232 @@ type Config struct {
 	Env                      string        `yaml:"env" env-default:"local"`
 	ConnectionStringPostgres string        `yaml:"connection-string-postgres-sso"`
 	AccessTokenTTL           time.Duration `yaml:"access_token_ttl" env-required:"true"`
-	RefreshTokenTTL          time.Duration `yaml:"refresh_token_ttl" env-required:"true"`
-	Http                     HTTPConfig    `yaml:"http"`
+	// RefreshTokenTTL is the idle timeout of a session: a refresh token not
+	// rotated within it expires. Each rotation starts it over.
+	RefreshTokenTTL time.Duration      `yaml:"refresh_token_ttl" env-required:"true"`
+	Http            HTTPConfig         `yaml:"http"`
+	Admin           AdminConfig        `yaml:"admin"`
+	Tracing         TracingConfig      `yaml:"tracing"`
+	Logging         LoggingConfig      `yaml:"logging"`
+	JWT             JWTConfig          `yaml:"jwt"`
+	Blob            BlobConfig         `yaml:"blob"`
+	Avatar          AvatarConfig       `yaml:"avatar"`
+	Mail            MailConfig         `yaml:"mail"`
+	EmailChange     EmailChangeConfig  `yaml:"email_change"`
+	Email           EmailConfig        `yaml:"email"`
+	Audit           AuditConfig        `yaml:"audit"`
+	Webhooks        WebhooksConfig     `yaml:"webhooks"`
+	Relations       RelationsConfig    `yaml:"relations"`
+	GRPC            GRPCConfig         `yaml:"grpc"`
+	ClientAuth      ClientAuthConfig   `yaml:"client_auth"`
+	Orgs            OrgsConfig         `yaml:"orgs"`
+	Registration    RegistrationConfig `yaml:"registration"`
+	Sessions        SessionsConfig     `yaml:"sessions"`
+	Janitor         JanitorConfig      `yaml:"janitor"`
+	Scheduler       SchedulerConfig    `yaml:"scheduler"`
 }
 
 type HTTPConfig struct {
-	Port    int           `yaml:"port"`
-	Timeout time.Duration `yaml:"timeout"`
+	Port            int           `yaml:"port"`
+	Timeout         time.Duration `yaml:"timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"15s"`
+	// DrainDelay is how long /readyz reports failure before the listener is
+	// shut down, giving the orchestrator time to stop routing traffic.
+	DrainDelay time.Duration `yaml:"drain_delay" env-default:"5s"`
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For/X-Real-IP.
+	// Enable only behind a proxy that overwrites them.
+	TrustProxyHeaders bool `yaml:"trust_proxy_headers"`
+}
+
+type AdminConfig struct {
+	Tokens []AdminToken `yaml:"tokens"`
+}
+
+// AdminToken is a static bearer token granting access to the admin endpoints.
+// Name identifies the holder in logs.
+type AdminToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+}
+
+type TracingConfig struct {
+	// Exporter is one of "none", "stdout" or "otlp".
+	Exporter    string  `yaml:"exporter" env-default:"none"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name" env-default:"sso"`
+	SampleRatio float64 `yaml:"sample_ratio" env-default:"1"`
+}
+
+type LoggingConfig struct {
+	RedactKeys []string `yaml:"redact_keys" env-default:"email,refreshTokenValue,password,secret"`
+	// RedactMode is "mask" or "hash". Hashing needs RedactHashKey and masks
+	// without it.
+	RedactMode    string `yaml:"redact_mode" env-default:"hash"`
+	RedactHashKey string `yaml:"redact_hash_key"`
+	// AllowPlaintextPII turns redaction off. It is ignored outside the local env.
+	AllowPlaintextPII bool `yaml:"allow_plaintext_pii"`
+}
+
+type JWTConfig struct {
+	Issuer string `yaml:"issuer" env-default:"sso"`
+	// KeyFiles are PEM encoded Ed25519 private keys. The first one signs new
+	// tokens, the rest are only kept for verification during key rotation.
+	KeyFiles []string `yaml:"key_files"`
+}
+
+type BlobConfig struct {
+	// Driver is "local" or "s3".
+	Driver string `yaml:"driver" env-default:"local"`
+	// Dir is the root directory of the local driver.
+	Dir string       `yaml:"dir" env-default:"./data/blobs"`
+	S3  BlobS3Config `yaml:"s3"`
+}
+
+type BlobS3Config struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	UseSSL          bool   `yaml:"use_ssl" env-default:"true"`
+}
+
+type AvatarConfig struct {
+	MaxBytes int64 `yaml:"max_bytes" env-default:"2097152"`
+}
+
+type MailConfig struct {
+	// Driver is "log" or "smtp". The log driver only writes messages,
+	// tokens included, to the service log and is refused outside the local
+	// env.
+	Driver string     `yaml:"driver" env-default:"log"`
+	From   string     `yaml:"from" env-default:"no-reply@localhost"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port" env-default:"587"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Timeout bounds a whole delivery, from dialing to QUIT, unless the
+	// caller's deadline is sooner.
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+type EmailConfig struct {
+	// LocalPart is "preserve" or "lowercase". Either way addresses are unique
+	// and looked up case-insensitively; this only decides what is stored.
+	LocalPart string `yaml:"local_part" env-default:"preserve"`
+}
+
+type EmailChangeConfig struct {
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"24h"`
+	// ConfirmURL is the page the confirmation email links to, with the token
+	// appended as the "token" query parameter. Without it the email carries
+	// the bare token.
+	ConfirmURL string `yaml:"confirm_url"`
+}
+
+type AuditConfig struct {
+	// ChainInterval is how often queued audit events are hashed into the
+	// chain, and so how long they may take to show up in listings. Zero
+	// leaves chaining to manual runs of the job.
+	ChainInterval time.Duration `yaml:"chain_interval" env-default:"1s"`
+	// CheckpointInterval is how often the head of the audit chain is signed.
+	// Zero leaves checkpoints to manual runs of the job.
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval" env-default:"1h"`
+}
+
+type WebhooksConfig struct {
+	// Source is the CloudEvents source attribute of every event sent.
+	Source string `yaml:"source" env-default:"sso"`
+	// PollInterval is how often the dispatcher looks for work. Zero leaves
+	// it to manual runs of the job; events accumulate in the outbox.
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"5s"`
+	BatchSize    int           `yaml:"batch_size" env-default:"50"`
+	Timeout      time.Duration `yaml:"timeout" env-default:"10s"`
+	// MaxAttempts is how many failed attempts move a delivery to dead.
+	MaxAttempts int `yaml:"max_attempts" env-default:"12"`
+	// The wait after the n-th failure is BackoffBase * 2^(n-1), capped at
+	// BackoffMax.
+	BackoffBase time.Duration `yaml:"backoff_base" env-default:"30s"`
+	BackoffMax  time.Duration `yaml:"backoff_max" env-default:"6h"`
+}
+
+type RelationsConfig struct {
+	// MaxDepth bounds how many rewrites and usersets one check may follow.
+	MaxDepth         int `yaml:"max_depth" env-default:"25"`
+	ListObjectsLimit int `yaml:"list_objects_limit" env-default:"1000"`
+}
+
+type GRPCConfig struct {
+	// Port of the gRPC listener. Zero turns it off.
+	Port int `yaml:"port" env-default:"44044"`
+}
+
+type ClientAuthConfig struct {
+	// CacheTTL is how long a verified app secret is accepted without
+	// comparing the hash again, and so how long a deleted or expired secret
+	// or a disabled app keeps working on a running instance. It is capped at
+	// 5s; 0 checks every request.
+	CacheTTL time.Duration `yaml:"cache_ttl" env-default:"5s"`
+}
+
+type OrgsConfig struct {
+	InvitationTTL time.Duration `yaml:"invitation_ttl" env-default:"168h"`
+	// AcceptURL is the page the invitation email links to, with the token
+	// appended as the "token" query parameter. Without it the email carries
+	// the bare token.
+	AcceptURL string `yaml:"accept_url"`
+}
+
+type RegistrationConfig struct {
+	// InvitationTTL is how long an invitation token is valid unless the
+	// admin asks for another lifetime, which may not exceed
+	// MaxInvitationTTL.
+	InvitationTTL    time.Duration `yaml:"invitation_ttl" env-default:"168h"`
+	MaxInvitationTTL time.Duration `yaml:"max_invitation_ttl" env-default:"720h"`
+}
+
+type SessionsConfig struct {
+	// Lifetime is how long a session lasts from sign-in however often its
+	// refresh token is rotated.
+	Lifetime time.Duration `yaml:"lifetime" env-default:"720h"`
+	// OfflineIdleTimeout and OfflineLifetime replace RefreshTokenTTL and
+	// Lifetime for offline_access sessions, in apps that allow them.
+	OfflineIdleTimeout time.Duration `yaml:"offline_idle_timeout" env-default:"720h"`
+	OfflineLifetime    time.Duration `yaml:"offline_lifetime" env-default:"8760h"`
+	// RotationGracePeriod is how long a rotated refresh token still yields
+	// its successor, for clients refreshing concurrently. Zero turns it off.
+	RotationGracePeriod time.Duration `yaml:"rotation_grace_period" env-default:"10s"`
+}
+
+type JanitorConfig struct {
+	// Schedule says when expired and revoked rows are purged, in the syntax
+	// of schedule.Parse. Empty leaves it to manual runs of the job.
+	Schedule string `yaml:"schedule" env-default:"*/10 * * * *"`
+	// BatchSize rows are deleted per statement, at most MaxBatches times per
+	// artifact and run, so one run never holds locks for long.
+	BatchSize  int `yaml:"batch_size" env-default:"1000"`
+	MaxBatches int `yaml:"max_batches" env-default:"100"`
+	// TokenRetention is how long refresh tokens and sessions are kept after
+	// they expire or are revoked; Retention the same for invitations and
+	// email changes.
+	TokenRetention time.Duration `yaml:"token_retention" env-default:"72h"`
+	Retention      time.Duration `yaml:"retention" env-default:"720h"`
+}
+
+type SchedulerConfig struct {
+	// Enabled lets this replica stand for leader and run scheduled jobs.
+	// Manual runs through the admin API work either way.
+	Enabled bool `yaml:"enabled" env-default:"true"`
+	// ElectionInterval is how often a follower tries to take over and how
+	// often the leader checks it still holds the lock.
+	ElectionInterval time.Duration `yaml:"election_interval" env-default:"15s"`
+	// HistoryRetention is how long the janitor keeps finished job runs.
+	HistoryRetention time.Duration `yaml:"history_retention" env-default:"168h"`
 }
 
 func MustLoad() *Config {
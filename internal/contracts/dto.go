@@ -1,9 +1,535 @@
 package contracts
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type TokensInfo struct {
 	AccessToken           string
 	RefreshToken          string
 	RefreshTokenExpiresAt time.Time
+	SessionID             uuid.UUID
+}
+
+// LoginParams signs a user in to an app. DeviceName is an optional label the
+// client picks so the user can tell their sessions apart. OfflineAccess asks
+// for a long-lived session, if the app allows them.
+type LoginParams struct {
+	Email         string `json:"email"`
+	Password      string `json:"password"`
+	AppID         int    `json:"app_id"`
+	DeviceName    string `json:"device_name"`
+	OfflineAccess bool   `json:"offline_access"`
+}
+
+// SessionInfo is one active sign-in. Current marks the session the request
+// was made from.
+type SessionInfo struct {
+	ID         uuid.UUID `json:"id"`
+	AppID      int       `json:"app_id"`
+	AppName    string    `json:"app_name"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// AbsoluteExpiresAt is when the session ends however active it is.
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	Offline           bool      `json:"offline"`
+	Current           bool      `json:"current"`
+}
+
+type AppInfo struct {
+	ID                     int      `json:"id"`
+	Name                   string   `json:"name"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	AllowedGrantTypes      []string `json:"allowed_grant_types"`
+	AccessTokenTTLSeconds  int      `json:"access_token_ttl_seconds,omitempty"`
+	RefreshTokenTTLSeconds int      `json:"refresh_token_ttl_seconds,omitempty"`
+	// SessionLifetimeSeconds and the offline settings are 0 when the app
+	// uses the service defaults.
+	SessionLifetimeSeconds        int        `json:"session_lifetime_seconds,omitempty"`
+	OfflineAccess                 bool       `json:"offline_access"`
+	OfflineIdleTimeoutSeconds     int        `json:"offline_idle_timeout_seconds,omitempty"`
+	OfflineSessionLifetimeSeconds int        `json:"offline_session_lifetime_seconds,omitempty"`
+	IsDisabled                    bool       `json:"is_disabled"`
+	OrgID                         *uuid.UUID `json:"org_id,omitempty"`
+	RegistrationPolicy            string     `json:"registration_policy"`
+	RegistrationDomains           []string   `json:"registration_domains"`
+	CreatedAt                     time.Time  `json:"created_at"`
+	UpdatedAt                     time.Time  `json:"updated_at"`
+}
+
+// CreatedApp is returned once, when the app is created; the secret cannot be
+// retrieved again.
+type CreatedApp struct {
+	AppInfo
+	Secret string `json:"secret"`
+}
+
+type CreateAppParams struct {
+	Name                   string   `json:"name"`
+	RedirectURIs           []string `json:"redirect_uris"`
+	AllowedGrantTypes      []string `json:"allowed_grant_types"`
+	AccessTokenTTLSeconds  int      `json:"access_token_ttl_seconds"`
+	RefreshTokenTTLSeconds int      `json:"refresh_token_ttl_seconds"`
+	// SessionLifetimeSeconds bounds a session from sign-in; refresh token
+	// rotation cannot extend it.
+	SessionLifetimeSeconds        int  `json:"session_lifetime_seconds"`
+	OfflineAccess                 bool `json:"offline_access"`
+	OfflineIdleTimeoutSeconds     int  `json:"offline_idle_timeout_seconds"`
+	OfflineSessionLifetimeSeconds int  `json:"offline_session_lifetime_seconds"`
+	// OrgID makes the app owned by the organization.
+	OrgID *uuid.UUID `json:"org_id"`
+	// RegistrationPolicy defaults to "open".
+	RegistrationPolicy  string   `json:"registration_policy"`
+	RegistrationDomains []string `json:"registration_domains"`
+}
+
+// UpdateAppParams changes only the fields that are set. A TTL of 0 removes
+// the override and the nil UUID as org_id releases the app from its
+// organization.
+type UpdateAppParams struct {
+	Name                          *string    `json:"name"`
+	RedirectURIs                  *[]string  `json:"redirect_uris"`
+	AllowedGrantTypes             *[]string  `json:"allowed_grant_types"`
+	AccessTokenTTLSeconds         *int       `json:"access_token_ttl_seconds"`
+	RefreshTokenTTLSeconds        *int       `json:"refresh_token_ttl_seconds"`
+	SessionLifetimeSeconds        *int       `json:"session_lifetime_seconds"`
+	OfflineAccess                 *bool      `json:"offline_access"`
+	OfflineIdleTimeoutSeconds     *int       `json:"offline_idle_timeout_seconds"`
+	OfflineSessionLifetimeSeconds *int       `json:"offline_session_lifetime_seconds"`
+	OrgID                         *uuid.UUID `json:"org_id"`
+	RegistrationPolicy            *string    `json:"registration_policy"`
+	RegistrationDomains           *[]string  `json:"registration_domains"`
+}
+
+// RegisterParams signs a user up through an app. InvitationToken is needed
+// when the app's registration policy asks for one.
+type RegisterParams struct {
+	Email           string `json:"email"`
+	Password        string `json:"password"`
+	AppID           int    `json:"app_id"`
+	InvitationToken string `json:"invitation_token"`
+}
+
+type AppInvitationInfo struct {
+	ID        uuid.UUID   `json:"id"`
+	AppID     int         `json:"app_id"`
+	Email     string      `json:"email,omitempty"`
+	RoleIDs   []uuid.UUID `json:"role_ids"`
+	CreatedBy string      `json:"created_by"`
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"`
+}
+
+// CreatedAppInvitation carries the invitation token. It is shown exactly
+// once.
+type CreatedAppInvitation struct {
+	AppInvitationInfo
+	Token string `json:"token"`
+}
+
+// CreateAppInvitationParams binds the invitation to Email when set and
+// grants RoleIDs, roles of the app, to whoever uses it. TTLSeconds of 0
+// takes the configured default.
+type CreateAppInvitationParams struct {
+	Email      string      `json:"email"`
+	RoleIDs    []uuid.UUID `json:"role_ids"`
+	TTLSeconds int         `json:"ttl_seconds"`
+}
+
+type AppSecretInfo struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	IsActive  bool       `json:"is_active"`
+}
+
+// RotatedSecret carries a newly issued secret. It is shown exactly once.
+type RotatedSecret struct {
+	SecretID          uuid.UUID `json:"secret_id"`
+	Secret            string    `json:"secret"`
+	PreviousExpiresAt time.Time `json:"previous_expires_at"`
+}
+
+type UserInfo struct {
+	ID                    uuid.UUID `json:"id"`
+	Email                 string    `json:"email"`
+	Name                  string    `json:"name,omitempty"`
+	Surname               string    `json:"surname,omitempty"`
+	Status                string    `json:"status"`
+	PasswordResetRequired bool      `json:"password_reset_required"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// ListUsersParams filters an admin user listing. Cursor is the opaque
+// next_cursor of the previous page.
+type ListUsersParams struct {
+	EmailPrefix string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Status      string
+	AppID       int
+	Cursor      string
+	Limit       int
+}
+
+type UserPage struct {
+	Users      []UserInfo `json:"users"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// UpdateUserParams changes only the fields that are set. An empty string
+// clears the field.
+type UpdateUserParams struct {
+	Name    *string `json:"name"`
+	Surname *string `json:"surname"`
+}
+
+type UserStatusTransitionInfo struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ProfileInfo is what a signed-in user sees about themselves.
+type ProfileInfo struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name,omitempty"`
+	Surname   string    `json:"surname,omitempty"`
+	HasAvatar bool      `json:"has_avatar"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListAuditEventsParams filters an audit event listing. Cursor is the opaque
+// next_cursor of the previous page.
+type ListAuditEventsParams struct {
+	EventType     string
+	Actor         string
+	SubjectUserID uuid.UUID
+	AppID         int
+	Outcome       string
+	From          time.Time
+	To            time.Time
+	Cursor        string
+	Limit         int
+}
+
+type AuditEventInfo struct {
+	ID            int64          `json:"id"`
+	OccurredAt    time.Time      `json:"occurred_at"`
+	EventType     string         `json:"event_type"`
+	Actor         string         `json:"actor"`
+	SubjectUserID *uuid.UUID     `json:"subject_user_id,omitempty"`
+	AppID         *int           `json:"app_id,omitempty"`
+	IP            string         `json:"ip,omitempty"`
+	UserAgent     string         `json:"user_agent,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+	Outcome       string         `json:"outcome"`
+	Reason        string         `json:"reason,omitempty"`
+	Details       map[string]any `json:"details,omitempty"`
+	// Hash is the event's link in the audit chain, hex encoded.
+	Hash string `json:"hash,omitempty"`
+}
+
+type AuditEventPage struct {
+	Events     []AuditEventInfo `json:"events"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+type WebhookEndpointInfo struct {
+	ID         uuid.UUID `json:"id"`
+	AppID      int       `json:"app_id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	IsDisabled bool      `json:"is_disabled"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreatedWebhookEndpoint carries the signing secret. It is shown exactly once.
+type CreatedWebhookEndpoint struct {
+	WebhookEndpointInfo
+	Secret string `json:"secret"`
+}
+
+// CreateWebhookEndpointParams subscribes url to event_types, or to every
+// event type when the list is empty.
+type CreateWebhookEndpointParams struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+// ListWebhookDeliveriesParams filters a delivery listing. Cursor is the
+// opaque next_cursor of the previous page.
+type ListWebhookDeliveriesParams struct {
+	Status     string
+	EndpointID uuid.UUID
+	AppID      int
+	Cursor     string
+	Limit      int
+}
+
+type WebhookDeliveryInfo struct {
+	ID             uuid.UUID  `json:"id"`
+	EventID        uuid.UUID  `json:"event_id"`
+	EndpointID     uuid.UUID  `json:"endpoint_id"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	LastStatusCode *int       `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDeliveryInfo `json:"deliveries"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// WebhookDeliveryDetail is a delivery with the event it carries.
+type WebhookDeliveryDetail struct {
+	WebhookDeliveryInfo
+	Event OutboxEventInfo `json:"event"`
+}
+
+type OutboxEventInfo struct {
+	ID         uuid.UUID      `json:"id"`
+	Type       string         `json:"type"`
+	Subject    string         `json:"subject"`
+	AppID      *int           `json:"app_id,omitempty"`
+	Data       map[string]any `json:"data"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+type PermissionInfo struct {
+	ID          uuid.UUID `json:"id"`
+	AppID       int       `json:"app_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreatePermissionParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleInfo struct {
+	ID          uuid.UUID `json:"id"`
+	AppID       int       `json:"app_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateRoleParams names the role's permissions, which must already exist in
+// the app.
+type CreateRoleParams struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleParams changes only the fields that are set. Permissions replaces
+// the whole list.
+type UpdateRoleParams struct {
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"`
+}
+
+type GroupInfo struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateGroupParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// AuthorizationInfo is what the user's next access token for the app will
+// carry in its roles and permissions claims.
+type AuthorizationInfo struct {
+	AppID       int      `json:"app_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRoleParams struct {
+	RoleID uuid.UUID `json:"role_id"`
+}
+
+type RelationNamespaceInfo struct {
+	Name      string          `json:"name"`
+	Config    json.RawMessage `json:"config"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// RelationTuple is object#relation@subject. Objects are "namespace:id";
+// a subject is an object or a userset such as "group:eng#member".
+type RelationTuple struct {
+	Object   string `json:"object"`
+	Relation string `json:"relation"`
+	Subject  string `json:"subject"`
+}
+
+type WriteRelationsParams struct {
+	Writes  []RelationTuple `json:"writes"`
+	Deletes []RelationTuple `json:"deletes"`
+}
+
+type WriteRelationsResult struct {
+	ConsistencyToken string `json:"consistency_token"`
+}
+
+// Consistency picks the snapshot a read is evaluated at. With neither token
+// set the latest snapshot is used.
+type Consistency struct {
+	// AtLeastAsFresh is a token from an earlier write or read; the snapshot
+	// read is that one or newer.
+	AtLeastAsFresh string `json:"at_least_as_fresh,omitempty"`
+	// AtExactSnapshot evaluates at exactly the token's snapshot.
+	AtExactSnapshot string `json:"at_exact_snapshot,omitempty"`
+}
+
+type CheckParams struct {
+	Object      string      `json:"object"`
+	Relation    string      `json:"relation"`
+	Subject     string      `json:"subject"`
+	Consistency Consistency `json:"consistency"`
+}
+
+type CheckResult struct {
+	Allowed          bool   `json:"allowed"`
+	ConsistencyToken string `json:"consistency_token"`
+}
+
+type ExpandParams struct {
+	Object      string      `json:"object"`
+	Relation    string      `json:"relation"`
+	Consistency Consistency `json:"consistency"`
+}
+
+// ExpandNode is a node of a userset tree. Leaves list the subjects stored
+// for Object#Relation; usersets among them are not expanded further.
+type ExpandNode struct {
+	Operation string       `json:"operation"`
+	Object    string       `json:"object"`
+	Relation  string       `json:"relation"`
+	Subjects  []string     `json:"subjects,omitempty"`
+	Children  []ExpandNode `json:"children,omitempty"`
+}
+
+type ExpandResult struct {
+	Tree             ExpandNode `json:"tree"`
+	ConsistencyToken string     `json:"consistency_token"`
+}
+
+type ListObjectsParams struct {
+	Namespace   string      `json:"namespace"`
+	Relation    string      `json:"relation"`
+	Subject     string      `json:"subject"`
+	Consistency Consistency `json:"consistency"`
+}
+
+type ListObjectsResult struct {
+	Objects          []string `json:"objects"`
+	ConsistencyToken string   `json:"consistency_token"`
+}
+
+type OrgInfo struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateOrgParams creates an organization, with OwnerID as its first owner
+// when set.
+type CreateOrgParams struct {
+	Name    string     `json:"name"`
+	Slug    string     `json:"slug"`
+	OwnerID *uuid.UUID `json:"owner_id"`
+}
+
+type UpdateOrgParams struct {
+	Name *string `json:"name"`
+	Slug *string `json:"slug"`
+}
+
+// UserOrgInfo is an organization the user belongs to and their role in it.
+type UserOrgInfo struct {
+	OrgInfo
+	Role string `json:"role"`
+}
+
+type OrgMemberInfo struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AddOrgMemberParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+type UpdateOrgMemberParams struct {
+	Role string `json:"role"`
+}
+
+type OrgInvitationInfo struct {
+	ID        uuid.UUID `json:"id"`
+	OrgID     uuid.UUID `json:"org_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy string    `json:"invited_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type CreateOrgInvitationParams struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// JobInfo describes a background job. NextRunAt is when the scheduler
+// leader runs it next, absent when it only runs on demand.
+type JobInfo struct {
+	Name      string      `json:"name"`
+	Schedule  string      `json:"schedule,omitempty"`
+	NextRunAt *time.Time  `json:"next_run_at,omitempty"`
+	LastRun   *JobRunInfo `json:"last_run,omitempty"`
+}
+
+type JobRunInfo struct {
+	ID          uuid.UUID  `json:"id"`
+	Job         string     `json:"job"`
+	Trigger     string     `json:"trigger"`
+	TriggeredBy string     `json:"triggered_by,omitempty"`
+	Instance    string     `json:"instance"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
 }
@@ -1,7 +1,108 @@
 package models
 
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	GrantTypePassword     = "password"
+	GrantTypeRefreshToken = "refresh_token"
+)
+
+// Registration policies decide who may sign up through an app.
+const (
+	// RegistrationOpen lets anyone register.
+	RegistrationOpen = "open"
+	// RegistrationInvitation requires an invitation token.
+	RegistrationInvitation = "invitation"
+	// RegistrationDomains admits addresses of RegistrationDomains, and anyone
+	// else holding an invitation token.
+	RegistrationDomains = "domains"
+	// RegistrationDisabled refuses every sign-up.
+	RegistrationDisabled = "disabled"
+)
+
 type App struct {
-	ID     int    `db:"id"`
-	Name   string `db:"name"`
-	Secret string `db:"secret"`
+	ID                int           `db:"id"`
+	Name              string        `db:"name"`
+	RedirectURIs      []string      `db:"redirect_uris"`
+	AllowedGrantTypes []string      `db:"allowed_grant_types"`
+	AccessTokenTTL    time.Duration `db:"access_token_ttl_seconds"`
+	// RefreshTokenTTL is the idle timeout of the app's sessions.
+	RefreshTokenTTL time.Duration `db:"refresh_token_ttl_seconds"`
+	// SessionLifetime caps a session from sign-in, rotations included.
+	SessionLifetime time.Duration `db:"session_lifetime_seconds"`
+	// OfflineAccess lets clients ask for offline_access sessions, which use
+	// the Offline* limits instead.
+	OfflineAccess          bool          `db:"offline_access"`
+	OfflineIdleTimeout     time.Duration `db:"offline_idle_timeout_seconds"`
+	OfflineSessionLifetime time.Duration `db:"offline_session_lifetime_seconds"`
+	IsDisabled             bool          `db:"is_disabled"`
+	// OrgID is the organization owning the app. Only its members may sign
+	// in to an owned app.
+	OrgID *uuid.UUID `db:"org_id"`
+	// RegistrationPolicy is one of the Registration* constants.
+	RegistrationPolicy  string    `db:"registration_policy"`
+	RegistrationDomains []string  `db:"registration_domains"`
+	CreatedAt           time.Time `db:"created_at"`
+	UpdatedAt           time.Time `db:"updated_at"`
+}
+
+// AllowsGrant reports whether the app may use the given grant type.
+func (a App) AllowsGrant(grantType string) bool {
+	for _, g := range a.AllowedGrantTypes {
+		if g == grantType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRegistrationDomain reports whether the domain of email is one of
+// RegistrationDomains. Subdomains are not included.
+func (a App) AllowsRegistrationDomain(email string) bool {
+	at := strings.LastIndexByte(email, '@')
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range a.RegistrationDomains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// AppInvitation lets one person register through an app whatever its
+// registration policy, except a disabled one. It may be bound to an email
+// address and pre-assigns roles of the app.
+type AppInvitation struct {
+	ID        uuid.UUID   `db:"id"`
+	AppID     int         `db:"app_id"`
+	Email     string      `db:"email"`
+	RoleIDs   []uuid.UUID `db:"role_ids"`
+	CreatedBy string      `db:"created_by"`
+	CreatedAt time.Time   `db:"created_at"`
+	ExpiresAt time.Time   `db:"expires_at"`
+	UsedAt    *time.Time  `db:"used_at"`
+	UsedBy    *uuid.UUID  `db:"used_by"`
+}
+
+// AppSecret is a client secret stored as a bcrypt hash. An app may hold
+// several at once while a rotation is in progress.
+type AppSecret struct {
+	ID         uuid.UUID  `db:"id"`
+	AppID      int        `db:"app_id"`
+	SecretHash []byte     `db:"secret_hash"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+}
+
+// ActiveAt reports whether the secret is still usable at t.
+func (s AppSecret) ActiveAt(t time.Time) bool {
+	return s.ExpiresAt == nil || s.ExpiresAt.After(t)
 }
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audit event types.
+const (
+	AuditLogin                   = "auth.login"
+	AuditRegister                = "auth.register"
+	AuditRefresh                 = "auth.refresh"
+	AuditPasswordChange          = "auth.password_change"
+	AuditEmailChangeRequest      = "auth.email_change_request"
+	AuditEmailChange             = "auth.email_change"
+	AuditSessionRevoke           = "session.revoke"
+	AuditSessionRevokeAll        = "session.revoke_all"
+	AuditUserStatusChange        = "admin.user.status_change"
+	AuditUserUpdate              = "admin.user.update"
+	AuditUserPasswordReset       = "admin.user.force_password_reset"
+	AuditUserPurge               = "admin.user.purge"
+	AuditAppCreate               = "admin.app.create"
+	AuditAppUpdate               = "admin.app.update"
+	AuditAppStateChange          = "admin.app.state_change"
+	AuditAppDelete               = "admin.app.delete"
+	AuditAppSecretRotate         = "admin.app.secret_rotate"
+	AuditAppSecretDelete         = "admin.app.secret_delete"
+	AuditAppInvitationCreate     = "admin.app.invitation_create"
+	AuditAppInvitationRevoke     = "admin.app.invitation_revoke"
+	AuditWebhookCreate           = "admin.webhook.create"
+	AuditWebhookStateChange      = "admin.webhook.state_change"
+	AuditWebhookDelete           = "admin.webhook.delete"
+	AuditWebhookReplay           = "admin.webhook.replay"
+	AuditPermissionCreate        = "admin.rbac.permission_create"
+	AuditPermissionDelete        = "admin.rbac.permission_delete"
+	AuditRoleCreate              = "admin.rbac.role_create"
+	AuditRoleUpdate              = "admin.rbac.role_update"
+	AuditRoleDelete              = "admin.rbac.role_delete"
+	AuditRoleGrant               = "admin.rbac.role_grant"
+	AuditRoleRevoke              = "admin.rbac.role_revoke"
+	AuditGroupCreate             = "admin.rbac.group_create"
+	AuditGroupDelete             = "admin.rbac.group_delete"
+	AuditGroupMemberAdd          = "admin.rbac.group_member_add"
+	AuditGroupMemberRemove       = "admin.rbac.group_member_remove"
+	AuditRelationNamespacePut    = "admin.relations.namespace_put"
+	AuditRelationNamespaceDelete = "admin.relations.namespace_delete"
+	AuditJobTrigger              = "admin.job.trigger"
+	AuditOrgCreate               = "org.create"
+	AuditOrgUpdate               = "org.update"
+	AuditOrgDelete               = "org.delete"
+	AuditOrgMemberAdd            = "org.member_add"
+	AuditOrgMemberUpdate         = "org.member_update"
+	AuditOrgMemberRemove         = "org.member_remove"
+	AuditOrgInvite               = "org.invite"
+	AuditOrgInviteRevoke         = "org.invite_revoke"
+	AuditOrgInviteAccept         = "org.invite_accept"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEvent is one append-only record of a security relevant action.
+type AuditEvent struct {
+	ID            int64          `db:"id"`
+	OccurredAt    time.Time      `db:"occurred_at"`
+	EventType     string         `db:"event_type"`
+	Actor         string         `db:"actor"`
+	SubjectUserID *uuid.UUID     `db:"subject_user_id"`
+	AppID         *int           `db:"app_id"`
+	IP            string         `db:"ip"`
+	UserAgent     string         `db:"user_agent"`
+	RequestID     string         `db:"request_id"`
+	Outcome       string         `db:"outcome"`
+	Reason        string         `db:"reason"`
+	Details       map[string]any `db:"details"`
+	// PrevHash and Hash link the event into the tamper-evident chain. Both
+	// are nil for events written before the chain existed.
+	PrevHash []byte `db:"prev_hash"`
+	Hash     []byte `db:"hash"`
+}
+
+// AuditCheckpoint is a signed statement that the chain ended at EventID with
+// EventHash when it was taken.
+type AuditCheckpoint struct {
+	ID        int64     `db:"id"`
+	EventID   int64     `db:"event_id"`
+	EventHash []byte    `db:"event_hash"`
+	CreatedAt time.Time `db:"created_at"`
+	KeyID     string    `db:"key_id"`
+	Signature []byte    `db:"signature"`
+}
+
+// AuditFilter narrows an audit event listing. Zero values are ignored.
+type AuditFilter struct {
+	EventType     string
+	Actor         string
+	SubjectUserID uuid.UUID
+	AppID         int
+	Outcome       string
+	From          time.Time
+	To            time.Time
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChange is a pending request to move a user to a new email address.
+// Only the SHA-256 hash of the confirmation token is stored.
+type EmailChange struct {
+	ID          uuid.UUID  `db:"id"`
+	UserID      uuid.UUID  `db:"user_id"`
+	NewEmail    string     `db:"new_email"`
+	TokenHash   []byte     `db:"token_hash"`
+	CreatedAt   time.Time  `db:"created_at"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	ConfirmedAt *time.Time `db:"confirmed_at"`
+}
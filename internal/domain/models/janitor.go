@@ -0,0 +1,12 @@
+package models
+
+// Expiring artifacts the janitor purges once they have been dead for longer
+// than their retention.
+const (
+	ArtifactRefreshTokens  = "refresh_tokens"
+	ArtifactSessions       = "sessions"
+	ArtifactEmailChanges   = "email_changes"
+	ArtifactAppInvitations = "app_invitations"
+	ArtifactOrgInvitations = "org_invitations"
+	ArtifactJobRuns        = "job_runs"
+)
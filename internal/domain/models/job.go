@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Background jobs run by the scheduler.
+const (
+	JobAuditChain      = "audit_chain"
+	JobAuditCheckpoint = "audit_checkpoint"
+	JobWebhookDispatch = "webhook_dispatch"
+	JobJanitor         = "janitor"
+)
+
+// What started a job run.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// Job run states.
+const (
+	JobRunRunning   = "running"
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun is one execution of a background job. Instance is the host name of
+// the replica that ran it.
+type JobRun struct {
+	ID          uuid.UUID  `db:"id"`
+	Job         string     `db:"job"`
+	Trigger     string     `db:"trigger"`
+	TriggeredBy string     `db:"triggered_by"`
+	Instance    string     `db:"instance"`
+	Status      string     `db:"status"`
+	Error       string     `db:"error"`
+	StartedAt   time.Time  `db:"started_at"`
+	FinishedAt  *time.Time `db:"finished_at"`
+}
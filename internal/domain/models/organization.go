@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization roles, from most to least privileged. Owners and admins
+// manage the members; only owners manage other owners.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+// IsValidOrgRole reports whether role is one of the organization roles.
+func IsValidOrgRole(role string) bool {
+	switch role {
+	case OrgRoleOwner, OrgRoleAdmin, OrgRoleMember:
+		return true
+	}
+	return false
+}
+
+// CanManageOrg reports whether role may manage the organization's members
+// and invitations.
+func CanManageOrg(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin
+}
+
+// Organization is a tenant that owns users, through memberships, and apps.
+type Organization struct {
+	ID        uuid.UUID `db:"id"`
+	Name      string    `db:"name"`
+	Slug      string    `db:"slug"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// OrgMembership places a user in an organization with an org-level role.
+type OrgMembership struct {
+	OrgID     uuid.UUID `db:"org_id"`
+	UserID    uuid.UUID `db:"user_id"`
+	Email     string    `db:"email"`
+	Role      string    `db:"role"`
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// UserOrganization is an organization seen from one of its members.
+type UserOrganization struct {
+	Organization
+	Role string `db:"role"`
+}
+
+// OrgInvitation offers membership to whoever holds the email address. Only
+// the SHA-256 hash of the invitation token is stored.
+type OrgInvitation struct {
+	ID         uuid.UUID  `db:"id"`
+	OrgID      uuid.UUID  `db:"org_id"`
+	Email      string     `db:"email"`
+	Role       string     `db:"role"`
+	TokenHash  []byte     `db:"token_hash"`
+	InvitedBy  string     `db:"invited_by"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	AcceptedAt *time.Time `db:"accepted_at"`
+	AcceptedBy *uuid.UUID `db:"accepted_by"`
+}
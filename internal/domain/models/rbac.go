@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permission is a named capability defined by an app, such as
+// "invoices:read".
+type Permission struct {
+	ID          uuid.UUID `db:"id"`
+	AppID       int       `db:"app_id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Role bundles permissions of one app.
+type Role struct {
+	ID          uuid.UUID `db:"id"`
+	AppID       int       `db:"app_id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	Permissions []string  `db:"permissions"`
+	CreatedAt   time.Time `db:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at"`
+}
+
+// Group holds users so roles can be granted to all of them at once.
+type Group struct {
+	ID          uuid.UUID `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Authorization is what a user may do in one app, through roles granted
+// directly or through groups. OrgID and OrgRole are set when the app belongs
+// to an organization.
+type Authorization struct {
+	Roles       []string
+	Permissions []string
+	OrgID       *uuid.UUID
+	OrgRole     string
+}
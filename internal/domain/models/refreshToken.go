@@ -7,11 +7,15 @@ import (
 )
 
 type RefreshToken struct {
-	ID        uuid.UUID `db:"id"`
-	UserID    uuid.UUID `db:"user_id"`
-	AppID     int       `db:"app_id"`
-	Value     string    `db:"value"`
-	IsRevoked bool      `db:"is_revoked"`
-	CreatedAt time.Time `db:"created_at"`
-	ExpiresAt time.Time `db:"expires_at"`
+	ID        uuid.UUID  `db:"id"`
+	UserID    uuid.UUID  `db:"user_id"`
+	AppID     int        `db:"app_id"`
+	SessionID *uuid.UUID `db:"session_id"`
+	Value     string     `db:"value"`
+	IsRevoked bool       `db:"is_revoked"`
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	// ReplacedBy and RotatedAt are set when rotation revoked the token.
+	ReplacedBy *uuid.UUID `db:"replaced_by"`
+	RotatedAt  *time.Time `db:"rotated_at"`
 }
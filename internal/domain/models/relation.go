@@ -0,0 +1,220 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	relationNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	objectIDPattern     = regexp.MustCompile(`^[A-Za-z0-9_./|=+\-]{1,256}$`)
+)
+
+// IsValidRelationName reports whether s can name a namespace or relation.
+func IsValidRelationName(s string) bool {
+	return relationNamePattern.MatchString(s)
+}
+
+// RelationObject is namespace:object_id, e.g. "document:readme".
+type RelationObject struct {
+	Namespace string
+	ObjectID  string
+}
+
+func (o RelationObject) String() string {
+	return o.Namespace + ":" + o.ObjectID
+}
+
+// RelationSubject is either an object ("user:42") or, when Relation is set,
+// the userset of everyone holding that relation on it ("group:eng#member").
+type RelationSubject struct {
+	RelationObject
+	Relation string
+}
+
+func (s RelationSubject) String() string {
+	if s.Relation == "" {
+		return s.RelationObject.String()
+	}
+	return s.RelationObject.String() + "#" + s.Relation
+}
+
+// RelationTuple states that Subject holds Relation on Object.
+type RelationTuple struct {
+	Object   RelationObject
+	Relation string
+	Subject  RelationSubject
+}
+
+func (t RelationTuple) String() string {
+	return t.Object.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// ParseRelationObject parses "namespace:object_id".
+func ParseRelationObject(s string) (RelationObject, error) {
+	ns, id, ok := strings.Cut(s, ":")
+	if !ok || !IsValidRelationName(ns) || !objectIDPattern.MatchString(id) {
+		return RelationObject{}, fmt.Errorf("invalid object %q", s)
+	}
+	return RelationObject{Namespace: ns, ObjectID: id}, nil
+}
+
+// ParseRelationSubject parses "namespace:object_id" or
+// "namespace:object_id#relation".
+func ParseRelationSubject(s string) (RelationSubject, error) {
+	obj, rel, hasRel := strings.Cut(s, "#")
+	o, err := ParseRelationObject(obj)
+	if err != nil {
+		return RelationSubject{}, fmt.Errorf("invalid subject %q", s)
+	}
+	if hasRel && !IsValidRelationName(rel) {
+		return RelationSubject{}, fmt.Errorf("invalid subject %q", s)
+	}
+	return RelationSubject{RelationObject: o, Relation: rel}, nil
+}
+
+// RelationNamespace is the config of one object type of an app.
+type RelationNamespace struct {
+	AppID     int
+	Name      string
+	Config    NamespaceConfig
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NamespaceConfig maps each relation of a namespace to the rewrite that
+// computes it. A nil or empty rewrite means the relation is read from
+// stored tuples only.
+type NamespaceConfig struct {
+	Relations map[string]*UsersetRewrite `json:"relations"`
+}
+
+// UsersetRewrite has exactly one field set, or none for This.
+type UsersetRewrite struct {
+	// This is the subjects stored as tuples for the relation itself.
+	This *struct{} `json:"this,omitempty"`
+	// ComputedUserset is another relation of the same object.
+	ComputedUserset *ComputedUserset `json:"computed_userset,omitempty"`
+	// TupleToUserset follows the objects stored under Tupleset and takes
+	// their ComputedUserset relation, e.g. the viewers of a parent folder.
+	TupleToUserset *TupleToUserset   `json:"tuple_to_userset,omitempty"`
+	Union          []*UsersetRewrite `json:"union,omitempty"`
+	Intersection   []*UsersetRewrite `json:"intersection,omitempty"`
+	Exclusion      *Exclusion        `json:"exclusion,omitempty"`
+}
+
+type ComputedUserset struct {
+	Relation string `json:"relation"`
+}
+
+type TupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computed_userset"`
+}
+
+// Exclusion is everyone in Base but not in Subtract.
+type Exclusion struct {
+	Base     *UsersetRewrite `json:"base"`
+	Subtract *UsersetRewrite `json:"subtract"`
+}
+
+// IsThis reports whether r only reads the relation's own tuples.
+func (r *UsersetRewrite) IsThis() bool {
+	return r == nil || (r.ComputedUserset == nil && r.TupleToUserset == nil &&
+		r.Union == nil && r.Intersection == nil && r.Exclusion == nil)
+}
+
+// Validate checks relation names and that every rewrite refers to relations
+// of the namespace. Relations of other namespaces reached through
+// TupleToUserset are only known at evaluation time.
+func (c NamespaceConfig) Validate() error {
+	for name, r := range c.Relations {
+		if !IsValidRelationName(name) {
+			return fmt.Errorf("invalid relation name %q", name)
+		}
+		if err := c.validateRewrite(r); err != nil {
+			return fmt.Errorf("relation %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (c NamespaceConfig) validateRewrite(r *UsersetRewrite) error {
+	if r.IsThis() {
+		return nil
+	}
+
+	set := 0
+	if r.This != nil {
+		set++
+	}
+	if r.ComputedUserset != nil {
+		set++
+		if _, ok := c.Relations[r.ComputedUserset.Relation]; !ok {
+			return fmt.Errorf("computed_userset names unknown relation %q", r.ComputedUserset.Relation)
+		}
+	}
+	if r.TupleToUserset != nil {
+		set++
+		if _, ok := c.Relations[r.TupleToUserset.Tupleset]; !ok {
+			return fmt.Errorf("tuple_to_userset names unknown tupleset %q", r.TupleToUserset.Tupleset)
+		}
+		if !IsValidRelationName(r.TupleToUserset.ComputedUserset) {
+			return fmt.Errorf("tuple_to_userset has invalid computed_userset %q", r.TupleToUserset.ComputedUserset)
+		}
+	}
+	for _, children := range [][]*UsersetRewrite{r.Union, r.Intersection} {
+		if children == nil {
+			continue
+		}
+		set++
+		if len(children) == 0 {
+			return errors.New("union and intersection need at least one child")
+		}
+		for _, child := range children {
+			if err := c.validateRewrite(child); err != nil {
+				return err
+			}
+		}
+	}
+	if r.Exclusion != nil {
+		set++
+		if r.Exclusion.Base == nil || r.Exclusion.Subtract == nil {
+			return errors.New("exclusion needs base and subtract")
+		}
+		if err := c.validateRewrite(r.Exclusion.Base); err != nil {
+			return err
+		}
+		if err := c.validateRewrite(r.Exclusion.Subtract); err != nil {
+			return err
+		}
+	}
+
+	if set != 1 {
+		return errors.New("a rewrite must have exactly one operation")
+	}
+	return nil
+}
+
+// AllowsTuples reports whether tuples may be written for relation, i.e. its
+// rewrite reads them somewhere.
+func (c NamespaceConfig) AllowsTuples(relation string) bool {
+	r, ok := c.Relations[relation]
+	return ok && readsThis(r)
+}
+
+func readsThis(r *UsersetRewrite) bool {
+	if r.IsThis() || r.This != nil {
+		return true
+	}
+	for _, child := range slices.Concat(r.Union, r.Intersection) {
+		if readsThis(child) {
+			return true
+		}
+	}
+	return r.Exclusion != nil && (readsThis(r.Exclusion.Base) || readsThis(r.Exclusion.Subtract))
+}
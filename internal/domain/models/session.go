@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one sign-in of a user to an app on one device. It outlives the
+// refresh tokens rotated under it; ExpiresAt follows the newest of them and
+// never passes AbsoluteExpiresAt.
+type Session struct {
+	ID         uuid.UUID `db:"id"`
+	UserID     uuid.UUID `db:"user_id"`
+	AppID      int       `db:"app_id"`
+	AppName    string    `db:"app_name"`
+	DeviceName string    `db:"device_name"`
+	UserAgent  string    `db:"user_agent"`
+	IP         string    `db:"ip"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastUsedAt time.Time `db:"last_used_at"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	// AbsoluteExpiresAt is fixed at sign-in; rotation cannot extend it.
+	AbsoluteExpiresAt time.Time `db:"absolute_expires_at"`
+	// Offline marks an offline_access session.
+	Offline   bool       `db:"offline"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
@@ -1,12 +1,82 @@
 package models
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserStatusActive              = "active"
+	UserStatusPendingVerification = "pending_verification"
+	UserStatusLocked              = "locked"
+	UserStatusDisabled            = "disabled"
+	UserStatusDeleted             = "deleted"
+)
+
+// userStatusTransitions lists, per status, the statuses a user may move to.
+// A deleted user can only be restored.
+var userStatusTransitions = map[string][]string{
+	UserStatusActive:              {UserStatusPendingVerification, UserStatusLocked, UserStatusDisabled, UserStatusDeleted},
+	UserStatusPendingVerification: {UserStatusActive, UserStatusLocked, UserStatusDisabled, UserStatusDeleted},
+	UserStatusLocked:              {UserStatusActive, UserStatusDisabled, UserStatusDeleted},
+	UserStatusDisabled:            {UserStatusActive, UserStatusDeleted},
+	UserStatusDeleted:             {UserStatusActive},
+}
+
+// IsKnownUserStatus reports whether status is part of the lifecycle.
+func IsKnownUserStatus(status string) bool {
+	_, ok := userStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionUserStatus reports whether a user may move from one status to
+// another.
+func CanTransitionUserStatus(from, to string) bool {
+	for _, s := range userStatusTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
 
 type User struct {
-	ID        uuid.UUID `db:"id"`
-	Email     string    `db:"email"`
-	PassHash  []byte    `db:"pass_hash"`
-	Name      string    `db:"name"`
-	Surname   string    `db:"surname"`
-	AvatarKey string    `db:"avatar_key"`
+	ID                    uuid.UUID  `db:"id"`
+	Email                 string     `db:"email"`
+	PassHash              []byte     `db:"pass_hash"`
+	Name                  string     `db:"name"`
+	Surname               string     `db:"surname"`
+	AvatarKey             string     `db:"avatar_key"`
+	Status                string     `db:"status"`
+	PasswordResetRequired bool       `db:"password_reset_required"`
+	CreatedAt             time.Time  `db:"created_at"`
+	UpdatedAt             time.Time  `db:"updated_at"`
+	DeletedAt             *time.Time `db:"deleted_at"`
+}
+
+// UserStatusTransition records who moved a user between statuses and why.
+type UserStatusTransition struct {
+	ID         uuid.UUID `db:"id"`
+	UserID     uuid.UUID `db:"user_id"`
+	FromStatus string    `db:"from_status"`
+	ToStatus   string    `db:"to_status"`
+	Actor      string    `db:"actor"`
+	Reason     string    `db:"reason"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// UserFilter narrows an admin user listing. Zero values are ignored.
+type UserFilter struct {
+	EmailPrefix string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Status      string
+	AppID       int
+}
+
+// UserCursor marks the position after which the next page of users starts.
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
 }
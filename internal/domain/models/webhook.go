@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Domain event types, published to webhooks as the CloudEvents type.
+const (
+	EventUserRegistered    = "sso.user.registered"
+	EventUserEmailChanged  = "sso.user.email_changed"
+	EventUserStatusChanged = "sso.user.status_changed"
+)
+
+// IsKnownEventType reports whether t is a domain event type webhooks can
+// subscribe to.
+func IsKnownEventType(t string) bool {
+	switch t {
+	case EventUserRegistered, EventUserEmailChanged, EventUserStatusChanged:
+		return true
+	}
+	return false
+}
+
+// OutboxEvent is a domain event recorded in the transaction that caused it.
+// Events without an AppID concern every app related to UserID: those the user
+// has signed in to or belongs to through an organization.
+type OutboxEvent struct {
+	ID           uuid.UUID      `db:"id"`
+	Type         string         `db:"event_type"`
+	Subject      string         `db:"subject"`
+	AppID        *int           `db:"app_id"`
+	UserID       *uuid.UUID     `db:"user_id"`
+	Data         map[string]any `db:"data"`
+	OccurredAt   time.Time      `db:"occurred_at"`
+	DispatchedAt *time.Time     `db:"dispatched_at"`
+}
+
+// WebhookEndpoint receives an app's domain events.
+type WebhookEndpoint struct {
+	ID         uuid.UUID `db:"id"`
+	AppID      int       `db:"app_id"`
+	URL        string    `db:"url"`
+	Secret     string    `db:"secret"`
+	EventTypes []string  `db:"event_types"`
+	IsDisabled bool      `db:"is_disabled"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookDelivery tracks sending one event to one endpoint.
+type WebhookDelivery struct {
+	ID             uuid.UUID  `db:"id"`
+	EventID        uuid.UUID  `db:"event_id"`
+	EndpointID     uuid.UUID  `db:"endpoint_id"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	NextAttemptAt  time.Time  `db:"next_attempt_at"`
+	LastAttemptAt  *time.Time `db:"last_attempt_at"`
+	LastStatusCode *int       `db:"last_status_code"`
+	LastError      string     `db:"last_error"`
+	CreatedAt      time.Time  `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+}
+
+// WebhookJob is a claimed delivery together with what it needs to be sent.
+type WebhookJob struct {
+	Delivery WebhookDelivery
+	Event    OutboxEvent
+	Endpoint WebhookEndpoint
+}
+
+// WebhookDeliveryFilter narrows a delivery listing. Zero values are ignored.
+type WebhookDeliveryFilter struct {
+	Status     string
+	EndpointID uuid.UUID
+	AppID      int
+}
+
+// WebhookDeliveryCursor marks the position after which the next page of
+// deliveries starts.
+type WebhookDeliveryCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
@@ -0,0 +1,66 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const relationsServiceName = "sso.relations.v1.Relations"
+
+type RelationService interface {
+	WriteRelations(ctx context.Context, appID int, params contracts.WriteRelationsParams) (contracts.WriteRelationsResult, error)
+	Check(ctx context.Context, appID int, params contracts.CheckParams) (contracts.CheckResult, error)
+	Expand(ctx context.Context, appID int, params contracts.ExpandParams) (contracts.ExpandResult, error)
+	ListObjects(ctx context.Context, appID int, params contracts.ListObjectsParams) (contracts.ListObjectsResult, error)
+}
+
+var relationsServiceDesc = grpc.ServiceDesc{
+	ServiceName: relationsServiceName,
+	HandlerType: (*RelationService)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Write", RelationService.WriteRelations),
+		unaryMethod("Check", RelationService.Check),
+		unaryMethod("Expand", RelationService.Expand),
+		unaryMethod("ListObjects", RelationService.ListObjects),
+	},
+}
+
+// unaryMethod adapts a service method called on behalf of the authenticated
+// app to a gRPC method handler.
+func unaryMethod[Req, Resp any](name string, call func(RelationService, context.Context, int, Req) (Resp, error)) grpc.MethodDesc {
+	fullMethod := "/" + relationsServiceName + "/" + name
+
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+
+			handler := func(ctx context.Context, req any) (any, error) {
+				appID, ok := reqctx.ClientApp(ctx)
+				if !ok {
+					return nil, status.Error(codes.Unauthenticated, "Unauthenticated")
+				}
+
+				resp, err := call(srv.(RelationService), ctx, appID, *req.(*Req))
+				if err != nil {
+					return nil, errs.ToStatus(err)
+				}
+				return &resp, nil
+			}
+
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+		},
+	}
+}
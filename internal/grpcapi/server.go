@@ -0,0 +1,72 @@
+// Package grpcapi serves the relationship API over gRPC.
+//
+// The service is JSON over gRPC: there is no protobuf contract, the service
+// descriptors are written by hand, and messages are the same JSON documents
+// the HTTP API takes, described in api/relations/v1/relations.schema.json.
+// Stock protobuf stubs cannot call it. Clients must use the "json" content
+// subtype (content-type application/grpc+json) with a codec that sends the
+// documents as the message bytes, and authenticate with an
+// "authorization: Basic base64(app_id:secret)" metadata entry.
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/finaptica/sso/internal/lib/clientauth"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return "json" }
+
+// NewServer returns a gRPC server with the relationship service registered.
+func NewServer(log *slog.Logger, clients *clientauth.Cache, relations RelationService) *grpc.Server {
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(appAuth(log, clients)))
+	s.RegisterService(&relationsServiceDesc, relations)
+	return s
+}
+
+// appAuth authenticates the calling app by its client credentials and
+// stores its ID in the context.
+func appAuth(log *slog.Logger, clients *clientauth.Cache) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var header string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("authorization"); len(v) > 0 {
+				header = v[0]
+			}
+		}
+
+		appID, secret, ok := clientauth.ParseBasic(header)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "Unauthenticated")
+		}
+		if err := clients.Verify(ctx, appID, secret); err != nil {
+			switch errs.KindOf(err) {
+			case errs.Unauthenticated, errs.PermissionDenied:
+				log.WarnContext(ctx, "rejected client credentials", slog.String("method", info.FullMethod), slog.Int("appID", appID), sl.Err(err))
+			default:
+				log.ErrorContext(ctx, "failed to verify client credentials", slog.String("method", info.FullMethod), sl.Err(err))
+			}
+			return nil, errs.ToStatus(err)
+		}
+
+		return handler(reqctx.WithClientApp(ctx, appID), req)
+	}
+}
@@ -2,21 +2,154 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"time"
 
 	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/blob"
 	"github.com/google/uuid"
 )
 
 type AuthService interface {
-	Register(ctx context.Context, email, password string) (userId uuid.UUID, err error)
-	Login(ctx context.Context, email string, password string, appId int) (tokensInfo contracts.TokensInfo, err error)
+	Register(ctx context.Context, params contracts.RegisterParams) (userId uuid.UUID, err error)
+	Login(ctx context.Context, params contracts.LoginParams) (tokensInfo contracts.TokensInfo, err error)
+	ChangePassword(ctx context.Context, email, currentPassword, newPassword string) error
 }
 
 type RefreshTokenService interface {
 	RefreshTokens(ctx context.Context, refreshToken string) (contracts.TokensInfo, error)
 }
 
+type SessionService interface {
+	ListSessions(ctx context.Context, userID uuid.UUID) ([]contracts.SessionInfo, error)
+	RevokeSession(ctx context.Context, userID, id uuid.UUID) error
+	RevokeAllSessions(ctx context.Context, userID uuid.UUID) error
+}
+
+type AppService interface {
+	CreateApp(ctx context.Context, params contracts.CreateAppParams) (contracts.CreatedApp, error)
+	ListApps(ctx context.Context) ([]contracts.AppInfo, error)
+	GetApp(ctx context.Context, id int) (contracts.AppInfo, error)
+	UpdateApp(ctx context.Context, id int, params contracts.UpdateAppParams) (contracts.AppInfo, error)
+	SetAppDisabled(ctx context.Context, id int, disabled bool) error
+	DeleteApp(ctx context.Context, id int) error
+	RotateSecret(ctx context.Context, appID int, previousTTL time.Duration) (contracts.RotatedSecret, error)
+	ListSecrets(ctx context.Context, appID int) ([]contracts.AppSecretInfo, error)
+	DeleteSecret(ctx context.Context, appID int, secretID uuid.UUID) error
+}
+
+type InvitationService interface {
+	CreateInvitation(ctx context.Context, appID int, params contracts.CreateAppInvitationParams) (contracts.CreatedAppInvitation, error)
+	ListInvitations(ctx context.Context, appID int) ([]contracts.AppInvitationInfo, error)
+	RevokeInvitation(ctx context.Context, appID int, id uuid.UUID) error
+}
+
+type UserService interface {
+	ListUsers(ctx context.Context, params contracts.ListUsersParams) (contracts.UserPage, error)
+	GetUser(ctx context.Context, id uuid.UUID) (contracts.UserInfo, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, params contracts.UpdateUserParams) (contracts.UserInfo, error)
+	SetUserStatus(ctx context.Context, id uuid.UUID, status, reason string) error
+	ListStatusHistory(ctx context.Context, id uuid.UUID) ([]contracts.UserStatusTransitionInfo, error)
+	ForcePasswordReset(ctx context.Context, id uuid.UUID) error
+	DeleteUser(ctx context.Context, id uuid.UUID, reason string) error
+	PurgeUser(ctx context.Context, id uuid.UUID) error
+}
+
+type ProfileService interface {
+	GetProfile(ctx context.Context, userID uuid.UUID) (contracts.ProfileInfo, error)
+	UpdateProfile(ctx context.Context, userID uuid.UUID, params contracts.UpdateUserParams) (contracts.ProfileInfo, error)
+	UploadAvatar(ctx context.Context, userID uuid.UUID, r io.Reader) (contracts.ProfileInfo, error)
+	GetAvatar(ctx context.Context, userID uuid.UUID) (io.ReadCloser, blob.Info, error)
+	DeleteAvatar(ctx context.Context, userID uuid.UUID) error
+	RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail, currentPassword string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+}
+
+type AuditService interface {
+	ListEvents(ctx context.Context, params contracts.ListAuditEventsParams) (contracts.AuditEventPage, error)
+}
+
+type WebhookService interface {
+	CreateEndpoint(ctx context.Context, appID int, params contracts.CreateWebhookEndpointParams) (contracts.CreatedWebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, appID int) ([]contracts.WebhookEndpointInfo, error)
+	SetEndpointDisabled(ctx context.Context, appID int, id uuid.UUID, disabled bool) error
+	DeleteEndpoint(ctx context.Context, appID int, id uuid.UUID) error
+	ListDeliveries(ctx context.Context, params contracts.ListWebhookDeliveriesParams) (contracts.WebhookDeliveryPage, error)
+	GetDelivery(ctx context.Context, id uuid.UUID) (contracts.WebhookDeliveryDetail, error)
+	ReplayDelivery(ctx context.Context, id uuid.UUID) error
+}
+
+type RBACService interface {
+	CreatePermission(ctx context.Context, appID int, params contracts.CreatePermissionParams) (contracts.PermissionInfo, error)
+	ListPermissions(ctx context.Context, appID int) ([]contracts.PermissionInfo, error)
+	DeletePermission(ctx context.Context, appID int, id uuid.UUID) error
+	CreateRole(ctx context.Context, appID int, params contracts.CreateRoleParams) (contracts.RoleInfo, error)
+	ListRoles(ctx context.Context, appID int) ([]contracts.RoleInfo, error)
+	GetRole(ctx context.Context, appID int, id uuid.UUID) (contracts.RoleInfo, error)
+	UpdateRole(ctx context.Context, appID int, id uuid.UUID, params contracts.UpdateRoleParams) (contracts.RoleInfo, error)
+	DeleteRole(ctx context.Context, appID int, id uuid.UUID) error
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]contracts.RoleInfo, error)
+	AssignUserRole(ctx context.Context, userID, roleID uuid.UUID) error
+	UnassignUserRole(ctx context.Context, userID, roleID uuid.UUID) error
+	GetUserAuthorization(ctx context.Context, userID uuid.UUID, appID int) (contracts.AuthorizationInfo, error)
+	CreateGroup(ctx context.Context, params contracts.CreateGroupParams) (contracts.GroupInfo, error)
+	ListGroups(ctx context.Context) ([]contracts.GroupInfo, error)
+	GetGroup(ctx context.Context, id uuid.UUID) (contracts.GroupInfo, error)
+	DeleteGroup(ctx context.Context, id uuid.UUID) error
+	ListGroupMembers(ctx context.Context, groupID uuid.UUID) ([]contracts.UserInfo, error)
+	AddGroupMember(ctx context.Context, groupID, userID uuid.UUID) error
+	RemoveGroupMember(ctx context.Context, groupID, userID uuid.UUID) error
+	ListGroupRoles(ctx context.Context, groupID uuid.UUID) ([]contracts.RoleInfo, error)
+	AssignGroupRole(ctx context.Context, groupID, roleID uuid.UUID) error
+	UnassignGroupRole(ctx context.Context, groupID, roleID uuid.UUID) error
+}
+
+type RelationService interface {
+	ListNamespaces(ctx context.Context, appID int) ([]contracts.RelationNamespaceInfo, error)
+	PutNamespace(ctx context.Context, appID int, name string, config json.RawMessage) (contracts.RelationNamespaceInfo, error)
+	DeleteNamespace(ctx context.Context, appID int, name string) error
+	WriteRelations(ctx context.Context, appID int, params contracts.WriteRelationsParams) (contracts.WriteRelationsResult, error)
+	Check(ctx context.Context, appID int, params contracts.CheckParams) (contracts.CheckResult, error)
+	Expand(ctx context.Context, appID int, params contracts.ExpandParams) (contracts.ExpandResult, error)
+	ListObjects(ctx context.Context, appID int, params contracts.ListObjectsParams) (contracts.ListObjectsResult, error)
+}
+
+type OrgService interface {
+	CreateOrg(ctx context.Context, params contracts.CreateOrgParams) (contracts.OrgInfo, error)
+	ListOrgs(ctx context.Context) ([]contracts.OrgInfo, error)
+	GetOrg(ctx context.Context, id uuid.UUID) (contracts.OrgInfo, error)
+	UpdateOrg(ctx context.Context, id uuid.UUID, params contracts.UpdateOrgParams) (contracts.OrgInfo, error)
+	DeleteOrg(ctx context.Context, id uuid.UUID) error
+	ListMembers(ctx context.Context, orgID uuid.UUID) ([]contracts.OrgMemberInfo, error)
+	AddMember(ctx context.Context, orgID uuid.UUID, params contracts.AddOrgMemberParams) (contracts.OrgMemberInfo, error)
+	UpdateMember(ctx context.Context, orgID, userID uuid.UUID, params contracts.UpdateOrgMemberParams) (contracts.OrgMemberInfo, error)
+	RemoveMember(ctx context.Context, orgID, userID uuid.UUID) error
+	CreateInvitation(ctx context.Context, orgID uuid.UUID, params contracts.CreateOrgInvitationParams) (contracts.OrgInvitationInfo, error)
+	ListInvitations(ctx context.Context, orgID uuid.UUID) ([]contracts.OrgInvitationInfo, error)
+	RevokeInvitation(ctx context.Context, orgID, id uuid.UUID) error
+	AcceptInvitation(ctx context.Context, userID uuid.UUID, token string) (contracts.UserOrgInfo, error)
+	ListUserOrgs(ctx context.Context, userID uuid.UUID) ([]contracts.UserOrgInfo, error)
+}
+
+type SchedulerService interface {
+	ListJobs(ctx context.Context) ([]contracts.JobInfo, error)
+	ListRuns(ctx context.Context, name string, limit int) ([]contracts.JobRunInfo, error)
+	TriggerJob(ctx context.Context, name string) (contracts.JobRunInfo, error)
+}
+
 type ServicesContainer struct {
-	AuthService AuthService
-	RtsService  RefreshTokenService
+	AuthService       AuthService
+	RtsService        RefreshTokenService
+	SessionService    SessionService
+	AppService        AppService
+	UserService       UserService
+	ProfileService    ProfileService
+	AuditService      AuditService
+	WebhookService    WebhookService
+	RBACService       RBACService
+	RelationService   RelationService
+	OrgService        OrgService
+	InvitationService InvitationService
+	SchedulerService  SchedulerService
 }
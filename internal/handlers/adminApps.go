@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/finaptica/sso/internal/contracts"
+)
+
+type AdminAppsHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminAppsHandler(services ServicesContainer) *AdminAppsHandler {
+	return &AdminAppsHandler{services: services}
+}
+
+// POST /admin/apps
+func (h *AdminAppsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req contracts.CreateAppParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	app, err := h.services.AppService.CreateApp(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, app)
+}
+
+// GET /admin/apps
+func (h *AdminAppsHandler) List(w http.ResponseWriter, r *http.Request) {
+	apps, err := h.services.AppService.ListApps(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"apps": apps})
+}
+
+// GET /admin/apps/{appID}
+func (h *AdminAppsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	app, err := h.services.AppService.GetApp(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, app)
+}
+
+// PATCH /admin/apps/{appID}
+func (h *AdminAppsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.UpdateAppParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	app, err := h.services.AppService.UpdateApp(r.Context(), id, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, app)
+}
+
+// POST /admin/apps/{appID}/disable
+func (h *AdminAppsHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+// POST /admin/apps/{appID}/enable
+func (h *AdminAppsHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *AdminAppsHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.AppService.SetAppDisabled(r.Context(), id, disabled); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/apps/{appID}
+func (h *AdminAppsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.AppService.DeleteApp(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/apps/{appID}/secrets
+func (h *AdminAppsHandler) ListSecrets(w http.ResponseWriter, r *http.Request) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	secrets, err := h.services.AppService.ListSecrets(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"secrets": secrets})
+}
+
+// POST /admin/apps/{appID}/secrets/rotate
+func (h *AdminAppsHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req struct {
+		PreviousSecretTTLSeconds int `json:"previous_secret_ttl_seconds"`
+	}
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	rotated, err := h.services.AppService.RotateSecret(r.Context(), id, time.Duration(req.PreviousSecretTTLSeconds)*time.Second)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rotated)
+}
+
+// DELETE /admin/apps/{appID}/secrets/{secretID}
+func (h *AdminAppsHandler) DeleteSecret(w http.ResponseWriter, r *http.Request) {
+	id, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	secretID, err := uuidURLParam(r, "secretID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.AppService.DeleteSecret(r.Context(), id, secretID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
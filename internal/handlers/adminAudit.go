@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/google/uuid"
+)
+
+type AdminAuditHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminAuditHandler(services ServicesContainer) *AdminAuditHandler {
+	return &AdminAuditHandler{services: services}
+}
+
+// GET /admin/audit-events
+func (h *AdminAuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	params, err := listAuditEventsParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page, err := h.services.AuditService.ListEvents(r.Context(), params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func listAuditEventsParams(r *http.Request) (contracts.ListAuditEventsParams, error) {
+	const op = "listAuditEventsParams"
+	q := r.URL.Query()
+
+	params := contracts.ListAuditEventsParams{
+		EventType: q.Get("event_type"),
+		Actor:     q.Get("actor"),
+		Outcome:   q.Get("outcome"),
+		Cursor:    q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("user_id"); v != "" {
+		if params.SubjectUserID, err = uuid.Parse(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid user_id"))
+		}
+	}
+	if v := q.Get("app_id"); v != "" {
+		if params.AppID, err = strconv.Atoi(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid app_id"))
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if params.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("from must be an RFC 3339 timestamp"))
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if params.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("to must be an RFC 3339 timestamp"))
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid limit"))
+		}
+	}
+
+	return params, nil
+}
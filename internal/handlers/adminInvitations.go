@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/finaptica/sso/internal/contracts"
+)
+
+type AdminInvitationsHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminInvitationsHandler(services ServicesContainer) *AdminInvitationsHandler {
+	return &AdminInvitationsHandler{services: services}
+}
+
+// POST /admin/apps/{appID}/invitations
+func (h *AdminInvitationsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.CreateAppInvitationParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	invitation, err := h.services.InvitationService.CreateInvitation(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, invitation)
+}
+
+// GET /admin/apps/{appID}/invitations
+func (h *AdminInvitationsHandler) List(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	invitations, err := h.services.InvitationService.ListInvitations(r.Context(), appID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"invitations": invitations})
+}
+
+// DELETE /admin/apps/{appID}/invitations/{invitationID}
+func (h *AdminInvitationsHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "invitationID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.InvitationService.RevokeInvitation(r.Context(), appID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
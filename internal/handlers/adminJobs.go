@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminJobsHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminJobsHandler(services ServicesContainer) *AdminJobsHandler {
+	return &AdminJobsHandler{services: services}
+}
+
+// GET /admin/jobs
+func (h *AdminJobsHandler) List(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.services.SchedulerService.ListJobs(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": jobs})
+}
+
+// GET /admin/jobs/{job}/runs
+func (h *AdminJobsHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	limit, err := listJobRunsLimit(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	runs, err := h.services.SchedulerService.ListRuns(r.Context(), chi.URLParam(r, "job"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"runs": runs})
+}
+
+// POST /admin/jobs/{job}/run
+func (h *AdminJobsHandler) Trigger(w http.ResponseWriter, r *http.Request) {
+	run, err := h.services.SchedulerService.TriggerJob(r.Context(), chi.URLParam(r, "job"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+func listJobRunsLimit(r *http.Request) (int, error) {
+	const op = "listJobRunsLimit"
+
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, errs.WithKind(op, errs.Invalid, errors.New("invalid limit"))
+	}
+	return limit, nil
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/finaptica/sso/internal/contracts"
+)
+
+type AdminOrgsHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminOrgsHandler(services ServicesContainer) *AdminOrgsHandler {
+	return &AdminOrgsHandler{services: services}
+}
+
+// POST /admin/orgs
+func (h *AdminOrgsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req contracts.CreateOrgParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	org, err := h.services.OrgService.CreateOrg(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, org)
+}
+
+// GET /admin/orgs
+func (h *AdminOrgsHandler) List(w http.ResponseWriter, r *http.Request) {
+	orgs, err := h.services.OrgService.ListOrgs(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"orgs": orgs})
+}
+
+// GET /admin/orgs/{orgID}
+func (h *AdminOrgsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	org, err := h.services.OrgService.GetOrg(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, org)
+}
+
+// PATCH /admin/orgs/{orgID}
+func (h *AdminOrgsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.UpdateOrgParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	org, err := h.services.OrgService.UpdateOrg(r.Context(), id, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, org)
+}
+
+// DELETE /admin/orgs/{orgID}
+func (h *AdminOrgsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.OrgService.DeleteOrg(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
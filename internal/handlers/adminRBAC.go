@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/google/uuid"
+)
+
+type AdminRBACHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminRBACHandler(services ServicesContainer) *AdminRBACHandler {
+	return &AdminRBACHandler{services: services}
+}
+
+// POST /admin/apps/{appID}/permissions
+func (h *AdminRBACHandler) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.CreatePermissionParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	permission, err := h.services.RBACService.CreatePermission(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, permission)
+}
+
+// GET /admin/apps/{appID}/permissions
+func (h *AdminRBACHandler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	permissions, err := h.services.RBACService.ListPermissions(r.Context(), appID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"permissions": permissions})
+}
+
+// DELETE /admin/apps/{appID}/permissions/{permissionID}
+func (h *AdminRBACHandler) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "permissionID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.DeletePermission(r.Context(), appID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /admin/apps/{appID}/roles
+func (h *AdminRBACHandler) CreateRole(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.CreateRoleParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	role, err := h.services.RBACService.CreateRole(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, role)
+}
+
+// GET /admin/apps/{appID}/roles
+func (h *AdminRBACHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	roles, err := h.services.RBACService.ListRoles(r.Context(), appID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"roles": roles})
+}
+
+// GET /admin/apps/{appID}/roles/{roleID}
+func (h *AdminRBACHandler) GetRole(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "roleID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	role, err := h.services.RBACService.GetRole(r.Context(), appID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, role)
+}
+
+// PATCH /admin/apps/{appID}/roles/{roleID}
+func (h *AdminRBACHandler) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "roleID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.UpdateRoleParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	role, err := h.services.RBACService.UpdateRole(r.Context(), appID, id, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, role)
+}
+
+// DELETE /admin/apps/{appID}/roles/{roleID}
+func (h *AdminRBACHandler) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "roleID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.DeleteRole(r.Context(), appID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/users/{userID}/roles
+func (h *AdminRBACHandler) ListUserRoles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	roles, err := h.services.RBACService.ListUserRoles(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"roles": roles})
+}
+
+// POST /admin/users/{userID}/roles
+func (h *AdminRBACHandler) AssignUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.AssignRoleParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.AssignUserRole(r.Context(), userID, req.RoleID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/users/{userID}/roles/{roleID}
+func (h *AdminRBACHandler) UnassignUserRole(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	roleID, err := uuidURLParam(r, "roleID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.UnassignUserRole(r.Context(), userID, roleID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/users/{userID}/authorization?app_id=
+func (h *AdminRBACHandler) GetUserAuthorization(w http.ResponseWriter, r *http.Request) {
+	const op = "GetUserAuthorization"
+
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	appID, err := strconv.Atoi(r.URL.Query().Get("app_id"))
+	if err != nil {
+		writeError(w, errs.WithKind(op, errs.Invalid, errors.New("app_id is required")))
+		return
+	}
+
+	authz, err := h.services.RBACService.GetUserAuthorization(r.Context(), userID, appID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, authz)
+}
+
+// POST /admin/groups
+func (h *AdminRBACHandler) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var req contracts.CreateGroupParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group, err := h.services.RBACService.CreateGroup(r.Context(), req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// GET /admin/groups
+func (h *AdminRBACHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.services.RBACService.ListGroups(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"groups": groups})
+}
+
+// GET /admin/groups/{groupID}
+func (h *AdminRBACHandler) GetGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "groupID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	group, err := h.services.RBACService.GetGroup(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, group)
+}
+
+// DELETE /admin/groups/{groupID}
+func (h *AdminRBACHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "groupID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.DeleteGroup(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/groups/{groupID}/members
+func (h *AdminRBACHandler) ListGroupMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "groupID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	members, err := h.services.RBACService.ListGroupMembers(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"members": members})
+}
+
+// PUT /admin/groups/{groupID}/members/{userID}
+func (h *AdminRBACHandler) AddGroupMember(w http.ResponseWriter, r *http.Request) {
+	groupID, userID, err := groupMemberURLParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.AddGroupMember(r.Context(), groupID, userID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/groups/{groupID}/members/{userID}
+func (h *AdminRBACHandler) RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	groupID, userID, err := groupMemberURLParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.RemoveGroupMember(r.Context(), groupID, userID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func groupMemberURLParams(r *http.Request) (groupID, userID uuid.UUID, err error) {
+	if groupID, err = uuidURLParam(r, "groupID"); err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	if userID, err = uuidURLParam(r, "userID"); err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+	return groupID, userID, nil
+}
+
+// GET /admin/groups/{groupID}/roles
+func (h *AdminRBACHandler) ListGroupRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "groupID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	roles, err := h.services.RBACService.ListGroupRoles(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"roles": roles})
+}
+
+// POST /admin/groups/{groupID}/roles
+func (h *AdminRBACHandler) AssignGroupRole(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuidURLParam(r, "groupID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.AssignRoleParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.AssignGroupRole(r.Context(), groupID, req.RoleID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/groups/{groupID}/roles/{roleID}
+func (h *AdminRBACHandler) UnassignGroupRole(w http.ResponseWriter, r *http.Request) {
+	groupID, err := uuidURLParam(r, "groupID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	roleID, err := uuidURLParam(r, "roleID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RBACService.UnassignGroupRole(r.Context(), groupID, roleID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminRelationsHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminRelationsHandler(services ServicesContainer) *AdminRelationsHandler {
+	return &AdminRelationsHandler{services: services}
+}
+
+// GET /admin/apps/{appID}/relation-namespaces
+func (h *AdminRelationsHandler) ListNamespaces(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	namespaces, err := h.services.RelationService.ListNamespaces(r.Context(), appID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"namespaces": namespaces})
+}
+
+// PUT /admin/apps/{appID}/relation-namespaces/{namespace}
+func (h *AdminRelationsHandler) PutNamespace(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var config json.RawMessage
+	if err := decodeJSON(r, &config); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	namespace, err := h.services.RelationService.PutNamespace(r.Context(), appID, chi.URLParam(r, "namespace"), config)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, namespace)
+}
+
+// DELETE /admin/apps/{appID}/relation-namespaces/{namespace}
+func (h *AdminRelationsHandler) DeleteNamespace(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.RelationService.DeleteNamespace(r.Context(), appID, chi.URLParam(r, "namespace")); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+)
+
+type AdminUsersHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminUsersHandler(services ServicesContainer) *AdminUsersHandler {
+	return &AdminUsersHandler{services: services}
+}
+
+// GET /admin/users
+func (h *AdminUsersHandler) List(w http.ResponseWriter, r *http.Request) {
+	params, err := listUsersParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page, err := h.services.UserService.ListUsers(r.Context(), params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /admin/users/{userID}
+func (h *AdminUsersHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.services.UserService.GetUser(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// PATCH /admin/users/{userID}
+func (h *AdminUsersHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.UpdateUserParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := h.services.UserService.UpdateUser(r.Context(), id, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// POST /admin/users/{userID}/status
+func (h *AdminUsersHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.UserService.SetUserStatus(r.Context(), id, req.Status, req.Reason); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /admin/users/{userID}/disable
+func (h *AdminUsersHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	h.setStatus(w, r, models.UserStatusDisabled)
+}
+
+// POST /admin/users/{userID}/enable
+func (h *AdminUsersHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	h.setStatus(w, r, models.UserStatusActive)
+}
+
+func (h *AdminUsersHandler) setStatus(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	reason, err := optionalReason(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.UserService.SetUserStatus(r.Context(), id, status, reason); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/users/{userID}/status-history
+func (h *AdminUsersHandler) StatusHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	history, err := h.services.UserService.ListStatusHistory(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"transitions": history})
+}
+
+// POST /admin/users/{userID}/force-password-reset
+func (h *AdminUsersHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.UserService.ForcePasswordReset(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/users/{userID}
+//
+// Soft-deletes the user. With ?purge=true the row is removed for good.
+func (h *AdminUsersHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("purge") == "true" {
+		err = h.services.UserService.PurgeUser(r.Context(), id)
+	} else {
+		var reason string
+		if reason, err = optionalReason(r); err == nil {
+			err = h.services.UserService.DeleteUser(r.Context(), id, reason)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// optionalReason reads {"reason": "..."} from the body when one is sent.
+func optionalReason(r *http.Request) (string, error) {
+	if r.ContentLength == 0 {
+		return "", nil
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		return "", err
+	}
+	return req.Reason, nil
+}
+
+func listUsersParams(r *http.Request) (contracts.ListUsersParams, error) {
+	const op = "listUsersParams"
+	q := r.URL.Query()
+
+	params := contracts.ListUsersParams{
+		EmailPrefix: q.Get("email_prefix"),
+		Status:      q.Get("status"),
+		Cursor:      q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("created_from"); v != "" {
+		if params.CreatedFrom, err = time.Parse(time.RFC3339, v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("created_from must be an RFC 3339 timestamp"))
+		}
+	}
+	if v := q.Get("created_to"); v != "" {
+		if params.CreatedTo, err = time.Parse(time.RFC3339, v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("created_to must be an RFC 3339 timestamp"))
+		}
+	}
+	if v := q.Get("app_id"); v != "" {
+		if params.AppID, err = strconv.Atoi(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid app_id"))
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid limit"))
+		}
+	}
+
+	return params, nil
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/google/uuid"
+)
+
+type AdminWebhooksHandler struct {
+	services ServicesContainer
+}
+
+func NewAdminWebhooksHandler(services ServicesContainer) *AdminWebhooksHandler {
+	return &AdminWebhooksHandler{services: services}
+}
+
+// POST /admin/apps/{appID}/webhooks
+func (h *AdminWebhooksHandler) CreateEndpoint(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.CreateWebhookEndpointParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	endpoint, err := h.services.WebhookService.CreateEndpoint(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, endpoint)
+}
+
+// GET /admin/apps/{appID}/webhooks
+func (h *AdminWebhooksHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	endpoints, err := h.services.WebhookService.ListEndpoints(r.Context(), appID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"webhooks": endpoints})
+}
+
+// POST /admin/apps/{appID}/webhooks/{endpointID}/disable
+func (h *AdminWebhooksHandler) DisableEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setEndpointDisabled(w, r, true)
+}
+
+// POST /admin/apps/{appID}/webhooks/{endpointID}/enable
+func (h *AdminWebhooksHandler) EnableEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setEndpointDisabled(w, r, false)
+}
+
+func (h *AdminWebhooksHandler) setEndpointDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "endpointID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.WebhookService.SetEndpointDisabled(r.Context(), appID, id, disabled); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /admin/apps/{appID}/webhooks/{endpointID}
+func (h *AdminWebhooksHandler) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	appID, err := intURLParam(r, "appID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "endpointID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.WebhookService.DeleteEndpoint(r.Context(), appID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /admin/webhook-deliveries
+func (h *AdminWebhooksHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	params, err := listWebhookDeliveriesParams(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	page, err := h.services.WebhookService.ListDeliveries(r.Context(), params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /admin/webhook-deliveries/{deliveryID}
+func (h *AdminWebhooksHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "deliveryID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	delivery, err := h.services.WebhookService.GetDelivery(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, delivery)
+}
+
+// POST /admin/webhook-deliveries/{deliveryID}/replay
+func (h *AdminWebhooksHandler) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := uuidURLParam(r, "deliveryID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.WebhookService.ReplayDelivery(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func listWebhookDeliveriesParams(r *http.Request) (contracts.ListWebhookDeliveriesParams, error) {
+	const op = "listWebhookDeliveriesParams"
+	q := r.URL.Query()
+
+	params := contracts.ListWebhookDeliveriesParams{
+		Status: q.Get("status"),
+		Cursor: q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("endpoint_id"); v != "" {
+		if params.EndpointID, err = uuid.Parse(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid endpoint_id"))
+		}
+	}
+	if v := q.Get("app_id"); v != "" {
+		if params.AppID, err = strconv.Atoi(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid app_id"))
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil {
+			return params, errs.WithKind(op, errs.Invalid, errors.New("invalid limit"))
+		}
+	}
+
+	return params, nil
+}
@@ -1,8 +1,9 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
+
+	"github.com/finaptica/sso/internal/contracts"
 )
 
 type AuthHandler struct {
@@ -15,44 +16,36 @@ func NewAuthHandler(services ServicesContainer) *AuthHandler {
 
 // POST /auth/register
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+	var req contracts.RegisterParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	userId, err := h.services.AuthService.Register(r.Context(), req.Email, req.Password)
+	userId, err := h.services.AuthService.Register(r.Context(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	resp := map[string]any{"user_id": userId}
-	_ = json.NewEncoder(w).Encode(resp)
+	writeJSON(w, http.StatusOK, map[string]any{"user_id": userId})
 }
 
 // POST /auth/login
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-		AppID    int    `json:"app_id"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+	var req contracts.LoginParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	tokens, err := h.services.AuthService.Login(r.Context(), req.Email, req.Password, req.AppID)
+	tokens, err := h.services.AuthService.Login(r.Context(), req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, err)
 		return
 	}
 
-	_ = json.NewEncoder(w).Encode(tokens)
+	writeJSON(w, http.StatusOK, tokens)
 }
 
 // POST /auth/refresh
@@ -60,16 +53,36 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
 		return
 	}
 
 	tokens, err := h.services.RtsService.RefreshTokens(r.Context(), req.RefreshToken)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// POST /auth/password
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email           string `json:"email"`
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.AuthService.ChangePassword(r.Context(), req.Email, req.CurrentPassword, req.NewPassword); err != nil {
+		writeError(w, err)
 		return
 	}
 
-	_ = json.NewEncoder(w).Encode(tokens)
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/finaptica/sso/internal/lib/health"
+)
+
+// DBStats is a snapshot of the database connection pool.
+type DBStats struct {
+	MaxConns      int32 `json:"max_conns"`
+	TotalConns    int32 `json:"total_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	AcquiredConns int32 `json:"acquired_conns"`
+}
+
+type HealthHandler struct {
+	checker   *health.Checker
+	startedAt time.Time
+	dbStats   func() DBStats
+}
+
+func NewHealthHandler(checker *health.Checker, dbStats func() DBStats) *HealthHandler {
+	return &HealthHandler{checker: checker, startedAt: time.Now(), dbStats: dbStats}
+}
+
+// GET /healthz
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": health.StatusOK})
+}
+
+// GET /readyz
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Ready(r.Context())
+
+	checks := make(map[string]string, len(report.Checks))
+	for name, res := range report.Checks {
+		checks[name] = res.Status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != health.StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": report.Status, "checks": checks})
+}
+
+// GET /status
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Ready(r.Context())
+
+	resp := map[string]any{
+		"status":     report.Status,
+		"checks":     report.Checks,
+		"started_at": h.startedAt.UTC(),
+		"uptime":     time.Since(h.startedAt).String(),
+		"go_version": runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"db":         h.dbStats(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/finaptica/sso/internal/lib/token"
+)
+
+type KeySetProvider interface {
+	JWKS() token.JWKS
+}
+
+type JWKSHandler struct {
+	keys KeySetProvider
+}
+
+func NewJWKSHandler(keys KeySetProvider) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// GET /.well-known/jwks.json
+func (h *JWKSHandler) Keys(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, h.keys.JWKS())
+}
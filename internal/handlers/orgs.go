@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/finaptica/sso/internal/contracts"
+)
+
+// OrgsHandler serves organization members and invitations. The same routes
+// are mounted for service admins under /admin/orgs and for the
+// organization's own owners and admins under /orgs; the service decides what
+// the caller may do.
+type OrgsHandler struct {
+	services ServicesContainer
+}
+
+func NewOrgsHandler(services ServicesContainer) *OrgsHandler {
+	return &OrgsHandler{services: services}
+}
+
+// GET /orgs/{orgID}/members
+func (h *OrgsHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	members, err := h.services.OrgService.ListMembers(r.Context(), orgID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"members": members})
+}
+
+// POST /admin/orgs/{orgID}/members
+func (h *OrgsHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.AddOrgMemberParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	member, err := h.services.OrgService.AddMember(r.Context(), orgID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, member)
+}
+
+// PATCH /orgs/{orgID}/members/{userID}
+func (h *OrgsHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.UpdateOrgMemberParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	member, err := h.services.OrgService.UpdateMember(r.Context(), orgID, userID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, member)
+}
+
+// DELETE /orgs/{orgID}/members/{userID}
+func (h *OrgsHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.OrgService.RemoveMember(r.Context(), orgID, userID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /orgs/{orgID}/invitations
+func (h *OrgsHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.CreateOrgInvitationParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	invitation, err := h.services.OrgService.CreateInvitation(r.Context(), orgID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, invitation)
+}
+
+// GET /orgs/{orgID}/invitations
+func (h *OrgsHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	invitations, err := h.services.OrgService.ListInvitations(r.Context(), orgID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"invitations": invitations})
+}
+
+// DELETE /orgs/{orgID}/invitations/{invitationID}
+func (h *OrgsHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuidURLParam(r, "orgID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	id, err := uuidURLParam(r, "invitationID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.OrgService.RevokeInvitation(r.Context(), orgID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /me/orgs
+func (h *OrgsHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	orgs, err := h.services.OrgService.ListUserOrgs(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"orgs": orgs})
+}
+
+// POST /me/org-invitations/accept
+func (h *OrgsHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	org, err := h.services.OrgService.AcceptInvitation(r.Context(), userID, req.Token)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, org)
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"github.com/google/uuid"
+)
+
+// avatarFormField is the multipart field an avatar is uploaded in.
+const avatarFormField = "avatar"
+
+type ProfileHandler struct {
+	services ServicesContainer
+}
+
+func NewProfileHandler(services ServicesContainer) *ProfileHandler {
+	return &ProfileHandler{services: services}
+}
+
+// GET /me
+func (h *ProfileHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	profile, err := h.services.ProfileService.GetProfile(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// PATCH /me
+func (h *ProfileHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.UpdateUserParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	profile, err := h.services.ProfileService.UpdateProfile(r.Context(), userID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// PUT /me/avatar
+//
+// Accepts either a multipart form with an "avatar" file field or the raw
+// image as the request body.
+func (h *ProfileHandler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	body, err := avatarBody(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	profile, err := h.services.ProfileService.UploadAvatar(r.Context(), userID, body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// GET /me/avatar
+func (h *ProfileHandler) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	rc, info, err := h.services.ProfileService.GetAvatar(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", info.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, rc)
+}
+
+// DELETE /me/avatar
+func (h *ProfileHandler) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.ProfileService.DeleteAvatar(r.Context(), userID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /me/email
+func (h *ProfileHandler) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req struct {
+		NewEmail        string `json:"new_email"`
+		CurrentPassword string `json:"current_password"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.ProfileService.RequestEmailChange(r.Context(), userID, req.NewEmail, req.CurrentPassword); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// POST /auth/email/confirm
+func (h *ProfileHandler) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.ProfileService.ConfirmEmailChange(r.Context(), req.Token); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// avatarBody returns the uploaded image, streaming it out of a multipart
+// form when one is sent.
+func avatarBody(r *http.Request) (io.Reader, error) {
+	const op = "avatarBody"
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, errs.WithKind(op, errs.Invalid, errors.New("invalid multipart body"))
+	}
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return nil, errs.WithKind(op, errs.Invalid, errors.New("missing avatar field"))
+		}
+		if part.FormName() == avatarFormField {
+			return part, nil
+		}
+	}
+}
+
+func currentUserID(r *http.Request) (uuid.UUID, error) {
+	id, ok := reqctx.User(r.Context())
+	if !ok {
+		return uuid.UUID{}, errs.WithKind("currentUserID", errs.Unauthenticated, errors.New("no authenticated user"))
+	}
+	return id, nil
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+)
+
+// RelationsHandler serves the relationship API to apps authenticated by
+// client credentials. Every call acts on the caller's own tuples.
+type RelationsHandler struct {
+	services ServicesContainer
+}
+
+func NewRelationsHandler(services ServicesContainer) *RelationsHandler {
+	return &RelationsHandler{services: services}
+}
+
+// POST /relations/write
+func (h *RelationsHandler) Write(w http.ResponseWriter, r *http.Request) {
+	appID, err := clientApp(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.WriteRelationsParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result, err := h.services.RelationService.WriteRelations(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// POST /relations/check
+func (h *RelationsHandler) Check(w http.ResponseWriter, r *http.Request) {
+	appID, err := clientApp(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.CheckParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result, err := h.services.RelationService.Check(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// POST /relations/expand
+func (h *RelationsHandler) Expand(w http.ResponseWriter, r *http.Request) {
+	appID, err := clientApp(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.ExpandParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result, err := h.services.RelationService.Expand(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// POST /relations/list-objects
+func (h *RelationsHandler) ListObjects(w http.ResponseWriter, r *http.Request) {
+	appID, err := clientApp(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var req contracts.ListObjectsParams
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	result, err := h.services.RelationService.ListObjects(r.Context(), appID, req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func clientApp(r *http.Request) (int, error) {
+	appID, ok := reqctx.ClientApp(r.Context())
+	if !ok {
+		return 0, errs.WithKind("clientApp", errs.Unauthenticated, errors.New("no authenticated app"))
+	}
+	return appID, nil
+}
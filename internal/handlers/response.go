@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError maps err to an HTTP status by its errs.Kind. Only validation
+// errors expose their cause to the client.
+func writeError(w http.ResponseWriter, err error) {
+	resp := map[string]string{"error": string(errs.KindOf(err))}
+	if errs.KindOf(err) == errs.Invalid {
+		resp["message"] = rootCause(err).Error()
+	}
+
+	writeJSON(w, errs.ToHTTPStatus(err), resp)
+}
+
+func rootCause(err error) error {
+	for {
+		var e *errs.E
+		if !errors.As(err, &e) || e.Err == nil {
+			return err
+		}
+		err = e.Err
+	}
+}
+
+func decodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errs.WithKind("decodeJSON", errs.Invalid, errors.New("invalid request body"))
+	}
+	return nil
+}
+
+func intURLParam(r *http.Request, name string) (int, error) {
+	v, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil {
+		return 0, errs.WithKind("intURLParam", errs.Invalid, errors.New("invalid "+name))
+	}
+	return v, nil
+}
+
+func uuidURLParam(r *http.Request, name string) (uuid.UUID, error) {
+	v, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		return uuid.UUID{}, errs.WithKind("uuidURLParam", errs.Invalid, errors.New("invalid "+name))
+	}
+	return v, nil
+}
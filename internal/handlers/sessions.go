@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// SessionsHandler serves the signed-in user's own sessions under /me and
+// anyone's sessions to admins under /admin/users.
+type SessionsHandler struct {
+	services ServicesContainer
+}
+
+func NewSessionsHandler(services ServicesContainer) *SessionsHandler {
+	return &SessionsHandler{services: services}
+}
+
+// GET /me/sessions
+func (h *SessionsHandler) ListMine(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.list(w, r, userID)
+}
+
+// DELETE /me/sessions/{sessionID}
+func (h *SessionsHandler) RevokeMine(w http.ResponseWriter, r *http.Request) {
+	userID, err := currentUserID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.revoke(w, r, userID)
+}
+
+// GET /admin/users/{userID}/sessions
+func (h *SessionsHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.list(w, r, userID)
+}
+
+// DELETE /admin/users/{userID}/sessions/{sessionID}
+func (h *SessionsHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	h.revoke(w, r, userID)
+}
+
+// DELETE /admin/users/{userID}/sessions
+func (h *SessionsHandler) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuidURLParam(r, "userID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.SessionService.RevokeAllSessions(r.Context(), userID); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *SessionsHandler) list(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	sessions, err := h.services.SessionService.ListSessions(r.Context(), userID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": sessions})
+}
+
+func (h *SessionsHandler) revoke(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	id, err := uuidURLParam(r, "sessionID")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if err := h.services.SessionService.RevokeSession(r.Context(), userID, id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
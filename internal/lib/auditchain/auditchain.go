@@ -0,0 +1,221 @@
+// Package auditchain links audit events into a hash chain and checks it.
+//
+// Every event's hash covers its content and the hash of the event before it,
+// so altering, removing or reordering a stored event breaks every link after
+// it. Signed checkpoints pin the head of the chain at a point in time, which
+// also exposes events cut off the end.
+package auditchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+// version is mixed into every hash so the encoding can change later without
+// old and new hashes being confused.
+const version = "sso-audit-v1"
+
+// Genesis is the prev hash of the first chained event.
+var Genesis = make([]byte, sha256.Size)
+
+// Timestamp returns t at the precision Postgres stores, so the hash computed
+// before the insert matches the one recomputed from the stored row.
+func Timestamp(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// canonicalEvent fixes the field order of the hashed encoding.
+type canonicalEvent struct {
+	Version       string          `json:"v"`
+	OccurredAt    string          `json:"occurred_at"`
+	EventType     string          `json:"event_type"`
+	Actor         string          `json:"actor"`
+	SubjectUserID *uuid.UUID      `json:"subject_user_id"`
+	AppID         *int            `json:"app_id"`
+	IP            string          `json:"ip"`
+	UserAgent     string          `json:"user_agent"`
+	RequestID     string          `json:"request_id"`
+	Outcome       string          `json:"outcome"`
+	Reason        string          `json:"reason"`
+	Details       json.RawMessage `json:"details"`
+}
+
+// Hash returns the chain hash of e following prev. ID, PrevHash and Hash of e
+// are not part of the hashed content.
+func Hash(prev []byte, e models.AuditEvent) ([]byte, error) {
+	details, err := canonicalDetails(e.Details)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.Marshal(canonicalEvent{
+		Version:       version,
+		OccurredAt:    Timestamp(e.OccurredAt).Format(time.RFC3339Nano),
+		EventType:     e.EventType,
+		Actor:         e.Actor,
+		SubjectUserID: e.SubjectUserID,
+		AppID:         e.AppID,
+		IP:            e.IP,
+		UserAgent:     e.UserAgent,
+		RequestID:     e.RequestID,
+		Outcome:       e.Outcome,
+		Reason:        e.Reason,
+		Details:       details,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	h.Write(prev)
+	h.Write(content)
+	return h.Sum(nil), nil
+}
+
+// canonicalDetails encodes details the same way whether they come straight
+// from a service or back out of a JSONB column: keys sorted and numbers kept
+// as written instead of going through float64.
+func canonicalDetails(details map[string]any) (json.RawMessage, error) {
+	if len(details) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+// CheckpointMessage is the byte string a checkpoint signature covers.
+func CheckpointMessage(eventID int64, eventHash []byte, createdAt time.Time) []byte {
+	return []byte(version + "/checkpoint:" +
+		strconv.FormatInt(eventID, 10) + ":" +
+		hex.EncodeToString(eventHash) + ":" +
+		Timestamp(createdAt).Format(time.RFC3339Nano))
+}
+
+// BrokenLinkError reports the first event at which verification failed.
+type BrokenLinkError struct {
+	EventID int64
+	Reason  string
+}
+
+func (e *BrokenLinkError) Error() string {
+	return fmt.Sprintf("audit chain broken at event %d: %s", e.EventID, e.Reason)
+}
+
+// VerifySignature checks a checkpoint signature made with the key keyID.
+type VerifySignature func(keyID string, msg, sig []byte) error
+
+// Verifier walks a contiguous run of events in ID order and checks every
+// link, along with the checkpoints that fall inside the run.
+type Verifier struct {
+	prev        []byte
+	lastID      int64
+	checkpoints []models.AuditCheckpoint
+	verifySig   VerifySignature
+
+	// Events counts events checked, Unchained those written before the
+	// chain existed.
+	Events    int
+	Unchained int
+	// Checkpoints counts checkpoints whose signature and hash matched.
+	Checkpoints int
+}
+
+// NewVerifier starts from prev, the hash of the event just before the run,
+// or nil when that event does not exist or predates the chain. checkpoints
+// must be sorted by EventID.
+func NewVerifier(prev []byte, checkpoints []models.AuditCheckpoint, verifySig VerifySignature) *Verifier {
+	return &Verifier{prev: prev, checkpoints: checkpoints, verifySig: verifySig}
+}
+
+// Next checks e, which must follow the previously checked event.
+func (v *Verifier) Next(e models.AuditEvent) error {
+	if err := v.checkMissedCheckpoints(e.ID); err != nil {
+		return err
+	}
+	v.lastID = e.ID
+
+	if e.Hash == nil {
+		if v.prev != nil {
+			return &BrokenLinkError{EventID: e.ID, Reason: "hash missing after the chain started"}
+		}
+		if e.PrevHash != nil {
+			return &BrokenLinkError{EventID: e.ID, Reason: "prev_hash set without a hash"}
+		}
+		v.Unchained++
+		return v.checkCheckpoint(e)
+	}
+
+	expectedPrev := v.prev
+	if expectedPrev == nil {
+		expectedPrev = Genesis
+	}
+	if !bytes.Equal(e.PrevHash, expectedPrev) {
+		return &BrokenLinkError{EventID: e.ID, Reason: "prev_hash does not match the preceding event"}
+	}
+
+	sum, err := Hash(e.PrevHash, e)
+	if err != nil {
+		return &BrokenLinkError{EventID: e.ID, Reason: "content cannot be encoded: " + err.Error()}
+	}
+	if !bytes.Equal(sum, e.Hash) {
+		return &BrokenLinkError{EventID: e.ID, Reason: "content does not match its hash"}
+	}
+
+	v.prev = e.Hash
+	v.Events++
+	return v.checkCheckpoint(e)
+}
+
+// Finish reports checkpoints that name events the run never reached, such as
+// events cut off the end of the chain.
+func (v *Verifier) Finish() error {
+	return v.checkMissedCheckpoints(math.MaxInt64)
+}
+
+func (v *Verifier) checkMissedCheckpoints(id int64) error {
+	if len(v.checkpoints) > 0 && v.checkpoints[0].EventID < id {
+		cp := v.checkpoints[0]
+		return &BrokenLinkError{EventID: cp.EventID, Reason: fmt.Sprintf("event named by checkpoint %d is missing", cp.ID)}
+	}
+	return nil
+}
+
+func (v *Verifier) checkCheckpoint(e models.AuditEvent) error {
+	if len(v.checkpoints) == 0 || v.checkpoints[0].EventID != e.ID {
+		return nil
+	}
+	cp := v.checkpoints[0]
+	v.checkpoints = v.checkpoints[1:]
+
+	if !bytes.Equal(cp.EventHash, e.Hash) {
+		return &BrokenLinkError{EventID: e.ID, Reason: fmt.Sprintf("hash differs from checkpoint %d", cp.ID)}
+	}
+	msg := CheckpointMessage(cp.EventID, cp.EventHash, cp.CreatedAt)
+	if err := v.verifySig(cp.KeyID, msg, cp.Signature); err != nil {
+		return &BrokenLinkError{EventID: e.ID, Reason: fmt.Sprintf("checkpoint %d signature: %s", cp.ID, err)}
+	}
+
+	v.Checkpoints++
+	return nil
+}
@@ -0,0 +1,365 @@
+package auditchain
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/google/uuid"
+)
+
+var baseTime = time.Date(2026, 1, 1, 12, 0, 0, 123456789, time.UTC)
+
+func event(id int64, details map[string]any) models.AuditEvent {
+	appID := 7
+	subject := uuid.MustParse("5b0c8e52-2d5b-4f4a-9a51-0d3c1f6b9a10")
+	return models.AuditEvent{
+		ID:            id,
+		OccurredAt:    baseTime.Add(time.Duration(id) * time.Second),
+		EventType:     models.AuditLogin,
+		Actor:         "user",
+		SubjectUserID: &subject,
+		AppID:         &appID,
+		IP:            "203.0.113.7",
+		UserAgent:     "test",
+		RequestID:     "req-1",
+		Outcome:       models.AuditOutcomeSuccess,
+		Details:       details,
+	}
+}
+
+// chain links events onto prev the way the audit repository does.
+func chain(t *testing.T, prev []byte, events ...models.AuditEvent) []models.AuditEvent {
+	t.Helper()
+
+	if prev == nil {
+		prev = Genesis
+	}
+	out := make([]models.AuditEvent, len(events))
+	for i, e := range events {
+		e.PrevHash = prev
+		hash, err := Hash(prev, e)
+		if err != nil {
+			t.Fatalf("Hash(event %d): %v", e.ID, err)
+		}
+		e.Hash = hash
+		prev = hash
+		out[i] = e
+	}
+	return out
+}
+
+func verify(prev []byte, events []models.AuditEvent, checkpoints []models.AuditCheckpoint, verifySig VerifySignature) (*Verifier, error) {
+	if verifySig == nil {
+		verifySig = func(string, []byte, []byte) error { return nil }
+	}
+	v := NewVerifier(prev, checkpoints, verifySig)
+	for _, e := range events {
+		if err := v.Next(e); err != nil {
+			return v, err
+		}
+	}
+	return v, v.Finish()
+}
+
+func TestHashStableAcrossStorage(t *testing.T) {
+	details := map[string]any{
+		"zeta":    "last",
+		"alpha":   1,
+		"big":     int64(9007199254740993),
+		"ratio":   0.25,
+		"ids":     []string{"b", "a"},
+		"nested":  map[string]any{"y": true, "x": nil},
+		"deleted": int64(3),
+	}
+	want, err := Hash(Genesis, event(1, details))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := event(1, nil)
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&stored.Details); err != nil {
+		t.Fatal(err)
+	}
+	// Postgres keeps microseconds only.
+	stored.OccurredAt = Timestamp(stored.OccurredAt).In(time.FixedZone("UTC+3", 3*3600))
+
+	got, err := Hash(Genesis, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("hash changed after a round trip of details through json.Number")
+	}
+}
+
+func TestHashStableAcrossFloatDecoding(t *testing.T) {
+	// JSONB columns scan into float64 numbers; values below 2^53 must hash
+	// as the ints they were written as.
+	details := map[string]any{"count": 42, "revoked": int64(1 << 40), "ratio": 0.5}
+	want, err := Hash(Genesis, event(1, details))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := json.Marshal(details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := event(1, nil)
+	if err := json.Unmarshal(raw, &stored.Details); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Hash(Genesis, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("hash changed after a round trip of details through float64")
+	}
+}
+
+func TestHashEmptyDetails(t *testing.T) {
+	a, err := Hash(Genesis, event(1, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Hash(Genesis, event(1, map[string]any{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Fatal("nil and empty details hash differently")
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	events := chain(t, nil,
+		event(1, map[string]any{"n": 1}),
+		event(2, map[string]any{"n": 2}),
+		event(3, map[string]any{"n": 3}),
+	)
+
+	tests := []struct {
+		name   string
+		events func() []models.AuditEvent
+		// reason is part of the expected BrokenLinkError, empty when the
+		// chain must verify.
+		reason  string
+		eventID int64
+	}{
+		{
+			name:   "intact",
+			events: func() []models.AuditEvent { return events },
+		},
+		{
+			name: "tampered field",
+			events: func() []models.AuditEvent {
+				tampered := append([]models.AuditEvent(nil), events...)
+				tampered[1].Outcome = models.AuditOutcomeFailure
+				return tampered
+			},
+			reason:  "content does not match its hash",
+			eventID: 2,
+		},
+		{
+			name: "tampered details",
+			events: func() []models.AuditEvent {
+				tampered := append([]models.AuditEvent(nil), events...)
+				tampered[1].Details = map[string]any{"n": 20}
+				return tampered
+			},
+			reason:  "content does not match its hash",
+			eventID: 2,
+		},
+		{
+			name: "reordered",
+			events: func() []models.AuditEvent {
+				return []models.AuditEvent{events[0], events[2], events[1]}
+			},
+			reason:  "prev_hash does not match the preceding event",
+			eventID: 3,
+		},
+		{
+			name: "removed",
+			events: func() []models.AuditEvent {
+				return []models.AuditEvent{events[0], events[2]}
+			},
+			reason:  "prev_hash does not match the preceding event",
+			eventID: 3,
+		},
+		{
+			name: "removed and relinked",
+			events: func() []models.AuditEvent {
+				relinked := events[2]
+				relinked.PrevHash = events[0].Hash
+				return []models.AuditEvent{events[0], relinked}
+			},
+			reason:  "content does not match its hash",
+			eventID: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verify(nil, tt.events(), nil, nil)
+			checkBroken(t, err, tt.eventID, tt.reason)
+		})
+	}
+}
+
+func TestVerifierCheckpoints(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifySig := func(keyID string, msg, sig []byte) error {
+		if keyID != "k1" || !ed25519.Verify(pub, msg, sig) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	events := chain(t, nil, event(1, nil), event(2, nil), event(3, nil))
+	checkpoint := func(id int64, e models.AuditEvent) models.AuditCheckpoint {
+		createdAt := baseTime.Add(time.Hour)
+		return models.AuditCheckpoint{
+			ID:        id,
+			EventID:   e.ID,
+			EventHash: e.Hash,
+			CreatedAt: createdAt,
+			KeyID:     "k1",
+			Signature: ed25519.Sign(priv, CheckpointMessage(e.ID, e.Hash, createdAt)),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		events      []models.AuditEvent
+		checkpoints func() []models.AuditCheckpoint
+		reason      string
+		eventID     int64
+	}{
+		{
+			name:        "valid",
+			events:      events,
+			checkpoints: func() []models.AuditCheckpoint { return []models.AuditCheckpoint{checkpoint(1, events[1])} },
+		},
+		{
+			name:        "missing checkpoint event",
+			events:      []models.AuditEvent{events[0], events[2]},
+			checkpoints: func() []models.AuditCheckpoint { return []models.AuditCheckpoint{checkpoint(1, events[1])} },
+			reason:      "event named by checkpoint 1 is missing",
+			eventID:     2,
+		},
+		{
+			name:        "checkpoint past the end",
+			events:      events[:2],
+			checkpoints: func() []models.AuditCheckpoint { return []models.AuditCheckpoint{checkpoint(1, events[2])} },
+			reason:      "event named by checkpoint 1 is missing",
+			eventID:     3,
+		},
+		{
+			name:   "hash differs",
+			events: events,
+			checkpoints: func() []models.AuditCheckpoint {
+				cp := checkpoint(1, events[1])
+				cp.EventHash = events[0].Hash
+				return []models.AuditCheckpoint{cp}
+			},
+			reason:  "hash differs from checkpoint 1",
+			eventID: 2,
+		},
+		{
+			name:   "bad signature",
+			events: events,
+			checkpoints: func() []models.AuditCheckpoint {
+				cp := checkpoint(1, events[1])
+				cp.CreatedAt = cp.CreatedAt.Add(time.Second)
+				return []models.AuditCheckpoint{cp}
+			},
+			reason:  "checkpoint 1 signature",
+			eventID: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := verify(nil, tt.events, tt.checkpoints(), verifySig)
+			checkBroken(t, err, tt.eventID, tt.reason)
+			if tt.reason == "" && v.Checkpoints != 1 {
+				t.Fatalf("Checkpoints = %d, want 1", v.Checkpoints)
+			}
+		})
+	}
+}
+
+func TestVerifierUnchainedPrefix(t *testing.T) {
+	legacy := []models.AuditEvent{event(1, nil), event(2, nil)}
+	chained := chain(t, nil, event(3, nil), event(4, nil))
+
+	t.Run("unchained then chained", func(t *testing.T) {
+		v, err := verify(nil, append(append([]models.AuditEvent(nil), legacy...), chained...), nil, nil)
+		checkBroken(t, err, 0, "")
+		if v.Unchained != 2 || v.Events != 2 {
+			t.Fatalf("Unchained, Events = %d, %d, want 2, 2", v.Unchained, v.Events)
+		}
+	})
+
+	t.Run("first chained event not on genesis", func(t *testing.T) {
+		first := chain(t, []byte("not genesis"), event(3, nil))
+		_, err := verify(nil, append(append([]models.AuditEvent(nil), legacy...), first...), nil, nil)
+		checkBroken(t, err, 3, "prev_hash does not match the preceding event")
+	})
+
+	t.Run("unchained after chained", func(t *testing.T) {
+		_, err := verify(nil, append(append([]models.AuditEvent(nil), chained...), event(5, nil)), nil, nil)
+		checkBroken(t, err, 5, "hash missing after the chain started")
+	})
+
+	t.Run("prev hash without hash", func(t *testing.T) {
+		forged := event(2, nil)
+		forged.PrevHash = Genesis
+		_, err := verify(nil, []models.AuditEvent{legacy[0], forged}, nil, nil)
+		checkBroken(t, err, 2, "prev_hash set without a hash")
+	})
+
+	t.Run("run starting mid chain", func(t *testing.T) {
+		v, err := verify(chained[0].Hash, chained[1:], nil, nil)
+		checkBroken(t, err, 0, "")
+		if v.Events != 1 {
+			t.Fatalf("Events = %d, want 1", v.Events)
+		}
+	})
+}
+
+func checkBroken(t *testing.T, err error, eventID int64, reason string) {
+	t.Helper()
+
+	if reason == "" {
+		if err != nil {
+			t.Fatalf("verification failed: %v", err)
+		}
+		return
+	}
+
+	var broken *BrokenLinkError
+	if !errors.As(err, &broken) {
+		t.Fatalf("err = %v, want a BrokenLinkError", err)
+	}
+	if broken.EventID != eventID || !strings.Contains(broken.Reason, reason) {
+		t.Fatalf("broken at %d: %q, want %d: %q", broken.EventID, broken.Reason, eventID, reason)
+	}
+}
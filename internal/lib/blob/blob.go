@@ -0,0 +1,27 @@
+// Package blob stores opaque objects, such as avatar images, by key.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no object is stored under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is implemented by every blob backend.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any object
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, Info, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+type Info struct {
+	Size        int64
+	ContentType string
+}
@@ -0,0 +1,97 @@
+// Package clientauth authenticates apps that call the service with their
+// client ID and secret.
+package clientauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verifier checks a secret against the app's stored secret hashes.
+type Verifier interface {
+	VerifySecret(ctx context.Context, appID int, secret string) error
+}
+
+// MaxTTL bounds how long a verification is cached. Nothing evicts an entry
+// when its secret is deleted or expires or its app is disabled, possibly on
+// another replica, so that change takes up to the TTL to apply.
+const MaxTTL = 5 * time.Second
+
+// Cache remembers verified credentials for ttl, since every secret check is
+// a bcrypt comparison. Only successful checks are cached.
+type Cache struct {
+	verifier Verifier
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	verified map[[sha256.Size]byte]time.Time
+}
+
+// NewCache returns a cache over verifier. A ttl above MaxTTL is lowered to
+// it.
+func NewCache(verifier Verifier, ttl time.Duration) *Cache {
+	ttl = min(ttl, MaxTTL)
+	return &Cache{verifier: verifier, ttl: ttl, verified: make(map[[sha256.Size]byte]time.Time)}
+}
+
+// Verify authenticates appID by secret.
+func (c *Cache) Verify(ctx context.Context, appID int, secret string) error {
+	if c.ttl <= 0 {
+		return c.verifier.VerifySecret(ctx, appID, secret)
+	}
+
+	key := sha256.Sum256([]byte(strconv.Itoa(appID) + ":" + secret))
+	now := time.Now()
+
+	c.mu.Lock()
+	expiresAt, ok := c.verified[key]
+	c.mu.Unlock()
+	if ok && now.Before(expiresAt) {
+		return nil
+	}
+
+	if err := c.verifier.VerifySecret(ctx, appID, secret); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	for k, exp := range c.verified {
+		if !now.Before(exp) {
+			delete(c.verified, k)
+		}
+	}
+	c.verified[key] = now.Add(c.ttl)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// ParseBasic reads an Authorization header of the form
+// "Basic base64(app_id:secret)".
+func ParseBasic(header string) (appID int, secret string, ok bool) {
+	const prefix = "Basic "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return 0, "", false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return 0, "", false
+	}
+
+	id, secret, ok := strings.Cut(string(raw), ":")
+	if !ok || secret == "" {
+		return 0, "", false
+	}
+	appID, err = strconv.Atoi(id)
+	if err != nil {
+		return 0, "", false
+	}
+
+	return appID, secret, true
+}
@@ -0,0 +1,60 @@
+// Package emailaddr normalizes email addresses so that one mailbox maps to one
+// account regardless of how the address was typed.
+package emailaddr
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength matches the users.email column.
+const MaxLength = 255
+
+// Local-part handling modes.
+const (
+	// LocalPartPreserve keeps the local part as typed. Addresses still
+	// compare case-insensitively in the database.
+	LocalPartPreserve = "preserve"
+	// LocalPartLowercase stores the local part lowercased.
+	LocalPartLowercase = "lowercase"
+)
+
+var ErrInvalid = errors.New("invalid email address")
+
+type Normalizer struct {
+	lowercaseLocal bool
+}
+
+// NewNormalizer returns a Normalizer for the given local-part mode. Unknown
+// modes fall back to LocalPartPreserve.
+func NewNormalizer(localPart string) *Normalizer {
+	return &Normalizer{lowercaseLocal: localPart == LocalPartLowercase}
+}
+
+// Normalize trims v, converts it to Unicode NFC and lowercases the domain,
+// and the local part when configured to. It fails unless the result is a
+// single bare address.
+func (n *Normalizer) Normalize(v string) (string, error) {
+	v = norm.NFC.String(strings.TrimSpace(v))
+
+	at := strings.LastIndexByte(v, '@')
+	if at <= 0 || at == len(v)-1 {
+		return "", ErrInvalid
+	}
+
+	local, domain := v[:at], strings.ToLower(v[at+1:])
+	if n.lowercaseLocal {
+		local = strings.ToLower(local)
+	}
+	v = local + "@" + domain
+
+	addr, err := mail.ParseAddress(v)
+	if err != nil || addr.Address != v || len(v) > MaxLength {
+		return "", ErrInvalid
+	}
+
+	return v, nil
+}
@@ -0,0 +1,42 @@
+package slogctx
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler adds request-scoped values carried by the context, the request ID
+// and the active trace and span IDs, to every record passed to the *Context
+// logging methods.
+type Handler struct {
+	slog.Handler
+}
+
+func NewHandler(h slog.Handler) *Handler {
+	return &Handler{Handler: h}
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := reqctx.RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &Handler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{Handler: h.Handler.WithGroup(name)}
+}
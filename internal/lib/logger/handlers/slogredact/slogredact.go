@@ -0,0 +1,111 @@
+package slogredact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+)
+
+const (
+	// ModeMask replaces sensitive values with a fixed placeholder.
+	ModeMask = "mask"
+	// ModeHash replaces sensitive values with a keyed hash so records about
+	// the same subject can still be correlated.
+	ModeHash = "hash"
+)
+
+const maskedValue = "[REDACTED]"
+
+type Options struct {
+	// Keys are the attribute keys to redact, matched case-insensitively at
+	// any group depth.
+	Keys    []string
+	Mode    string
+	HashKey []byte
+}
+
+// Handler redacts sensitive attributes before passing records on to the
+// wrapped handler.
+type Handler struct {
+	next    slog.Handler
+	keys    map[string]struct{}
+	mode    string
+	hashKey []byte
+}
+
+// NewHandler returns a Handler redacting opts.Keys. ModeHash without a
+// HashKey falls back to ModeMask: an unkeyed hash of an email address is
+// recovered by hashing candidate addresses.
+func NewHandler(next slog.Handler, opts Options) *Handler {
+	keys := make(map[string]struct{}, len(opts.Keys))
+	for _, k := range opts.Keys {
+		keys[strings.ToLower(strings.TrimSpace(k))] = struct{}{}
+	}
+
+	mode := opts.Mode
+	if mode == ModeHash && len(opts.HashKey) == 0 {
+		mode = ModeMask
+	}
+
+	return &Handler{next: next, keys: keys, mode: mode, hashKey: opts.HashKey}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+
+	return &Handler{next: h.next.WithAttrs(redacted), keys: h.keys, mode: h.mode, hashKey: h.hashKey}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), keys: h.keys, mode: h.mode, hashKey: h.hashKey}
+}
+
+func (h *Handler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+
+	if _, ok := h.keys[strings.ToLower(a.Key)]; !ok {
+		return a
+	}
+
+	if h.mode == ModeHash {
+		return slog.String(a.Key, h.hash(a.Value.String()))
+	}
+
+	return slog.String(a.Key, maskedValue)
+}
+
+func (h *Handler) hash(v string) string {
+	mac := hmac.New(sha256.New, h.hashKey)
+	mac.Write([]byte(v))
+
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
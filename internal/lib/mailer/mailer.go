@@ -0,0 +1,157 @@
+// Package mailer sends plain text transactional email.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// LogMailer writes messages to the log instead of sending them. It is meant
+// for local development, where following links from the log is enough; the
+// bodies it logs carry live tokens.
+type LogMailer struct {
+	log *slog.Logger
+}
+
+func NewLogMailer(log *slog.Logger) *LogMailer {
+	return &LogMailer{log: log}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.log.InfoContext(ctx, "email not sent, logging instead",
+		slog.String("email", msg.To),
+		slog.String("subject", msg.Subject),
+		slog.String("body", msg.Body),
+	)
+	return nil
+}
+
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// Timeout bounds a delivery when the context has no sooner deadline.
+	Timeout time.Duration
+}
+
+// SMTPMailer relays messages through an SMTP server, upgrading to TLS when
+// the server offers STARTTLS.
+type SMTPMailer struct {
+	opts SMTPOptions
+}
+
+func NewSMTPMailer(opts SMTPOptions) (*SMTPMailer, error) {
+	if opts.Host == "" || opts.From == "" {
+		return nil, fmt.Errorf("smtp host and from address are required")
+	}
+	return &SMTPMailer{opts: opts}, nil
+}
+
+// Send delivers msg within ctx and the configured timeout, so a slow or
+// unreachable server cannot hold the caller up indefinitely.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	if m.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.opts.Timeout)
+		defer cancel()
+	}
+
+	if err := m.send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// send does what smtp.SendMail does over a connection bound to ctx.
+func (m *SMTPMailer) send(ctx context.Context, msg Message) error {
+	addr := net.JoinHostPort(m.opts.Host, strconv.Itoa(m.opts.Port))
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	// Cancellation without a deadline still unblocks the exchange.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, m.opts.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.opts.Host}); err != nil {
+			return err
+		}
+	}
+	if m.opts.Username != "" {
+		auth := smtp.PlainAuth("", m.opts.Username, m.opts.Password, m.opts.Host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.opts.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.render(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (m *SMTPMailer) render(msg Message) []byte {
+	var b bytes.Buffer
+	header := func(k, v string) {
+		// Header values must not smuggle extra headers in.
+		v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+		fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+	}
+
+	header("From", m.opts.From)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return b.Bytes()
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Labels must stay low-cardinality: never put emails, user IDs or token
+// values into them.
+
+const namespace = "sso"
+
+const OutcomeSuccess = "success"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and chi route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	AuthOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_operations_total",
+		Help:      "Login, register and refresh outcomes by error kind and app.",
+	}, []string{"operation", "outcome", "app_id"})
+
+	BcryptDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "bcrypt_duration_seconds",
+		Help:      "Time spent hashing and comparing passwords.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2},
+	}, []string{"operation"})
+
+	RefreshRotations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refresh_token_rotations_total",
+		Help:      "Refresh tokens rotated.",
+	})
+
+	RefreshGraceReplays = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refresh_token_grace_replays_total",
+		Help:      "Rotated refresh tokens answered with their successor within the grace period.",
+	})
+
+	RefreshTokenReuses = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "refresh_token_reuses_total",
+		Help:      "Rotated refresh tokens presented after the grace period, ending their session.",
+	})
+
+	TxRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "uow_transaction_retries_total",
+		Help:      "UnitOfWork transactions retried after a serialization failure.",
+	})
+
+	AuditWriteFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_write_failures_total",
+		Help:      "Audit events that could not be stored.",
+	})
+
+	WebhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_attempts_total",
+		Help:      "Webhook delivery attempts by result: delivered, retry or dead.",
+	}, []string{"result"})
+
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Background job runs by job and result: succeeded, failed or skipped because the job was already running.",
+	}, []string{"job", "result"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Time taken by background job runs by job.",
+		Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+	}, []string{"job"})
+
+	SchedulerLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_leader",
+		Help:      "1 while this replica is the scheduler leader, 0 otherwise.",
+	})
+
+	JanitorPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "janitor_purged_rows_total",
+		Help:      "Rows deleted by the janitor by artifact.",
+	}, []string{"artifact"})
+
+	RelationChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relation_checks_total",
+		Help:      "Relationship checks by result: allowed, denied or error.",
+	}, []string{"result"})
+)
+
+// ObserveAuth records the outcome of an auth operation. A nil err counts as
+// success, anything else is labeled with its errs.Kind. appID must be that of
+// an app known to exist, or zero, labeled "unknown": a client-supplied ID
+// would let callers mint series at will.
+func ObserveAuth(operation string, appID int, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = string(errs.KindOf(err))
+	}
+
+	app := "unknown"
+	if appID != 0 {
+		app = strconv.Itoa(appID)
+	}
+
+	AuthOutcomes.WithLabelValues(operation, outcome, app).Inc()
+}
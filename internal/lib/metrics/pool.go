@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports pgxpool statistics on every scrape.
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	maxConns      *prometheus.Desc
+	totalConns    *prometheus.Desc
+	idleConns     *prometheus.Desc
+	acquiredConns *prometheus.Desc
+	acquireCount  *prometheus.Desc
+	acquireWait   *prometheus.Desc
+	emptyAcquire  *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &PoolCollector{
+		pool:          pool,
+		maxConns:      desc("max_conns", "Maximum size of the pool."),
+		totalConns:    desc("total_conns", "Connections currently in the pool."),
+		idleConns:     desc("idle_conns", "Idle connections in the pool."),
+		acquiredConns: desc("acquired_conns", "Connections currently acquired."),
+		acquireCount:  desc("acquires_total", "Successful connection acquisitions."),
+		acquireWait:   desc("acquire_wait_seconds_total", "Time spent waiting for a connection."),
+		emptyAcquire:  desc("empty_acquires_total", "Acquisitions that had to wait for a connection."),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxConns
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.acquiredConns
+	ch <- c.acquireCount
+	ch <- c.acquireWait
+	ch <- c.emptyAcquire
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWait, prometheus.CounterValue, s.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquire, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+}
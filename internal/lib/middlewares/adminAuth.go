@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+)
+
+// AdminAuth rejects requests that do not carry one of the configured admin
+// bearer tokens and stores the matching token name in the request context.
+func AdminAuth(log *slog.Logger, tokens []config.AdminToken) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := bearerToken(r)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			for _, t := range tokens {
+				if t.Token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(t.Token)) == 1 {
+					next.ServeHTTP(w, r.WithContext(reqctx.WithAdmin(r.Context(), t.Name)))
+					return
+				}
+			}
+
+			log.WarnContext(r.Context(), "rejected admin request", slog.String("path", r.URL.Path))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return h[len(prefix):], true
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/finaptica/sso/internal/lib/clientauth"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+)
+
+// AppAuth rejects requests without valid app client credentials in HTTP
+// Basic form and stores the authenticated app ID in the request context.
+func AppAuth(log *slog.Logger, clients *clientauth.Cache) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			appID, secret, ok := clientauth.ParseBasic(r.Header.Get("Authorization"))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="sso"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			if err := clients.Verify(r.Context(), appID, secret); err != nil {
+				switch errs.KindOf(err) {
+				case errs.Unauthenticated:
+					log.WarnContext(r.Context(), "rejected client credentials", slog.Int("appID", appID))
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				case errs.PermissionDenied:
+					log.WarnContext(r.Context(), "rejected disabled app", slog.Int("appID", appID))
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				default:
+					log.ErrorContext(r.Context(), "failed to verify client credentials", sl.Err(err))
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+				return
+			}
+
+			reqctx.SetAppID(r.Context(), appID)
+			next.ServeHTTP(w, r.WithContext(reqctx.WithClientApp(r.Context(), appID)))
+		})
+	}
+}
@@ -11,7 +11,7 @@ func Recoverer(log *slog.Logger) func(http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if rec := recover(); rec != nil {
-					log.Error("panic recovered",
+					log.ErrorContext(r.Context(), "panic recovered",
 						slog.Any("panic", rec),
 						slog.String("stack", string(debug.Stack())),
 					)
@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// AccessTokenParser verifies access tokens issued by the service.
+type AccessTokenParser interface {
+	Parse(tokenString string) (jwt.MapClaims, error)
+}
+
+// UserAuth rejects requests without a valid access token and stores the
+// token's user ID, and session ID when present, in the request context.
+func UserAuth(log *slog.Logger, parser AccessTokenParser) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := bearerToken(r)
+			if !ok {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := parser.Parse(presented)
+			if err != nil {
+				log.InfoContext(r.Context(), "rejected access token", sl.Err(err))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			uid, _ := claims["uid"].(string)
+			id, err := uuid.Parse(uid)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			if appID, ok := claims["app_id"].(float64); ok {
+				reqctx.SetAppID(r.Context(), int(appID))
+			}
+
+			ctx := reqctx.WithUser(r.Context(), id)
+			sidClaim, _ := claims["sid"].(string)
+			if sid, err := uuid.Parse(sidClaim); err == nil {
+				ctx = reqctx.WithSession(ctx, sid)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
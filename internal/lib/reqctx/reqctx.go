@@ -0,0 +1,106 @@
+package reqctx
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+type ctxKey int
+
+const (
+	adminKey ctxKey = iota
+	requestKey
+	userKey
+	sessionKey
+	clientAppKey
+)
+
+// Request describes the HTTP request being served. It is created by the
+// request ID middleware; AppID is filled in later by whichever layer learns
+// which app the request is for.
+type Request struct {
+	ID        string
+	ClientIP  string
+	UserAgent string
+
+	appID atomic.Int64
+}
+
+func (r *Request) AppID() int {
+	return int(r.appID.Load())
+}
+
+// WithRequest stores req in ctx.
+func WithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestKey, req)
+}
+
+// RequestFrom returns the request stored in ctx, if any.
+func RequestFrom(ctx context.Context) (*Request, bool) {
+	req, ok := ctx.Value(requestKey).(*Request)
+	return req, ok
+}
+
+// RequestID returns the ID of the request in ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	if req, ok := RequestFrom(ctx); ok {
+		return req.ID
+	}
+	return ""
+}
+
+// SetAppID records the app the current request is for. It is a no-op
+// outside a request.
+func SetAppID(ctx context.Context, appID int) {
+	if req, ok := RequestFrom(ctx); ok {
+		req.appID.Store(int64(appID))
+	}
+}
+
+// WithAdmin stores the name of the authenticated admin in ctx.
+func WithAdmin(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, adminKey, name)
+}
+
+// Admin returns the name of the authenticated admin, if any.
+func Admin(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(adminKey).(string)
+	return name, ok
+}
+
+// WithUser stores the ID of the user authenticated by access token in ctx.
+func WithUser(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, userKey, id)
+}
+
+// User returns the ID of the authenticated user, if any.
+func User(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(userKey).(uuid.UUID)
+	return id, ok
+}
+
+// WithSession stores the ID of the session the access token was issued
+// under in ctx.
+func WithSession(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, sessionKey, id)
+}
+
+// Session returns the ID of the current user's session, if known.
+func Session(ctx context.Context) (uuid.UUID, bool) {
+	id, ok := ctx.Value(sessionKey).(uuid.UUID)
+	return id, ok
+}
+
+// WithClientApp stores the ID of the app authenticated by client credentials
+// in ctx.
+func WithClientApp(ctx context.Context, appID int) context.Context {
+	return context.WithValue(ctx, clientAppKey, appID)
+}
+
+// ClientApp returns the ID of the authenticated app, if any.
+func ClientApp(ctx context.Context) (int, bool) {
+	id, ok := ctx.Value(clientAppKey).(int)
+	return id, ok
+}
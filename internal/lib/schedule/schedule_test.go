@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// 2026-01-01 is a Thursday.
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "minute step",
+			spec: "*/15 * * * *",
+			from: start.Add(7 * time.Minute),
+			want: []time.Time{
+				time.Date(2026, 1, 1, 0, 15, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "list and range",
+			spec: "0 9-10,17 * * *",
+			from: start,
+			want: []time.Time{
+				time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 17, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "hourly",
+			spec: "@hourly",
+			from: time.Date(2026, 1, 1, 0, 59, 30, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "daily",
+			spec: "@daily",
+			from: start,
+			want: []time.Time{
+				time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "monthly",
+			spec: "@monthly",
+			from: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "sunday as 7",
+			spec: "0 0 * * 7",
+			from: start,
+			want: []time.Time{
+				time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "restricted day fields match either",
+			spec: "0 0 13 * 5",
+			from: start,
+			want: []time.Time{
+				time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 13, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "stepped day of month narrows day of week",
+			spec: "0 0 */10 * 1",
+			from: start,
+			want: []time.Time{
+				time.Date(2026, 5, 11, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "stepped day of week narrows day of month",
+			spec: "0 0 1 * */2",
+			from: start,
+			want: []time.Time{
+				time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "never",
+			spec: "0 0 30 2 *",
+			from: start,
+			want: []time.Time{{}},
+		},
+		{
+			name: "every",
+			spec: "@every 90s",
+			from: start,
+			want: []time.Time{
+				start.Add(90 * time.Second),
+				start.Add(180 * time.Second),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			next := tt.from
+			for i, want := range tt.want {
+				next = s.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("activation %d of %q after %v = %v, want %v", i+1, tt.spec, tt.from, next, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@yearly",
+		"@every 500ms",
+		"@every soon",
+	}
+
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if s, err := Parse(spec); err == nil {
+				t.Fatalf("Parse(%q) = %v, want an error", spec, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// invitationTokenType tells invitation tokens apart from access tokens,
+// which are signed with the same keys.
+const invitationTokenType = "invitation"
+
+type signingKey struct {
+	id      string
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// Signer issues and verifies service-signed JWTs. The first key signs new
+// tokens; every key is accepted for verification so signing keys can be
+// rotated without invalidating tokens already in flight.
+type Signer struct {
+	issuer string
+	keys   []signingKey
+}
+
+func NewSigner(issuer string, keys ...ed25519.PrivateKey) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one signing key is required")
+	}
+
+	s := &Signer{issuer: issuer}
+	for _, k := range keys {
+		pub := k.Public().(ed25519.PublicKey)
+		sum := sha256.Sum256(pub)
+		s.keys = append(s.keys, signingKey{id: hex.EncodeToString(sum[:8]), private: k, public: pub})
+	}
+
+	return s, nil
+}
+
+// LoadKeyFiles reads PEM encoded PKCS#8 Ed25519 private keys, as produced by
+// `openssl genpkey -algorithm ed25519`.
+func LoadKeyFiles(paths []string) ([]ed25519.PrivateKey, error) {
+	keys := make([]ed25519.PrivateKey, 0, len(paths))
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing key %s: %w", p, err)
+		}
+
+		k, err := jwt.ParseEdPrivateKeyFromPEM(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key %s: %w", p, err)
+		}
+
+		edKey, ok := k.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s is not an Ed25519 key", p)
+		}
+		keys = append(keys, edKey)
+	}
+
+	return keys, nil
+}
+
+// GenerateKey returns a fresh Ed25519 key, for local runs without key files.
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, k, err := ed25519.GenerateKey(rand.Reader)
+	return k, err
+}
+
+// NewAccessToken issues an access token for user in app under the session
+// sid. The roles and permissions claims are always present, empty when
+// nothing is granted; the org_id and org_role claims only when the app
+// belongs to an organization. Ed25519 signatures are deterministic, so the
+// same inputs always give the same token.
+func (s *Signer) NewAccessToken(user models.User, app models.App, authz models.Authorization, sid uuid.UUID, issuedAt time.Time, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"uid":         user.ID,
+		"sid":         sid,
+		"email":       user.Email,
+		"iat":         issuedAt.Unix(),
+		"exp":         issuedAt.Add(ttl).Unix(),
+		"app_id":      app.ID,
+		"roles":       nonNil(authz.Roles),
+		"permissions": nonNil(authz.Permissions),
+	}
+	if authz.OrgID != nil {
+		claims["org_id"] = authz.OrgID
+		claims["org_role"] = authz.OrgRole
+	}
+
+	return s.Sign(claims)
+}
+
+// NewInvitationToken issues the token of a registration invitation. It only
+// names the invitation; what it grants is read from storage when it is used.
+func (s *Signer) NewInvitationToken(inv models.AppInvitation) (string, error) {
+	return s.Sign(jwt.MapClaims{
+		"typ":    invitationTokenType,
+		"jti":    inv.ID,
+		"app_id": inv.AppID,
+		"iat":    inv.CreatedAt.Unix(),
+		"exp":    inv.ExpiresAt.Unix(),
+	})
+}
+
+// ParseInvitationToken verifies an invitation token and returns the IDs of
+// the invitation and of the app it is for.
+func (s *Signer) ParseInvitationToken(tokenString string) (uuid.UUID, int, error) {
+	claims, err := s.Parse(tokenString)
+	if err != nil {
+		return uuid.UUID{}, 0, err
+	}
+	if typ, _ := claims["typ"].(string); typ != invitationTokenType {
+		return uuid.UUID{}, 0, errors.New("not an invitation token")
+	}
+
+	jti, _ := claims["jti"].(string)
+	id, err := uuid.Parse(jti)
+	if err != nil {
+		return uuid.UUID{}, 0, fmt.Errorf("invalid invitation id: %w", err)
+	}
+	appID, ok := claims["app_id"].(float64)
+	if !ok {
+		return uuid.UUID{}, 0, errors.New("invitation token has no app")
+	}
+
+	return id, int(appID), nil
+}
+
+func nonNil(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+// Sign signs arbitrary claims with the active key, adding the issuer.
+func (s *Signer) Sign(claims jwt.MapClaims) (string, error) {
+	if s.issuer != "" {
+		claims["iss"] = s.issuer
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	t.Header["kid"] = s.keys[0].id
+
+	return t.SignedString(s.keys[0].private)
+}
+
+// Parse verifies the signature, expiry and issuer of tokenString and returns
+// its claims.
+func (s *Signer) Parse(tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodEdDSA {
+			return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		for _, k := range s.keys {
+			if k.id == kid {
+				return k.public, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.issuer != "" && !claims.VerifyIssuer(s.issuer, true) {
+		return nil, errors.New("unexpected issuer")
+	}
+
+	return claims, nil
+}
+
+// SignBytes signs msg with the active key and returns the key ID alongside
+// the signature.
+func (s *Signer) SignBytes(msg []byte) (string, []byte) {
+	return s.keys[0].id, ed25519.Sign(s.keys[0].private, msg)
+}
+
+// VerifyBytes checks sig over msg against the loaded key with keyID.
+func (s *Signer) VerifyBytes(keyID string, msg, sig []byte) error {
+	for _, k := range s.keys {
+		if k.id == keyID {
+			if !ed25519.Verify(k.public, msg, sig) {
+				return errors.New("invalid signature")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown key id %q", keyID)
+}
+
+// KeyCount reports how many keys are loaded.
+func (s *Signer) KeyCount() int {
+	return len(s.keys)
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys in JSON Web Key Set form.
+func (s *Signer) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(s.keys))}
+	for _, k := range s.keys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k.public),
+			Kid: k.id,
+			Alg: jwt.SigningMethodEdDSA.Alg(),
+			Use: "sig",
+		})
+	}
+	return set
+}
@@ -1,29 +1,11 @@
 package token
 
 import (
-	"math/rand"
-	"time"
-
-	"github.com/finaptica/sso/internal/domain/models"
-	"github.com/golang-jwt/jwt"
+	"crypto/rand"
+	"encoding/base64"
+	mathrand "math/rand"
 )
 
-func NewAccessToken(user models.User, app models.App, ttl time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	claims["uid"] = user.ID
-	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(ttl).Unix()
-	claims["app_id"] = app.ID
-
-	tokenString, err := token.SignedString([]byte(app.Secret))
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
-}
-
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
 const refreshTokenLength = 32
@@ -31,7 +13,18 @@ const refreshTokenLength = 32
 func NewRefreshToken() string {
 	b := make([]rune, refreshTokenLength)
 	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+		b[i] = letterRunes[mathrand.Intn(len(letterRunes))]
 	}
 	return string(b)
 }
+
+const secretBytes = 32
+
+// NewSecret returns a random URL-safe client secret.
+func NewSecret() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
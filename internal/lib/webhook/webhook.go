@@ -0,0 +1,102 @@
+// Package webhook sends domain events to subscriber endpoints as CloudEvents
+// in structured JSON mode, signed with the endpoint's shared secret.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	ContentType = "application/cloudevents+json"
+
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" where
+	// the MAC covers "<t>.<body>". Receivers should reject stale timestamps.
+	SignatureHeader = "Webhook-Signature"
+	// IDHeader carries the delivery ID, stable across retries, for
+	// receivers to deduplicate on.
+	IDHeader = "Webhook-Id"
+)
+
+// maxResponseBytes bounds how much of a response is read and kept for
+// diagnostics.
+const maxResponseBytes = 1024
+
+// CloudEvent is a CloudEvents 1.0 envelope.
+type CloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            any       `json:"data"`
+}
+
+// NewCloudEvent wraps data in an envelope with JSON content.
+func NewCloudEvent(id, source, eventType, subject string, at time.Time, data any) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              id,
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            at.UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+}
+
+// Sign returns the SignatureHeader value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Client posts signed events.
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(timeout time.Duration) *Client {
+	return &Client{http: &http.Client{Timeout: timeout}}
+}
+
+// Send posts body to url and returns the response status. Any status outside
+// 2xx is an error.
+func (c *Client) Send(ctx context.Context, url, secret, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set(IDHeader, deliveryID)
+	req.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+	}
+	return resp.StatusCode, nil
+}
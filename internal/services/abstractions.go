@@ -2,39 +2,229 @@ package services
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/blob"
+	"github.com/finaptica/sso/internal/lib/mailer"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
 type UserRepository interface {
-	CreateUser(ctx context.Context, email string, passHash []byte) (uid uuid.UUID, err error)
+	CreateUserTx(ctx context.Context, tx pgx.Tx, email string, passHash []byte) (uid uuid.UUID, err error)
 	GetUserByIDTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (models.User, error)
 	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter, cursor *models.UserCursor, limit int) ([]models.User, error)
+	UpdateProfile(ctx context.Context, id uuid.UUID, name, surname string) error
+	SwapAvatarKeyTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, key string) (string, error)
+	UpdateEmailTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, email string) error
+	IsUserExistByEmail(ctx context.Context, email string) (bool, error)
+	SetStatusTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, status string) error
+	AddStatusTransitionTx(ctx context.Context, tx pgx.Tx, t models.UserStatusTransition) error
+	ListStatusTransitions(ctx context.Context, userID uuid.UUID) ([]models.UserStatusTransition, error)
+	SetPasswordResetRequiredTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, required bool) error
+	UpdatePasswordTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, passHash []byte) error
+	DeleteUserTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) error
+	TouchAppMembership(ctx context.Context, userID uuid.UUID, appID int) error
 }
 
 type AppRepository interface {
 	GetAppById(ctx context.Context, appId int) (models.App, error)
 	GetAppByIDTx(ctx context.Context, tx pgx.Tx, id int) (models.App, error)
+	GetAppForUpdateTx(ctx context.Context, tx pgx.Tx, id int) (models.App, error)
+	ListApps(ctx context.Context) ([]models.App, error)
+	CreateAppTx(ctx context.Context, tx pgx.Tx, app models.App) (int, error)
+	UpdateAppTx(ctx context.Context, tx pgx.Tx, app models.App) error
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	DeleteAppTx(ctx context.Context, tx pgx.Tx, id int) error
+	AddSecretTx(ctx context.Context, tx pgx.Tx, appID int, secretHash []byte) (uuid.UUID, error)
+	ExpireSecretsTx(ctx context.Context, tx pgx.Tx, appID int, expiresAt time.Time) (int64, error)
+	ListSecrets(ctx context.Context, appID int) ([]models.AppSecret, error)
+	DeleteSecret(ctx context.Context, appID int, secretID uuid.UUID) error
+	CreateInvitation(ctx context.Context, inv models.AppInvitation) (models.AppInvitation, error)
+	ListInvitations(ctx context.Context, appID int) ([]models.AppInvitation, error)
+	DeleteInvitation(ctx context.Context, appID int, id uuid.UUID) error
+	UseInvitationTx(ctx context.Context, tx pgx.Tx, appID int, id, userID uuid.UUID, at time.Time) (models.AppInvitation, error)
+}
+
+// TokenSigner issues and verifies tokens signed with the service key.
+type TokenSigner interface {
+	NewAccessToken(user models.User, app models.App, authz models.Authorization, sessionID uuid.UUID, issuedAt time.Time, ttl time.Duration) (string, error)
+	NewInvitationToken(inv models.AppInvitation) (string, error)
+	ParseInvitationToken(tokenString string) (id uuid.UUID, appID int, err error)
+}
+
+type EmailChangeRepository interface {
+	ReplacePendingTx(ctx context.Context, tx pgx.Tx, change models.EmailChange) (uuid.UUID, error)
+	GetByTokenHashTx(ctx context.Context, tx pgx.Tx, tokenHash []byte) (models.EmailChange, error)
+	MarkConfirmedTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, at time.Time) error
+}
+
+type AuditRepository interface {
+	Insert(ctx context.Context, e models.AuditEvent) error
+	ChainPending(ctx context.Context, limit int) (int, error)
+	List(ctx context.Context, filter models.AuditFilter, beforeID int64, limit int) ([]models.AuditEvent, error)
+	ChainHead(ctx context.Context) (models.AuditEvent, error)
+	InsertCheckpoint(ctx context.Context, cp models.AuditCheckpoint) error
+}
+
+// CheckpointSigner signs audit chain checkpoints.
+type CheckpointSigner interface {
+	SignBytes(msg []byte) (keyID string, sig []byte)
+}
+
+// Mailer delivers transactional email.
+type Mailer interface {
+	Send(ctx context.Context, msg mailer.Message) error
+}
+
+// BlobStore keeps uploaded files such as avatars.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, blob.Info, error)
+	Delete(ctx context.Context, key string) error
 }
 
 type RefreshTokenRepository interface {
-	SaveNewRefreshToken(ctx context.Context, userId uuid.UUID, appId int, value string, expiresAt time.Time) (uuid.UUID, error)
-	SaveNewRefreshTokenTx(ctx context.Context, tx pgx.Tx, userId uuid.UUID, appId int, value string, expiresAt time.Time) (uuid.UUID, error)
-	RevokeTx(ctx context.Context, tx pgx.Tx, tokenID uuid.UUID) error
+	SaveNewRefreshTokenTx(ctx context.Context, tx pgx.Tx, token models.RefreshToken) (uuid.UUID, error)
+	RotateTx(ctx context.Context, tx pgx.Tx, tokenID, successorID uuid.UUID, at time.Time) error
+	GetByIDTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (models.RefreshToken, error)
 	GetByValueTx(ctx context.Context, tx pgx.Tx, tokenValue string) (models.RefreshToken, error)
 	GetByValue(ctx context.Context, tokenValue string) (*models.RefreshToken, error)
+	DeleteByAppTx(ctx context.Context, tx pgx.Tx, appID int) (int64, error)
+	RevokeAllForUserTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error)
+	DeleteByUserTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error)
+}
+
+type SessionRepository interface {
+	CreateTx(ctx context.Context, tx pgx.Tx, s models.Session) (uuid.UUID, error)
+	GetTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (models.Session, error)
+	TouchTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, ip, userAgent string, expiresAt time.Time) error
+	ListActive(ctx context.Context, userID uuid.UUID) ([]models.Session, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	RevokeTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) error
+	RevokeAll(ctx context.Context, userID uuid.UUID) (int64, error)
+}
+
+type RBACRepository interface {
+	CreatePermission(ctx context.Context, p models.Permission) (models.Permission, error)
+	ListPermissions(ctx context.Context, appID int) ([]models.Permission, error)
+	DeletePermission(ctx context.Context, appID int, id uuid.UUID) error
+	CreateRoleTx(ctx context.Context, tx pgx.Tx, role models.Role) (uuid.UUID, error)
+	UpdateRoleTx(ctx context.Context, tx pgx.Tx, role models.Role) error
+	SetRolePermissionsTx(ctx context.Context, tx pgx.Tx, appID int, roleID uuid.UUID, names []string) (int64, error)
+	GetRole(ctx context.Context, appID int, id uuid.UUID) (models.Role, error)
+	ListRoles(ctx context.Context, appID int) ([]models.Role, error)
+	DeleteRole(ctx context.Context, appID int, id uuid.UUID) error
+	AssignUserRole(ctx context.Context, userID, roleID uuid.UUID) error
+	UnassignUserRole(ctx context.Context, userID, roleID uuid.UUID) error
+	ListUserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error)
+	CreateGroup(ctx context.Context, g models.Group) (models.Group, error)
+	GetGroup(ctx context.Context, id uuid.UUID) (models.Group, error)
+	ListGroups(ctx context.Context) ([]models.Group, error)
+	DeleteGroup(ctx context.Context, id uuid.UUID) error
+	AddGroupMember(ctx context.Context, groupID, userID uuid.UUID) error
+	RemoveGroupMember(ctx context.Context, groupID, userID uuid.UUID) error
+	ListGroupMembers(ctx context.Context, groupID uuid.UUID) ([]models.User, error)
+	AssignGroupRole(ctx context.Context, groupID, roleID uuid.UUID) error
+	UnassignGroupRole(ctx context.Context, groupID, roleID uuid.UUID) error
+	ListGroupRoles(ctx context.Context, groupID uuid.UUID) ([]models.Role, error)
+	GetAuthorization(ctx context.Context, userID uuid.UUID, appID int) (models.Authorization, error)
+	AssignAppRolesTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID, appID int, roleIDs []uuid.UUID) (int64, error)
+}
+
+type OutboxRepository interface {
+	AddTx(ctx context.Context, tx pgx.Tx, e models.OutboxEvent) error
+	FanOut(ctx context.Context, limit int) (events int, deliveries int64, err error)
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(ctx context.Context, e models.WebhookEndpoint) (models.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, appID int) ([]models.WebhookEndpoint, error)
+	SetEndpointDisabled(ctx context.Context, appID int, id uuid.UUID, disabled bool) error
+	DeleteEndpoint(ctx context.Context, appID int, id uuid.UUID) error
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookJob, error)
+	RecordAttempt(ctx context.Context, d models.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, cursor *models.WebhookDeliveryCursor, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, id uuid.UUID) (models.WebhookDelivery, models.OutboxEvent, error)
+	Requeue(ctx context.Context, id uuid.UUID) error
+}
+
+// WebhookSender posts a signed event body to an endpoint.
+type WebhookSender interface {
+	Send(ctx context.Context, url, secret, deliveryID string, body []byte) (status int, err error)
+}
+
+type JanitorRepository interface {
+	Purge(ctx context.Context, artifact string, before time.Time, limit int) (int64, error)
+}
+
+type JobRepository interface {
+	StartRun(ctx context.Context, run models.JobRun) (models.JobRun, error)
+	FinishRun(ctx context.Context, id uuid.UUID, status, runErr string, finishedAt time.Time) error
+	ListRuns(ctx context.Context, job string, limit int) ([]models.JobRun, error)
+	LastRuns(ctx context.Context) ([]models.JobRun, error)
+}
+
+// Locker runs work that only one replica may do at a time.
+type Locker interface {
+	TryWithLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error)
+	TryLead(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) (bool, error)
 }
 
 type UnitOfWork interface {
 	Do(ctx context.Context, fn func(pgx.Tx) error) error
 }
 
+type RelationRepository interface {
+	ListNamespaces(ctx context.Context, appID int) ([]models.RelationNamespace, error)
+	GetNamespaceTx(ctx context.Context, tx pgx.Tx, appID int, name string) (models.RelationNamespace, error)
+	PutNamespaceTx(ctx context.Context, tx pgx.Tx, ns models.RelationNamespace) (models.RelationNamespace, error)
+	DeleteNamespaceTx(ctx context.Context, tx pgx.Tx, appID int, name string) error
+	CountLiveTuplesTx(ctx context.Context, tx pgx.Tx, appID int, namespace string, relations []string) (int64, error)
+	WriteTuplesTx(ctx context.Context, tx pgx.Tx, appID int, writes, deletes []models.RelationTuple) (int64, error)
+	Revision(ctx context.Context, appID int) (int64, error)
+	ReadSubjects(ctx context.Context, appID int, revision int64, object models.RelationObject, relation string) ([]models.RelationSubject, error)
+	ListObjectIDs(ctx context.Context, appID int, revision int64, namespace string) ([]string, error)
+}
+
+type OrgRepository interface {
+	CreateOrgTx(ctx context.Context, tx pgx.Tx, org models.Organization) (models.Organization, error)
+	GetOrg(ctx context.Context, id uuid.UUID) (models.Organization, error)
+	ListOrgs(ctx context.Context) ([]models.Organization, error)
+	UpdateOrg(ctx context.Context, org models.Organization) (models.Organization, error)
+	DeleteOrg(ctx context.Context, id uuid.UUID) error
+	ListMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrgMembership, error)
+	GetMembership(ctx context.Context, orgID, userID uuid.UUID) (models.OrgMembership, error)
+	GetMembershipTx(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID) (models.OrgMembership, error)
+	ListUserOrgs(ctx context.Context, userID uuid.UUID) ([]models.UserOrganization, error)
+	PutMembershipTx(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID, role string) error
+	DeleteMembershipTx(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID) error
+	CountOwnersTx(ctx context.Context, tx pgx.Tx, orgID uuid.UUID) (int, error)
+	ReplaceInvitationTx(ctx context.Context, tx pgx.Tx, inv models.OrgInvitation) (models.OrgInvitation, error)
+	ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]models.OrgInvitation, error)
+	GetInvitationTx(ctx context.Context, tx pgx.Tx, orgID, id uuid.UUID) (models.OrgInvitation, error)
+	GetInvitationByTokenHashTx(ctx context.Context, tx pgx.Tx, tokenHash []byte) (models.OrgInvitation, error)
+	MarkInvitationAcceptedTx(ctx context.Context, tx pgx.Tx, id, userID uuid.UUID, at time.Time) error
+	DeleteInvitationTx(ctx context.Context, tx pgx.Tx, orgID, id uuid.UUID) error
+}
+
 type RepositoriesContainer struct {
-	UserRepo UserRepository
-	AppRepo  AppRepository
-	RtsRepo  RefreshTokenRepository
-	Uow      UnitOfWork
+	UserRepo        UserRepository
+	AppRepo         AppRepository
+	RtsRepo         RefreshTokenRepository
+	SessionRepo     SessionRepository
+	EmailChangeRepo EmailChangeRepository
+	AuditRepo       AuditRepository
+	OutboxRepo      OutboxRepository
+	WebhookRepo     WebhookRepository
+	RBACRepo        RBACRepository
+	RelationRepo    RelationRepository
+	OrgRepo         OrgRepository
+	JanitorRepo     JanitorRepository
+	JobRepo         JobRepository
+	Uow             UnitOfWork
 }
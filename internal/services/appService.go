@@ -0,0 +1,536 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	tokenGen "github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const maxAppNameLength = 255
+
+var knownGrantTypes = map[string]struct{}{
+	models.GrantTypePassword:     {},
+	models.GrantTypeRefreshToken: {},
+}
+
+var knownRegistrationPolicies = map[string]struct{}{
+	models.RegistrationOpen:       {},
+	models.RegistrationInvitation: {},
+	models.RegistrationDomains:    {},
+	models.RegistrationDisabled:   {},
+}
+
+type AppService struct {
+	appRepository          AppRepository
+	refreshTokenRepository RefreshTokenRepository
+	audit                  *auditor
+	uow                    UnitOfWork
+	log                    *slog.Logger
+}
+
+// NewAppService returns a new instance of the AppService
+func NewAppService(log *slog.Logger, repoContainer RepositoriesContainer) *AppService {
+	return &AppService{
+		appRepository:          repoContainer.AppRepo,
+		refreshTokenRepository: repoContainer.RtsRepo,
+		audit:                  newAuditor(log, repoContainer.AuditRepo),
+		uow:                    repoContainer.Uow,
+		log:                    log,
+	}
+}
+
+func (s *AppService) CreateApp(ctx context.Context, params contracts.CreateAppParams) (created contracts.CreatedApp, err error) {
+	const op = "appService.CreateApp"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{EventType: models.AuditAppCreate, AppID: intRef(created.ID)}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)))
+
+	app := models.App{
+		Name:                   strings.TrimSpace(params.Name),
+		RedirectURIs:           params.RedirectURIs,
+		AllowedGrantTypes:      params.AllowedGrantTypes,
+		AccessTokenTTL:         time.Duration(params.AccessTokenTTLSeconds) * time.Second,
+		RefreshTokenTTL:        time.Duration(params.RefreshTokenTTLSeconds) * time.Second,
+		SessionLifetime:        time.Duration(params.SessionLifetimeSeconds) * time.Second,
+		OfflineAccess:          params.OfflineAccess,
+		OfflineIdleTimeout:     time.Duration(params.OfflineIdleTimeoutSeconds) * time.Second,
+		OfflineSessionLifetime: time.Duration(params.OfflineSessionLifetimeSeconds) * time.Second,
+		OrgID:                  params.OrgID,
+		RegistrationPolicy:     params.RegistrationPolicy,
+		RegistrationDomains:    normalizeDomains(params.RegistrationDomains),
+	}
+	if app.RedirectURIs == nil {
+		app.RedirectURIs = []string{}
+	}
+	if app.RegistrationPolicy == "" {
+		app.RegistrationPolicy = models.RegistrationOpen
+	}
+	if len(app.AllowedGrantTypes) == 0 {
+		app.AllowedGrantTypes = []string{models.GrantTypePassword, models.GrantTypeRefreshToken}
+	}
+	if err := validateApp(app); err != nil {
+		return contracts.CreatedApp{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	secret, secretHash, err := newHashedSecret()
+	if err != nil {
+		return contracts.CreatedApp{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	var id int
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		appID, err := s.appRepository.CreateAppTx(ctx, tx, app)
+		if err != nil {
+			return err
+		}
+		id = appID
+
+		_, err = s.appRepository.AddSecretTx(ctx, tx, appID, secretHash)
+		return err
+	})
+	if err != nil {
+		return contracts.CreatedApp{}, errs.Wrap(op, err)
+	}
+
+	app, err = s.appRepository.GetAppById(ctx, id)
+	if err != nil {
+		return contracts.CreatedApp{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "app created", slog.Int("appID", id))
+	return contracts.CreatedApp{AppInfo: toAppInfo(app), Secret: secret}, nil
+}
+
+func (s *AppService) ListApps(ctx context.Context) (infos []contracts.AppInfo, err error) {
+	const op = "appService.ListApps"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	apps, err := s.appRepository.ListApps(ctx)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.AppInfo, 0, len(apps))
+	for _, app := range apps {
+		infos = append(infos, toAppInfo(app))
+	}
+
+	return infos, nil
+}
+
+func (s *AppService) GetApp(ctx context.Context, id int) (info contracts.AppInfo, err error) {
+	const op = "appService.GetApp"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	app, err := s.appRepository.GetAppById(ctx, id)
+	if err != nil {
+		return contracts.AppInfo{}, errs.Wrap(op, err)
+	}
+
+	return toAppInfo(app), nil
+}
+
+func (s *AppService) UpdateApp(ctx context.Context, id int, params contracts.UpdateAppParams) (info contracts.AppInfo, err error) {
+	const op = "appService.UpdateApp"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{EventType: models.AuditAppUpdate, AppID: intRef(id)}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.Int("appID", id))
+
+	// The row lock keeps a concurrent update from being overwritten with
+	// the fields this one read.
+	var app models.App
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		var err error
+		app, err = s.appRepository.GetAppForUpdateTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if params.Name != nil {
+			app.Name = strings.TrimSpace(*params.Name)
+		}
+		if params.RedirectURIs != nil {
+			app.RedirectURIs = *params.RedirectURIs
+		}
+		if params.AllowedGrantTypes != nil {
+			app.AllowedGrantTypes = *params.AllowedGrantTypes
+		}
+		if params.AccessTokenTTLSeconds != nil {
+			app.AccessTokenTTL = time.Duration(*params.AccessTokenTTLSeconds) * time.Second
+		}
+		if params.RefreshTokenTTLSeconds != nil {
+			app.RefreshTokenTTL = time.Duration(*params.RefreshTokenTTLSeconds) * time.Second
+		}
+		if params.SessionLifetimeSeconds != nil {
+			app.SessionLifetime = time.Duration(*params.SessionLifetimeSeconds) * time.Second
+		}
+		if params.OfflineAccess != nil {
+			app.OfflineAccess = *params.OfflineAccess
+		}
+		if params.OfflineIdleTimeoutSeconds != nil {
+			app.OfflineIdleTimeout = time.Duration(*params.OfflineIdleTimeoutSeconds) * time.Second
+		}
+		if params.OfflineSessionLifetimeSeconds != nil {
+			app.OfflineSessionLifetime = time.Duration(*params.OfflineSessionLifetimeSeconds) * time.Second
+		}
+		if params.OrgID != nil {
+			app.OrgID = params.OrgID
+			if *params.OrgID == uuid.Nil {
+				app.OrgID = nil
+			}
+		}
+		if params.RegistrationPolicy != nil {
+			app.RegistrationPolicy = *params.RegistrationPolicy
+		}
+		if params.RegistrationDomains != nil {
+			app.RegistrationDomains = normalizeDomains(*params.RegistrationDomains)
+		}
+		if app.RedirectURIs == nil {
+			app.RedirectURIs = []string{}
+		}
+		if err := validateApp(app); err != nil {
+			return errs.WithKind(op, errs.Invalid, err)
+		}
+
+		if err := s.appRepository.UpdateAppTx(ctx, tx, app); err != nil {
+			return err
+		}
+
+		app, err = s.appRepository.GetAppByIDTx(ctx, tx, id)
+		return err
+	})
+	if err != nil {
+		return contracts.AppInfo{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "app updated")
+	return toAppInfo(app), nil
+}
+
+func (s *AppService) SetAppDisabled(ctx context.Context, id int, disabled bool) (err error) {
+	const op = "appService.SetAppDisabled"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditAppStateChange,
+			AppID:     intRef(id),
+			Details:   map[string]any{"disabled": disabled},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.Int("appID", id))
+
+	if err := s.appRepository.SetDisabled(ctx, id, disabled); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "app state changed", slog.Bool("disabled", disabled))
+	return nil
+}
+
+// DeleteApp removes the app together with every refresh token issued for it.
+func (s *AppService) DeleteApp(ctx context.Context, id int) (err error) {
+	const op = "appService.DeleteApp"
+	ctx, span := tracing.Start(ctx, op)
+	var deletedTokens int64
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditAppDelete,
+			AppID:     intRef(id),
+			Details:   map[string]any{"deleted_refresh_tokens": deletedTokens},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.Int("appID", id))
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		n, err := s.refreshTokenRepository.DeleteByAppTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		deletedTokens = n
+
+		return s.appRepository.DeleteAppTx(ctx, tx, id)
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "app deleted", slog.Int64("deletedRefreshTokens", deletedTokens))
+	return nil
+}
+
+// RotateSecret issues a new client secret and makes the app's existing
+// secrets expire after previousTTL, so clients can switch over without
+// downtime. A zero previousTTL revokes the old secrets immediately.
+func (s *AppService) RotateSecret(ctx context.Context, appID int, previousTTL time.Duration) (rotated contracts.RotatedSecret, err error) {
+	const op = "appService.RotateSecret"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditAppSecretRotate,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"secret_id": rotated.SecretID},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.Int("appID", appID))
+
+	if previousTTL < 0 {
+		return contracts.RotatedSecret{}, errs.WithKind(op, errs.Invalid, errors.New("previous secret ttl must not be negative"))
+	}
+
+	secret, secretHash, err := newHashedSecret()
+	if err != nil {
+		return contracts.RotatedSecret{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	previousExpiresAt := time.Now().UTC().Add(previousTTL)
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		if _, err := s.appRepository.GetAppByIDTx(ctx, tx, appID); err != nil {
+			return err
+		}
+
+		if _, err := s.appRepository.ExpireSecretsTx(ctx, tx, appID, previousExpiresAt); err != nil {
+			return err
+		}
+
+		secretID, err := s.appRepository.AddSecretTx(ctx, tx, appID, secretHash)
+		if err != nil {
+			return err
+		}
+		rotated.SecretID = secretID
+		return nil
+	})
+	if err != nil {
+		return contracts.RotatedSecret{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "app secret rotated", slog.Time("previousExpiresAt", previousExpiresAt))
+	rotated.Secret = secret
+	rotated.PreviousExpiresAt = previousExpiresAt
+	return rotated, nil
+}
+
+func (s *AppService) ListSecrets(ctx context.Context, appID int) (infos []contracts.AppSecretInfo, err error) {
+	const op = "appService.ListSecrets"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.appRepository.GetAppById(ctx, appID); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	secrets, err := s.appRepository.ListSecrets(ctx, appID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	now := time.Now()
+	infos = make([]contracts.AppSecretInfo, 0, len(secrets))
+	for _, sec := range secrets {
+		infos = append(infos, contracts.AppSecretInfo{
+			ID:        sec.ID,
+			CreatedAt: sec.CreatedAt,
+			ExpiresAt: sec.ExpiresAt,
+			IsActive:  sec.ActiveAt(now),
+		})
+	}
+
+	return infos, nil
+}
+
+func (s *AppService) DeleteSecret(ctx context.Context, appID int, secretID uuid.UUID) (err error) {
+	const op = "appService.DeleteSecret"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditAppSecretDelete,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"secret_id": secretID},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.Int("appID", appID))
+
+	if err := s.appRepository.DeleteSecret(ctx, appID, secretID); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "app secret deleted", slog.String("secretID", secretID.String()))
+	return nil
+}
+
+// VerifySecret authenticates a client by one of its active secrets. A
+// disabled app is refused even with a valid secret.
+func (s *AppService) VerifySecret(ctx context.Context, appID int, secret string) (err error) {
+	const op = "appService.VerifySecret"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	invalid := errs.WithKind(op, errs.Unauthenticated, errors.New("invalid client credentials"))
+
+	app, err := s.appRepository.GetAppById(ctx, appID)
+	if err != nil {
+		if errs.KindOf(err) == errs.NotFound {
+			return invalid
+		}
+		return errs.Wrap(op, err)
+	}
+
+	secrets, err := s.appRepository.ListSecrets(ctx, appID)
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	now := time.Now()
+	for _, sec := range secrets {
+		if !sec.ActiveAt(now) {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword(sec.SecretHash, []byte(secret)) == nil {
+			if app.IsDisabled {
+				return errs.WithKind(op, errs.PermissionDenied, errors.New("app is disabled"))
+			}
+			return nil
+		}
+	}
+
+	return invalid
+}
+
+func newHashedSecret() (secret string, hash []byte, err error) {
+	secret, err = tokenGen.NewSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	hash, err = bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return secret, hash, nil
+}
+
+func validateApp(app models.App) error {
+	if app.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(app.Name) > maxAppNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxAppNameLength)
+	}
+
+	for _, raw := range app.RedirectURIs {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return fmt.Errorf("invalid redirect uri %q", raw)
+		}
+	}
+
+	if len(app.AllowedGrantTypes) == 0 {
+		return errors.New("at least one grant type is required")
+	}
+	for _, g := range app.AllowedGrantTypes {
+		if _, ok := knownGrantTypes[g]; !ok {
+			return fmt.Errorf("unknown grant type %q", g)
+		}
+	}
+
+	if app.AccessTokenTTL < 0 || app.RefreshTokenTTL < 0 {
+		return errors.New("token ttl must not be negative")
+	}
+	if app.SessionLifetime < 0 || app.OfflineIdleTimeout < 0 || app.OfflineSessionLifetime < 0 {
+		return errors.New("session lifetimes must not be negative")
+	}
+
+	if _, ok := knownRegistrationPolicies[app.RegistrationPolicy]; !ok {
+		return fmt.Errorf("unknown registration policy %q", app.RegistrationPolicy)
+	}
+	for _, d := range app.RegistrationDomains {
+		if d == "" || strings.ContainsAny(d, "@/ ") || !strings.Contains(d, ".") {
+			return fmt.Errorf("invalid registration domain %q", d)
+		}
+	}
+	if app.RegistrationPolicy == models.RegistrationDomains && len(app.RegistrationDomains) == 0 {
+		return errors.New("the domains registration policy needs at least one domain")
+	}
+
+	return nil
+}
+
+// normalizeDomains lowercases the domains and drops a leading "@", so both
+// "example.com" and "@Example.com" are accepted.
+func normalizeDomains(domains []string) []string {
+	out := make([]string, 0, len(domains))
+	for _, d := range domains {
+		out = append(out, strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@")))
+	}
+	return out
+}
+
+func toAppInfo(app models.App) contracts.AppInfo {
+	return contracts.AppInfo{
+		ID:                            app.ID,
+		Name:                          app.Name,
+		RedirectURIs:                  app.RedirectURIs,
+		AllowedGrantTypes:             app.AllowedGrantTypes,
+		AccessTokenTTLSeconds:         int(app.AccessTokenTTL / time.Second),
+		RefreshTokenTTLSeconds:        int(app.RefreshTokenTTL / time.Second),
+		SessionLifetimeSeconds:        int(app.SessionLifetime / time.Second),
+		OfflineAccess:                 app.OfflineAccess,
+		OfflineIdleTimeoutSeconds:     int(app.OfflineIdleTimeout / time.Second),
+		OfflineSessionLifetimeSeconds: int(app.OfflineSessionLifetime / time.Second),
+		IsDisabled:                    app.IsDisabled,
+		OrgID:                         app.OrgID,
+		RegistrationPolicy:            app.RegistrationPolicy,
+		RegistrationDomains:           app.RegistrationDomains,
+		CreatedAt:                     app.CreatedAt,
+		UpdatedAt:                     app.UpdatedAt,
+	}
+}
+
+func adminName(ctx context.Context) string {
+	name, _ := reqctx.Admin(ctx)
+	return name
+}
+
+// actor names who is acting on behalf of the request: an admin token, the
+// user behind an access token, or the service itself.
+func actor(ctx context.Context) string {
+	if name, ok := reqctx.Admin(ctx); ok {
+		return "admin:" + name
+	}
+	if id, ok := reqctx.User(ctx); ok {
+		return subjectActor(id)
+	}
+	return "system"
+}
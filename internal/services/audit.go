@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/auditchain"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 500
+
+	// auditChainBatchSize is how many queued events one transaction moves
+	// into the chain.
+	auditChainBatchSize = 500
+)
+
+// auditor writes audit events on behalf of the other services. Writing is
+// best effort: a failed write is logged and counted but never fails the
+// operation being audited. Events are queued without locking anything and
+// join the chain when AuditService.Chain next runs.
+type auditor struct {
+	repo AuditRepository
+	log  *slog.Logger
+}
+
+func newAuditor(log *slog.Logger, repo AuditRepository) *auditor {
+	return &auditor{repo: repo, log: log}
+}
+
+// record stores e, filling in the request metadata, the acting principal and
+// the outcome of err.
+func (a *auditor) record(ctx context.Context, e models.AuditEvent, err error) {
+	if a == nil || a.repo == nil {
+		return
+	}
+
+	if req, ok := reqctx.RequestFrom(ctx); ok {
+		e.IP = req.ClientIP
+		e.UserAgent = req.UserAgent
+		e.RequestID = req.ID
+		if e.AppID == nil && req.AppID() != 0 {
+			appID := req.AppID()
+			e.AppID = &appID
+		}
+	}
+	if e.Actor == "" {
+		e.Actor = actor(ctx)
+	}
+
+	e.Outcome = models.AuditOutcomeSuccess
+	if err != nil {
+		e.Outcome = models.AuditOutcomeFailure
+		if e.Reason == "" {
+			e.Reason = string(errs.KindOf(err))
+		}
+	}
+
+	// The audited operation may have been cancelled by the client; the
+	// record of it should still be written.
+	if writeErr := a.repo.Insert(context.WithoutCancel(ctx), e); writeErr != nil {
+		metrics.AuditWriteFailures.Inc()
+		a.log.ErrorContext(ctx, "failed to write audit event", slog.String("eventType", e.EventType), sl.Err(writeErr))
+	}
+}
+
+// subjectActor names a user acting on their own account, or "anonymous"
+// when the user could not be identified.
+func subjectActor(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return "anonymous"
+	}
+	return "user:" + id.String()
+}
+
+func uuidRef(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+func intRef(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// AuditService backs the admin audit query API and signs checkpoints of the
+// audit chain.
+type AuditService struct {
+	auditRepository AuditRepository
+	signer          CheckpointSigner
+	log             *slog.Logger
+}
+
+// NewAuditService returns a new instance of the AuditService
+func NewAuditService(log *slog.Logger, repoContainer RepositoriesContainer, signer CheckpointSigner) *AuditService {
+	return &AuditService{
+		auditRepository: repoContainer.AuditRepo,
+		signer:          signer,
+		log:             log,
+	}
+}
+
+// Chain moves every queued audit event into the hash chain. It is the
+// chain's only writer, run by the scheduler on one replica at a time.
+func (s *AuditService) Chain(ctx context.Context) (err error) {
+	const op = "auditService.Chain"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	for {
+		n, err := s.auditRepository.ChainPending(ctx, auditChainBatchSize)
+		if err != nil {
+			return errs.Wrap(op, err)
+		}
+		if n < auditChainBatchSize {
+			return nil
+		}
+	}
+}
+
+// Checkpoint signs the current head of the audit chain. It does nothing when
+// the head has not moved since the last checkpoint.
+func (s *AuditService) Checkpoint(ctx context.Context) (err error) {
+	const op = "auditService.Checkpoint"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	head, err := s.auditRepository.ChainHead(ctx)
+	if err != nil {
+		if errs.KindOf(err) == errs.NotFound {
+			return nil
+		}
+		return errs.Wrap(op, err)
+	}
+
+	cp := models.AuditCheckpoint{
+		EventID:   head.ID,
+		EventHash: head.Hash,
+		CreatedAt: auditchain.Timestamp(time.Now()),
+	}
+	cp.KeyID, cp.Signature = s.signer.SignBytes(auditchain.CheckpointMessage(cp.EventID, cp.EventHash, cp.CreatedAt))
+
+	if err := s.auditRepository.InsertCheckpoint(ctx, cp); err != nil {
+		if errs.KindOf(err) == errs.AlreadyExists {
+			return nil
+		}
+		return errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "audit chain checkpointed", slog.Int64("eventID", cp.EventID), slog.String("keyID", cp.KeyID))
+	return nil
+}
+
+// ListEvents returns one page of audit events, newest first.
+func (s *AuditService) ListEvents(ctx context.Context, params contracts.ListAuditEventsParams) (page contracts.AuditEventPage, err error) {
+	const op = "auditService.ListEvents"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	limit := params.Limit
+	switch {
+	case limit == 0:
+		limit = defaultAuditPageSize
+	case limit < 0 || limit > maxAuditPageSize:
+		return contracts.AuditEventPage{}, errs.WithKind(op, errs.Invalid, errors.New("limit must be between 1 and 500"))
+	}
+
+	switch params.Outcome {
+	case "", models.AuditOutcomeSuccess, models.AuditOutcomeFailure:
+	default:
+		return contracts.AuditEventPage{}, errs.WithKind(op, errs.Invalid, errors.New("outcome must be success or failure"))
+	}
+
+	var beforeID int64
+	if params.Cursor != "" {
+		beforeID, err = strconv.ParseInt(params.Cursor, 10, 64)
+		if err != nil || beforeID <= 0 {
+			return contracts.AuditEventPage{}, errs.WithKind(op, errs.Invalid, errors.New("invalid cursor"))
+		}
+	}
+
+	filter := models.AuditFilter{
+		EventType:     params.EventType,
+		Actor:         params.Actor,
+		SubjectUserID: params.SubjectUserID,
+		AppID:         params.AppID,
+		Outcome:       params.Outcome,
+		From:          params.From,
+		To:            params.To,
+	}
+
+	events, err := s.auditRepository.List(ctx, filter, beforeID, limit+1)
+	if err != nil {
+		return contracts.AuditEventPage{}, errs.Wrap(op, err)
+	}
+
+	page.Events = make([]contracts.AuditEventInfo, 0, min(len(events), limit))
+	for i, e := range events {
+		if i == limit {
+			page.NextCursor = strconv.FormatInt(events[limit-1].ID, 10)
+			break
+		}
+		page.Events = append(page.Events, toAuditEventInfo(e))
+	}
+
+	return page, nil
+}
+
+func toAuditEventInfo(e models.AuditEvent) contracts.AuditEventInfo {
+	return contracts.AuditEventInfo{
+		ID:            e.ID,
+		OccurredAt:    e.OccurredAt,
+		EventType:     e.EventType,
+		Actor:         e.Actor,
+		SubjectUserID: e.SubjectUserID,
+		AppID:         e.AppID,
+		IP:            e.IP,
+		UserAgent:     e.UserAgent,
+		RequestID:     e.RequestID,
+		Outcome:       e.Outcome,
+		Reason:        e.Reason,
+		Details:       e.Details,
+		Hash:          hex.EncodeToString(e.Hash),
+	}
+}
@@ -2,14 +2,24 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/finaptica/sso/internal/config"
 	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/emailaddr"
 	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/reqctx"
 	tokenGen "github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/tracing"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,29 +27,78 @@ type AuthService struct {
 	userRepository         UserRepository
 	appRepository          AppRepository
 	refreshTokenRepository RefreshTokenRepository
+	sessionRepository      SessionRepository
+	rbacRepository         RBACRepository
+	orgRepository          OrgRepository
+	outboxRepository       OutboxRepository
+	uow                    UnitOfWork
+	signer                 TokenSigner
+	audit                  *auditor
+	emails                 *emailaddr.Normalizer
 	log                    *slog.Logger
 	accessTokenTTL         time.Duration
-	refreshTokenTTL        time.Duration
+	sessions               sessionLimits
 }
 
 // NewAuthService returns a new instance of the AuthService
-func NewAuthService(log *slog.Logger, repoContainer RepositoriesContainer, cfg *config.Config) *AuthService {
+func NewAuthService(log *slog.Logger, repoContainer RepositoriesContainer, signer TokenSigner, cfg *config.Config) *AuthService {
 	return &AuthService{
 		log:                    log,
+		signer:                 signer,
 		userRepository:         repoContainer.UserRepo,
 		appRepository:          repoContainer.AppRepo,
 		refreshTokenRepository: repoContainer.RtsRepo,
+		sessionRepository:      repoContainer.SessionRepo,
+		rbacRepository:         repoContainer.RBACRepo,
+		orgRepository:          repoContainer.OrgRepo,
+		outboxRepository:       repoContainer.OutboxRepo,
+		uow:                    repoContainer.Uow,
+		emails:                 emailaddr.NewNormalizer(cfg.Email.LocalPart),
+		audit:                  newAuditor(log, repoContainer.AuditRepo),
 		accessTokenTTL:         cfg.AccessTokenTTL,
-		refreshTokenTTL:        cfg.RefreshTokenTTL,
+		sessions:               newSessionLimits(cfg),
 	}
 }
 
-func (a *AuthService) Login(ctx context.Context, email string, password string, appId int) (tokensInfo contracts.TokensInfo, err error) {
+// Login checks the user's password and opens a new session in the app,
+// labelled with the device name the client sent. The session ends after the
+// app's idle timeout without a refresh, or at the end of its lifetime.
+func (a *AuthService) Login(ctx context.Context, params contracts.LoginParams) (tokensInfo contracts.TokensInfo, err error) {
 	const op = "auth.Login"
+	ctx, span := tracing.Start(ctx, op)
+	appId := params.AppID
+	var knownAppID int
+	var userID, sessionID uuid.UUID
+	defer func() {
+		metrics.ObserveAuth("login", knownAppID, err)
+		var details map[string]any
+		if sessionID != uuid.Nil {
+			details = map[string]any{"session_id": sessionID, "offline": params.OfflineAccess}
+		}
+		a.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditLogin,
+			Actor:         subjectActor(userID),
+			SubjectUserID: uuidRef(userID),
+			AppID:         intRef(appId),
+			Details:       details,
+		}, err)
+		tracing.End(span, err)
+	}()
+	reqctx.SetAppID(ctx, appId)
 
-	log := a.log.With(slog.String("op", op), slog.String("email", email))
+	log := a.log.With(slog.String("op", op), slog.String("email", params.Email))
+
+	log.InfoContext(ctx, "attempting to login user")
 
-	log.Info("attempting to login user")
+	email, err := a.emails.Normalize(params.Email)
+	if err != nil {
+		return contracts.TokensInfo{}, errs.WithKind(op, errs.Unauthenticated, err)
+	}
+
+	deviceName := strings.TrimSpace(params.DeviceName)
+	if len(deviceName) > maxDeviceNameLength {
+		return contracts.TokensInfo{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("device name must be at most %d bytes", maxDeviceNameLength))
+	}
 
 	user, err := a.userRepository.GetUserByEmail(ctx, email)
 	if err != nil {
@@ -49,12 +108,20 @@ func (a *AuthService) Login(ctx context.Context, email string, password string,
 
 		return contracts.TokensInfo{}, errs.WithKind(op, errs.Internal, err)
 	}
+	userID = user.ID
 
-	err = bcrypt.CompareHashAndPassword(user.PassHash, []byte(password))
+	bcryptStart := time.Now()
+	err = bcrypt.CompareHashAndPassword(user.PassHash, []byte(params.Password))
+	metrics.BcryptDuration.WithLabelValues("compare").Observe(time.Since(bcryptStart).Seconds())
 	if err != nil {
 		return contracts.TokensInfo{}, errs.WithKind(op, errs.Unauthenticated, err)
 	}
 
+	if err := checkUserCanSignIn(op, user); err != nil {
+		log.InfoContext(ctx, "login refused", slog.String("status", user.Status), sl.Err(err))
+		return contracts.TokensInfo{}, err
+	}
+
 	app, err := a.appRepository.GetAppById(ctx, appId)
 	if err != nil {
 		if errs.KindOf(err) == errs.NotFound {
@@ -63,50 +130,319 @@ func (a *AuthService) Login(ctx context.Context, email string, password string,
 
 		return contracts.TokensInfo{}, errs.WithKind(op, errs.Internal, err)
 	}
+	knownAppID = app.ID
+
+	if app.IsDisabled || !app.AllowsGrant(models.GrantTypePassword) {
+		return contracts.TokensInfo{}, errs.WithKind(op, errs.PermissionDenied, errors.New("app does not allow password login"))
+	}
+	if params.OfflineAccess && !app.OfflineAccess {
+		return contracts.TokensInfo{}, errs.WithKind(op, errs.PermissionDenied, errors.New("app does not allow offline access"))
+	}
 
-	accessToken, err := tokenGen.NewAccessToken(user, app, a.accessTokenTTL)
+	authz, err := appAuthorization(ctx, op, a.rbacRepository, a.orgRepository, user.ID, app)
 	if err != nil {
-		return contracts.TokensInfo{}, errs.WithKind(op, errs.Internal, err)
+		return contracts.TokensInfo{}, err
 	}
 
+	now := time.Now().UTC()
+	idle, lifetime := a.sessions.forApp(app, params.OfflineAccess)
+	sessionEnd := now.Add(lifetime)
 	refreshTokenValue := tokenGen.NewRefreshToken()
-	refreshTokenExpiresAt := time.Now().UTC().Add(a.refreshTokenTTL)
-	_, err = a.refreshTokenRepository.SaveNewRefreshToken(ctx, user.ID, app.ID, refreshTokenValue, refreshTokenExpiresAt)
+	refreshTokenExpiresAt := refreshExpiry(now, idle, sessionEnd)
+	ip, userAgent := requestClient(ctx)
+	err = a.uow.Do(ctx, func(tx pgx.Tx) error {
+		id, err := a.sessionRepository.CreateTx(ctx, tx, models.Session{
+			UserID:            user.ID,
+			AppID:             app.ID,
+			DeviceName:        deviceName,
+			UserAgent:         userAgent,
+			IP:                ip,
+			ExpiresAt:         refreshTokenExpiresAt,
+			AbsoluteExpiresAt: sessionEnd,
+			Offline:           params.OfflineAccess,
+		})
+		if err != nil {
+			return err
+		}
+		sessionID = id
+
+		_, err = a.refreshTokenRepository.SaveNewRefreshTokenTx(ctx, tx, models.RefreshToken{
+			UserID:    user.ID,
+			AppID:     app.ID,
+			SessionID: &id,
+			Value:     refreshTokenValue,
+			CreatedAt: now,
+			ExpiresAt: refreshTokenExpiresAt,
+		})
+		return err
+	})
+	if err != nil {
+		return contracts.TokensInfo{}, errs.Wrap(op, err)
+	}
+
+	accessToken, err := a.signer.NewAccessToken(user, app, authz, sessionID, now, ttlOr(app.AccessTokenTTL, a.accessTokenTTL))
 	if err != nil {
 		return contracts.TokensInfo{}, errs.WithKind(op, errs.Internal, err)
 	}
 
-	log.Info("user logged in successfully")
+	if err := a.userRepository.TouchAppMembership(ctx, user.ID, app.ID); err != nil {
+		log.WarnContext(ctx, "failed to record app membership", sl.Err(err))
+	}
+
+	log.InfoContext(ctx, "user logged in successfully")
 	tokensInfo = contracts.TokensInfo{
 		AccessToken:           accessToken,
 		RefreshToken:          refreshTokenValue,
 		RefreshTokenExpiresAt: refreshTokenExpiresAt,
+		SessionID:             sessionID,
 	}
 
 	return tokensInfo, nil
 }
 
-func (a *AuthService) Register(ctx context.Context, email, password string) (userId uuid.UUID, err error) {
+// Register signs a user up through an app, as far as the app's registration
+// policy allows. A valid invitation token admits the user under any policy
+// but disabled; it is consumed in the same transaction that creates the user
+// and grants the roles it carries.
+func (a *AuthService) Register(ctx context.Context, params contracts.RegisterParams) (userId uuid.UUID, err error) {
 	const op = "auth.Register"
+	ctx, span := tracing.Start(ctx, op)
+	var invitationID uuid.UUID
+	var knownAppID int
+	defer func() {
+		metrics.ObserveAuth("register", knownAppID, err)
+		var details map[string]any
+		if invitationID != uuid.Nil {
+			details = map[string]any{"invitation_id": invitationID}
+		}
+		a.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditRegister,
+			Actor:         subjectActor(userId),
+			SubjectUserID: uuidRef(userId),
+			AppID:         intRef(params.AppID),
+			Details:       details,
+		}, err)
+		tracing.End(span, err)
+	}()
+	reqctx.SetAppID(ctx, params.AppID)
 
-	log := a.log.With(slog.String("op", op), slog.String("email", email))
+	log := a.log.With(slog.String("op", op), slog.String("email", params.Email), slog.Int("appID", params.AppID))
 
-	log.Info("registering user")
+	log.InfoContext(ctx, "registering user")
 
-	passHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	email, err := a.emails.Normalize(params.Email)
 	if err != nil {
-		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
+		return uuid.UUID{}, errs.WithKind(op, errs.Invalid, err)
 	}
 
-	id, err := a.userRepository.CreateUser(ctx, email, passHash)
+	app, err := a.appRepository.GetAppById(ctx, params.AppID)
 	if err != nil {
-		if errs.KindOf(err) == errs.AlreadyExists {
-			return uuid.UUID{}, errs.WithKind(op, errs.AlreadyExists, err)
+		if errs.KindOf(err) == errs.NotFound {
+			return uuid.UUID{}, errs.WithKind(op, errs.Invalid, errors.New("unknown app"))
 		}
+		return uuid.UUID{}, errs.Wrap(op, err)
+	}
+	knownAppID = app.ID
+	if app.IsDisabled {
+		return uuid.UUID{}, errs.WithKind(op, errs.PermissionDenied, errors.New("app is disabled"))
+	}
+
+	invalidInvitation := errs.WithKind(op, errs.Invalid, errors.New("invalid or expired invitation"))
+	if params.InvitationToken != "" {
+		id, tokenAppID, err := a.signer.ParseInvitationToken(params.InvitationToken)
+		if err != nil || tokenAppID != app.ID {
+			return uuid.UUID{}, invalidInvitation
+		}
+		invitationID = id
+	}
+
+	if err := checkRegistration(op, app, email, invitationID != uuid.Nil); err != nil {
+		log.InfoContext(ctx, "registration refused", slog.String("policy", app.RegistrationPolicy))
+		return uuid.UUID{}, err
+	}
+
+	bcryptStart := time.Now()
+	passHash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+	metrics.BcryptDuration.WithLabelValues("hash").Observe(time.Since(bcryptStart).Seconds())
+	if err != nil {
 		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
 	}
 
-	log.Info("user registered")
+	var id uuid.UUID
+	err = a.uow.Do(ctx, func(tx pgx.Tx) error {
+		uid, err := a.userRepository.CreateUserTx(ctx, tx, email, passHash)
+		if err != nil {
+			return err
+		}
+		id = uid
+
+		if invitationID != uuid.Nil {
+			inv, err := a.appRepository.UseInvitationTx(ctx, tx, app.ID, invitationID, uid, time.Now().UTC())
+			if err != nil {
+				if errs.KindOf(err) == errs.NotFound {
+					return invalidInvitation
+				}
+				return err
+			}
+			if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+				return errs.WithKind(op, errs.PermissionDenied, errors.New("invitation is for another email address"))
+			}
+			if _, err := a.rbacRepository.AssignAppRolesTx(ctx, tx, uid, app.ID, inv.RoleIDs); err != nil {
+				return err
+			}
+		}
+
+		return a.outboxRepository.AddTx(ctx, tx, models.OutboxEvent{
+			Type:    models.EventUserRegistered,
+			Subject: uid.String(),
+			AppID:   &app.ID,
+			UserID:  &uid,
+			Data:    map[string]any{"user_id": uid, "email": email, "app_id": app.ID},
+		})
+	})
+	if err != nil {
+		return uuid.UUID{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "user registered")
 	return id, nil
+}
 
+// ChangePassword replaces the user's password after checking the current one.
+// It also clears a forced reset and revokes every refresh token the user holds.
+func (a *AuthService) ChangePassword(ctx context.Context, email, currentPassword, newPassword string) (err error) {
+	const op = "auth.ChangePassword"
+	ctx, span := tracing.Start(ctx, op)
+	var userID uuid.UUID
+	var revoked int64
+	defer func() {
+		a.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditPasswordChange,
+			Actor:         subjectActor(userID),
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"revoked_refresh_tokens": revoked},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := a.log.With(slog.String("op", op), slog.String("email", email))
+
+	email, err = a.emails.Normalize(email)
+	if err != nil {
+		return errs.WithKind(op, errs.Unauthenticated, err)
+	}
+
+	if newPassword == "" {
+		return errs.WithKind(op, errs.Invalid, errors.New("new password must not be empty"))
+	}
+
+	user, err := a.userRepository.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errs.KindOf(err) == errs.NotFound {
+			return errs.WithKind(op, errs.Unauthenticated, err)
+		}
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	userID = user.ID
+
+	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(currentPassword)); err != nil {
+		return errs.WithKind(op, errs.Unauthenticated, err)
+	}
+
+	switch user.Status {
+	case models.UserStatusActive:
+	case models.UserStatusDeleted:
+		return errs.WithKind(op, errs.Unauthenticated, errors.New("user is deleted"))
+	default:
+		return errs.WithKind(op, errs.PermissionDenied, errors.New("user is "+user.Status))
+	}
+
+	bcryptStart := time.Now()
+	passHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	metrics.BcryptDuration.WithLabelValues("hash").Observe(time.Since(bcryptStart).Seconds())
+	if err != nil {
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	err = a.uow.Do(ctx, func(tx pgx.Tx) error {
+		if err := a.userRepository.UpdatePasswordTx(ctx, tx, user.ID, passHash); err != nil {
+			return err
+		}
+		n, err := a.refreshTokenRepository.RevokeAllForUserTx(ctx, tx, user.ID)
+		revoked = n
+		return err
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "password changed")
+	return nil
+}
+
+// checkUserCanSignIn reports why the user may not obtain new tokens. Deleted
+// users are reported as unauthenticated so their existence is not revealed.
+func checkUserCanSignIn(op string, user models.User) error {
+	switch user.Status {
+	case models.UserStatusActive:
+	case models.UserStatusDeleted:
+		return errs.WithKind(op, errs.Unauthenticated, errors.New("user is deleted"))
+	default:
+		return errs.WithKind(op, errs.PermissionDenied, errors.New("user is "+user.Status))
+	}
+
+	if user.PasswordResetRequired {
+		return errs.WithKind(op, errs.PermissionDenied, errors.New("password reset required"))
+	}
+	return nil
+}
+
+// checkRegistration reports why email may not sign up through app. invited
+// tells whether a valid invitation token was presented.
+func checkRegistration(op string, app models.App, email string, invited bool) error {
+	switch app.RegistrationPolicy {
+	case models.RegistrationOpen:
+		return nil
+	case models.RegistrationInvitation:
+		if invited {
+			return nil
+		}
+	case models.RegistrationDomains:
+		if invited || app.AllowsRegistrationDomain(email) {
+			return nil
+		}
+	}
+	return errs.WithKind(op, errs.PermissionDenied, errors.New("registration through this app is not open"))
+}
+
+// appAuthorization loads what the user may do in app. An app owned by an
+// organization only admits its members, and their org role goes into the
+// token.
+func appAuthorization(ctx context.Context, op string, rbac RBACRepository, orgs OrgRepository, userID uuid.UUID, app models.App) (models.Authorization, error) {
+	var orgRole string
+	if app.OrgID != nil {
+		m, err := orgs.GetMembership(ctx, *app.OrgID, userID)
+		if err != nil {
+			if errs.KindOf(err) == errs.NotFound {
+				return models.Authorization{}, errs.WithKind(op, errs.PermissionDenied, errors.New("user is not a member of the app's organization"))
+			}
+			return models.Authorization{}, errs.Wrap(op, err)
+		}
+		orgRole = m.Role
+	}
+
+	authz, err := rbac.GetAuthorization(ctx, userID, app.ID)
+	if err != nil {
+		return models.Authorization{}, errs.Wrap(op, err)
+	}
+	authz.OrgID, authz.OrgRole = app.OrgID, orgRole
+	return authz, nil
+}
+
+// ttlOr returns the per-app override when one is set, def otherwise.
+func ttlOr(override, def time.Duration) time.Duration {
+	if override > 0 {
+		return override
+	}
+	return def
 }
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/emailaddr"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+)
+
+// InvitationService backs the admin API for registration invitations. The
+// signed token it hands out is what AuthService.Register consumes.
+type InvitationService struct {
+	appRepository  AppRepository
+	rbacRepository RBACRepository
+	signer         TokenSigner
+	audit          *auditor
+	emails         *emailaddr.Normalizer
+	log            *slog.Logger
+	defaultTTL     time.Duration
+	maxTTL         time.Duration
+}
+
+// NewInvitationService returns a new instance of the InvitationService
+func NewInvitationService(log *slog.Logger, repoContainer RepositoriesContainer, signer TokenSigner, cfg *config.Config) *InvitationService {
+	return &InvitationService{
+		appRepository:  repoContainer.AppRepo,
+		rbacRepository: repoContainer.RBACRepo,
+		signer:         signer,
+		audit:          newAuditor(log, repoContainer.AuditRepo),
+		emails:         emailaddr.NewNormalizer(cfg.Email.LocalPart),
+		log:            log,
+		defaultTTL:     cfg.Registration.InvitationTTL,
+		maxTTL:         cfg.Registration.MaxInvitationTTL,
+	}
+}
+
+func (s *InvitationService) CreateInvitation(ctx context.Context, appID int, params contracts.CreateAppInvitationParams) (created contracts.CreatedAppInvitation, err error) {
+	const op = "invitationService.CreateInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditAppInvitationCreate,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"invitation_id": created.ID, "role_ids": params.RoleIDs},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	app, err := s.appRepository.GetAppById(ctx, appID)
+	if err != nil {
+		return contracts.CreatedAppInvitation{}, errs.Wrap(op, err)
+	}
+	if app.RegistrationPolicy == models.RegistrationDisabled {
+		return contracts.CreatedAppInvitation{}, errs.WithKind(op, errs.Invalid, errors.New("app does not allow registration"))
+	}
+
+	inv := models.AppInvitation{AppID: appID, CreatedBy: actor(ctx)}
+	if params.Email != "" {
+		inv.Email, err = s.emails.Normalize(params.Email)
+		if err != nil {
+			return contracts.CreatedAppInvitation{}, errs.WithKind(op, errs.Invalid, err)
+		}
+	}
+
+	ttl := time.Duration(params.TTLSeconds) * time.Second
+	switch {
+	case ttl < 0 || ttl > s.maxTTL:
+		return contracts.CreatedAppInvitation{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("ttl must be between 0 and %s", s.maxTTL))
+	case ttl == 0:
+		ttl = s.defaultTTL
+	}
+	inv.ExpiresAt = time.Now().UTC().Add(ttl)
+
+	inv.RoleIDs = make([]uuid.UUID, 0, len(params.RoleIDs))
+	for _, id := range params.RoleIDs {
+		if slices.Contains(inv.RoleIDs, id) {
+			continue
+		}
+		if _, err := s.rbacRepository.GetRole(ctx, appID, id); err != nil {
+			if errs.KindOf(err) == errs.NotFound {
+				return contracts.CreatedAppInvitation{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("unknown role %s", id))
+			}
+			return contracts.CreatedAppInvitation{}, errs.Wrap(op, err)
+		}
+		inv.RoleIDs = append(inv.RoleIDs, id)
+	}
+
+	inv, err = s.appRepository.CreateInvitation(ctx, inv)
+	if err != nil {
+		return contracts.CreatedAppInvitation{}, errs.Wrap(op, err)
+	}
+
+	token, err := s.signer.NewInvitationToken(inv)
+	if err != nil {
+		return contracts.CreatedAppInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	s.log.InfoContext(ctx, "app invitation created", slog.String("op", op), slog.String("admin", adminName(ctx)),
+		slog.Int("appID", appID), slog.String("invitationID", inv.ID.String()))
+	return contracts.CreatedAppInvitation{AppInvitationInfo: toAppInvitationInfo(inv), Token: token}, nil
+}
+
+// ListInvitations returns the invitations not used yet, expired ones
+// included.
+func (s *InvitationService) ListInvitations(ctx context.Context, appID int) (infos []contracts.AppInvitationInfo, err error) {
+	const op = "invitationService.ListInvitations"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.appRepository.GetAppById(ctx, appID); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	invitations, err := s.appRepository.ListInvitations(ctx, appID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.AppInvitationInfo, 0, len(invitations))
+	for _, inv := range invitations {
+		infos = append(infos, toAppInvitationInfo(inv))
+	}
+	return infos, nil
+}
+
+// RevokeInvitation deletes an unused invitation so its token stops working.
+func (s *InvitationService) RevokeInvitation(ctx context.Context, appID int, id uuid.UUID) (err error) {
+	const op = "invitationService.RevokeInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditAppInvitationRevoke,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"invitation_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := s.appRepository.DeleteInvitation(ctx, appID, id); err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+func toAppInvitationInfo(inv models.AppInvitation) contracts.AppInvitationInfo {
+	return contracts.AppInvitationInfo{
+		ID:        inv.ID,
+		AppID:     inv.AppID,
+		Email:     inv.Email,
+		RoleIDs:   inv.RoleIDs,
+		CreatedBy: inv.CreatedBy,
+		CreatedAt: inv.CreatedAt,
+		ExpiresAt: inv.ExpiresAt,
+	}
+}
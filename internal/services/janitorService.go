@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/tracing"
+)
+
+// JanitorService deletes rows that can no longer be used: expired and
+// revoked refresh tokens and sessions, spent or expired invitations and
+// email changes, and the history of old job runs.
+type JanitorService struct {
+	janitorRepository JanitorRepository
+	log               *slog.Logger
+	batchSize         int
+	maxBatches        int
+	tokenRetention    time.Duration
+	retention         time.Duration
+	historyRetention  time.Duration
+}
+
+// NewJanitorService returns a new instance of the JanitorService
+func NewJanitorService(log *slog.Logger, repoContainer RepositoriesContainer, cfg *config.Config) *JanitorService {
+	return &JanitorService{
+		janitorRepository: repoContainer.JanitorRepo,
+		log:               log,
+		batchSize:         cfg.Janitor.BatchSize,
+		maxBatches:        cfg.Janitor.MaxBatches,
+		tokenRetention:    cfg.Janitor.TokenRetention,
+		retention:         cfg.Janitor.Retention,
+		historyRetention:  cfg.Scheduler.HistoryRetention,
+	}
+}
+
+// Sweep purges every artifact once. The scheduler makes sure only one
+// replica sweeps at a time.
+func (s *JanitorService) Sweep(ctx context.Context) (err error) {
+	const op = "janitorService.Sweep"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	now := time.Now().UTC()
+	// Sessions go first: deleting one takes its refresh tokens along.
+	cutoffs := []struct {
+		artifact string
+		before   time.Time
+	}{
+		{models.ArtifactSessions, now.Add(-s.tokenRetention)},
+		{models.ArtifactRefreshTokens, now.Add(-s.tokenRetention)},
+		{models.ArtifactEmailChanges, now.Add(-s.retention)},
+		{models.ArtifactAppInvitations, now.Add(-s.retention)},
+		{models.ArtifactOrgInvitations, now.Add(-s.retention)},
+		{models.ArtifactJobRuns, now.Add(-s.historyRetention)},
+	}
+
+	for _, c := range cutoffs {
+		purged, err := s.purge(ctx, c.artifact, c.before)
+		if purged > 0 {
+			s.log.InfoContext(ctx, "janitor purged rows", slog.String("artifact", c.artifact), slog.Int64("rows", purged))
+		}
+		if err != nil {
+			return errs.Wrap(op, err)
+		}
+	}
+	return nil
+}
+
+// purge deletes dead rows of artifact batch by batch until a batch comes
+// back short or the per-run batch limit is reached.
+func (s *JanitorService) purge(ctx context.Context, artifact string, before time.Time) (int64, error) {
+	var total int64
+	for range s.maxBatches {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		n, err := s.janitorRepository.Purge(ctx, artifact, before, s.batchSize)
+		total += n
+		metrics.JanitorPurged.WithLabelValues(artifact).Add(float64(n))
+		if err != nil {
+			return total, err
+		}
+		if n < int64(s.batchSize) {
+			break
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,692 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/emailaddr"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/mailer"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	tokenGen "github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxOrgNameLength = 100
+
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// OrgService manages organizations, their members and invitations. The
+// organization CRUD is for service admins; member and invitation management
+// is also open to the organization's own owners and admins, and the service
+// decides from the caller in the context what they may do. Service admins
+// act as owners of every organization, and only they add members directly;
+// everyone else joins through an invitation.
+type OrgService struct {
+	orgRepository  OrgRepository
+	userRepository UserRepository
+	uow            UnitOfWork
+	mailer         Mailer
+	audit          *auditor
+	emails         *emailaddr.Normalizer
+	log            *slog.Logger
+	invitationTTL  time.Duration
+	acceptURL      string
+}
+
+// NewOrgService returns a new instance of the OrgService
+func NewOrgService(log *slog.Logger, repoContainer RepositoriesContainer, mailer Mailer, cfg *config.Config) *OrgService {
+	return &OrgService{
+		orgRepository:  repoContainer.OrgRepo,
+		userRepository: repoContainer.UserRepo,
+		uow:            repoContainer.Uow,
+		mailer:         mailer,
+		audit:          newAuditor(log, repoContainer.AuditRepo),
+		emails:         emailaddr.NewNormalizer(cfg.Email.LocalPart),
+		log:            log,
+		invitationTTL:  cfg.Orgs.InvitationTTL,
+		acceptURL:      cfg.Orgs.AcceptURL,
+	}
+}
+
+func (s *OrgService) CreateOrg(ctx context.Context, params contracts.CreateOrgParams) (info contracts.OrgInfo, err error) {
+	const op = "orgService.CreateOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditOrgCreate,
+			SubjectUserID: params.OwnerID,
+			Details:       map[string]any{"org_id": info.ID, "slug": params.Slug},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	org := models.Organization{Name: strings.TrimSpace(params.Name), Slug: params.Slug}
+	if err := validateOrg(org); err != nil {
+		return contracts.OrgInfo{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		created, err := s.orgRepository.CreateOrgTx(ctx, tx, org)
+		if err != nil {
+			return err
+		}
+		org = created
+
+		if params.OwnerID == nil {
+			return nil
+		}
+		return s.orgRepository.PutMembershipTx(ctx, tx, org.ID, *params.OwnerID, models.OrgRoleOwner)
+	})
+	if err != nil {
+		return contracts.OrgInfo{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization created", slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("orgID", org.ID.String()))
+	return toOrgInfo(org), nil
+}
+
+func (s *OrgService) ListOrgs(ctx context.Context) (infos []contracts.OrgInfo, err error) {
+	const op = "orgService.ListOrgs"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	orgs, err := s.orgRepository.ListOrgs(ctx)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.OrgInfo, 0, len(orgs))
+	for _, o := range orgs {
+		infos = append(infos, toOrgInfo(o))
+	}
+	return infos, nil
+}
+
+func (s *OrgService) GetOrg(ctx context.Context, id uuid.UUID) (info contracts.OrgInfo, err error) {
+	const op = "orgService.GetOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	org, err := s.orgRepository.GetOrg(ctx, id)
+	if err != nil {
+		return contracts.OrgInfo{}, errs.Wrap(op, err)
+	}
+
+	return toOrgInfo(org), nil
+}
+
+func (s *OrgService) UpdateOrg(ctx context.Context, id uuid.UUID, params contracts.UpdateOrgParams) (info contracts.OrgInfo, err error) {
+	const op = "orgService.UpdateOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditOrgUpdate,
+			Details:   map[string]any{"org_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	org, err := s.orgRepository.GetOrg(ctx, id)
+	if err != nil {
+		return contracts.OrgInfo{}, errs.Wrap(op, err)
+	}
+
+	if params.Name != nil {
+		org.Name = strings.TrimSpace(*params.Name)
+	}
+	if params.Slug != nil {
+		org.Slug = *params.Slug
+	}
+	if err := validateOrg(org); err != nil {
+		return contracts.OrgInfo{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	org, err = s.orgRepository.UpdateOrg(ctx, org)
+	if err != nil {
+		return contracts.OrgInfo{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization updated", slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("orgID", id.String()))
+	return toOrgInfo(org), nil
+}
+
+// DeleteOrg removes the organization, its memberships and invitations. Apps
+// it owns must be released first.
+func (s *OrgService) DeleteOrg(ctx context.Context, id uuid.UUID) (err error) {
+	const op = "orgService.DeleteOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditOrgDelete,
+			Details:   map[string]any{"org_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := s.orgRepository.DeleteOrg(ctx, id); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization deleted", slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("orgID", id.String()))
+	return nil
+}
+
+func (s *OrgService) ListMembers(ctx context.Context, orgID uuid.UUID) (infos []contracts.OrgMemberInfo, err error) {
+	const op = "orgService.ListMembers"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.managerRole(ctx, op, orgID, s.orgRepository.GetMembership); err != nil {
+		return nil, err
+	}
+
+	members, err := s.orgRepository.ListMembers(ctx, orgID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.OrgMemberInfo, 0, len(members))
+	for _, m := range members {
+		infos = append(infos, toOrgMemberInfo(m))
+	}
+	return infos, nil
+}
+
+// AddMember adds an existing user to the organization. It is for service
+// admins only: organization owners and admins invite people instead, so
+// nobody becomes a member without accepting.
+func (s *OrgService) AddMember(ctx context.Context, orgID uuid.UUID, params contracts.AddOrgMemberParams) (info contracts.OrgMemberInfo, err error) {
+	const op = "orgService.AddMember"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditOrgMemberAdd,
+			SubjectUserID: uuidRef(params.UserID),
+			Details:       map[string]any{"org_id": orgID, "role": params.Role},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if _, ok := reqctx.Admin(ctx); !ok {
+		return contracts.OrgMemberInfo{}, errs.WithKind(op, errs.PermissionDenied, errors.New("members join through invitations"))
+	}
+	if !models.IsValidOrgRole(params.Role) {
+		return contracts.OrgMemberInfo{}, errs.WithKind(op, errs.Invalid, errors.New("invalid organization role"))
+	}
+
+	if _, err := s.orgRepository.GetOrg(ctx, orgID); err != nil {
+		return contracts.OrgMemberInfo{}, errs.Wrap(op, err)
+	}
+
+	var member models.OrgMembership
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		_, err := s.orgRepository.GetMembershipTx(ctx, tx, orgID, params.UserID)
+		switch {
+		case err == nil:
+			return errs.WithKind(op, errs.AlreadyExists, errors.New("user is already a member"))
+		case errs.KindOf(err) != errs.NotFound:
+			return err
+		}
+
+		if err := s.orgRepository.PutMembershipTx(ctx, tx, orgID, params.UserID, params.Role); err != nil {
+			return err
+		}
+		member, err = s.orgRepository.GetMembershipTx(ctx, tx, orgID, params.UserID)
+		return err
+	})
+	if err != nil {
+		return contracts.OrgMemberInfo{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization member added", slog.String("op", op), slog.String("actor", actor(ctx)),
+		slog.String("orgID", orgID.String()), slog.String("userID", params.UserID.String()))
+	return toOrgMemberInfo(member), nil
+}
+
+// UpdateMember changes the member's role. Only owners touch the owner role,
+// and the last owner cannot step down.
+func (s *OrgService) UpdateMember(ctx context.Context, orgID, userID uuid.UUID, params contracts.UpdateOrgMemberParams) (info contracts.OrgMemberInfo, err error) {
+	const op = "orgService.UpdateMember"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditOrgMemberUpdate,
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"org_id": orgID, "role": params.Role},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if !models.IsValidOrgRole(params.Role) {
+		return contracts.OrgMemberInfo{}, errs.WithKind(op, errs.Invalid, errors.New("invalid organization role"))
+	}
+
+	var member models.OrgMembership
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		role, err := s.managerRole(ctx, op, orgID, s.membershipTx(tx))
+		if err != nil {
+			return err
+		}
+
+		current, err := s.orgRepository.GetMembershipTx(ctx, tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if (current.Role == models.OrgRoleOwner || params.Role == models.OrgRoleOwner) && role != models.OrgRoleOwner {
+			return errs.WithKind(op, errs.PermissionDenied, errors.New("only owners may change owners"))
+		}
+		if current.Role == models.OrgRoleOwner && params.Role != models.OrgRoleOwner {
+			if err := s.ensureOtherOwnerTx(ctx, tx, op, orgID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.orgRepository.PutMembershipTx(ctx, tx, orgID, userID, params.Role); err != nil {
+			return err
+		}
+		member, err = s.orgRepository.GetMembershipTx(ctx, tx, orgID, userID)
+		return err
+	})
+	if err != nil {
+		return contracts.OrgMemberInfo{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization member updated", slog.String("op", op), slog.String("actor", actor(ctx)),
+		slog.String("orgID", orgID.String()), slog.String("userID", userID.String()), slog.String("role", params.Role))
+	return toOrgMemberInfo(member), nil
+}
+
+// RemoveMember takes the user out of the organization. Any member may leave
+// on their own; removing others takes a manager, and an owner only an owner.
+// The last owner cannot be removed.
+func (s *OrgService) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) (err error) {
+	const op = "orgService.RemoveMember"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditOrgMemberRemove,
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"org_id": orgID},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		current, err := s.orgRepository.GetMembershipTx(ctx, tx, orgID, userID)
+		if err != nil && errs.KindOf(err) != errs.NotFound {
+			return err
+		}
+
+		if self, ok := reqctx.User(ctx); !ok || self != userID {
+			role, err := s.managerRole(ctx, op, orgID, s.membershipTx(tx))
+			if err != nil {
+				return err
+			}
+			if current.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+				return errs.WithKind(op, errs.PermissionDenied, errors.New("only owners may remove owners"))
+			}
+		}
+		if current.Role == models.OrgRoleOwner {
+			if err := s.ensureOtherOwnerTx(ctx, tx, op, orgID); err != nil {
+				return err
+			}
+		}
+
+		return s.orgRepository.DeleteMembershipTx(ctx, tx, orgID, userID)
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization member removed", slog.String("op", op), slog.String("actor", actor(ctx)),
+		slog.String("orgID", orgID.String()), slog.String("userID", userID.String()))
+	return nil
+}
+
+// CreateInvitation mails an invitation token to email. Accepting it makes
+// the user holding that address a member with role. A new invitation to the
+// same address replaces the pending one.
+func (s *OrgService) CreateInvitation(ctx context.Context, orgID uuid.UUID, params contracts.CreateOrgInvitationParams) (info contracts.OrgInvitationInfo, err error) {
+	const op = "orgService.CreateInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditOrgInvite,
+			Details:   map[string]any{"org_id": orgID, "invitation_id": info.ID, "role": params.Role},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("orgID", orgID.String()))
+
+	if !models.IsValidOrgRole(params.Role) {
+		return contracts.OrgInvitationInfo{}, errs.WithKind(op, errs.Invalid, errors.New("invalid organization role"))
+	}
+	email, err := s.emails.Normalize(params.Email)
+	if err != nil {
+		return contracts.OrgInvitationInfo{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	token, err := tokenGen.NewSecret()
+	if err != nil {
+		return contracts.OrgInvitationInfo{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	var org models.Organization
+	var inv models.OrgInvitation
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		role, err := s.managerRole(ctx, op, orgID, s.membershipTx(tx))
+		if err != nil {
+			return err
+		}
+		if params.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			return errs.WithKind(op, errs.PermissionDenied, errors.New("only owners may invite owners"))
+		}
+
+		user, err := s.userRepository.GetUserByEmail(ctx, email)
+		switch {
+		case err == nil:
+			if _, err := s.orgRepository.GetMembershipTx(ctx, tx, orgID, user.ID); err == nil {
+				return errs.WithKind(op, errs.AlreadyExists, errors.New("user is already a member"))
+			} else if errs.KindOf(err) != errs.NotFound {
+				return err
+			}
+		case errs.KindOf(err) != errs.NotFound:
+			return err
+		}
+
+		org, err = s.orgRepository.GetOrg(ctx, orgID)
+		if err != nil {
+			return err
+		}
+		inv, err = s.orgRepository.ReplaceInvitationTx(ctx, tx, models.OrgInvitation{
+			OrgID:     orgID,
+			Email:     email,
+			Role:      params.Role,
+			TokenHash: hashToken(token),
+			InvitedBy: actor(ctx),
+			ExpiresAt: time.Now().UTC().Add(s.invitationTTL),
+		})
+		return err
+	})
+	if err != nil {
+		return contracts.OrgInvitationInfo{}, errs.Wrap(op, err)
+	}
+
+	if err := s.mailer.Send(ctx, s.invitationMessage(email, org, token, inv.ExpiresAt)); err != nil {
+		log.ErrorContext(ctx, "failed to send invitation", sl.Err(err))
+		return contracts.OrgInvitationInfo{}, errs.WithKind(op, errs.Unavailable, err)
+	}
+
+	log.InfoContext(ctx, "organization invitation sent", slog.String("actor", actor(ctx)), slog.String("invitationID", inv.ID.String()))
+	return toOrgInvitationInfo(inv), nil
+}
+
+// ListInvitations returns the invitations not accepted yet, expired ones
+// included.
+func (s *OrgService) ListInvitations(ctx context.Context, orgID uuid.UUID) (infos []contracts.OrgInvitationInfo, err error) {
+	const op = "orgService.ListInvitations"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.managerRole(ctx, op, orgID, s.orgRepository.GetMembership); err != nil {
+		return nil, err
+	}
+
+	invitations, err := s.orgRepository.ListPendingInvitations(ctx, orgID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.OrgInvitationInfo, 0, len(invitations))
+	for _, inv := range invitations {
+		infos = append(infos, toOrgInvitationInfo(inv))
+	}
+	return infos, nil
+}
+
+func (s *OrgService) RevokeInvitation(ctx context.Context, orgID, id uuid.UUID) (err error) {
+	const op = "orgService.RevokeInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditOrgInviteRevoke,
+			Details:   map[string]any{"org_id": orgID, "invitation_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		role, err := s.managerRole(ctx, op, orgID, s.membershipTx(tx))
+		if err != nil {
+			return err
+		}
+
+		inv, err := s.orgRepository.GetInvitationTx(ctx, tx, orgID, id)
+		if err != nil {
+			return err
+		}
+		if inv.Role == models.OrgRoleOwner && role != models.OrgRoleOwner {
+			return errs.WithKind(op, errs.PermissionDenied, errors.New("only owners may revoke owner invitations"))
+		}
+
+		return s.orgRepository.DeleteInvitationTx(ctx, tx, orgID, id)
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+// AcceptInvitation makes the user a member of the organization the token
+// invites to. The invitation must be addressed to the user's own email.
+// Members keep their current role.
+func (s *OrgService) AcceptInvitation(ctx context.Context, userID uuid.UUID, token string) (info contracts.UserOrgInfo, err error) {
+	const op = "orgService.AcceptInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	var inv models.OrgInvitation
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditOrgInviteAccept,
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"org_id": inv.OrgID, "invitation_id": inv.ID},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	invalid := errs.WithKind(op, errs.Invalid, errors.New("invalid or expired token"))
+	var role string
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		found, err := s.orgRepository.GetInvitationByTokenHashTx(ctx, tx, hashToken(token))
+		if err != nil {
+			if errs.KindOf(err) == errs.NotFound {
+				return invalid
+			}
+			return err
+		}
+		now := time.Now().UTC()
+		if found.AcceptedAt != nil || now.After(found.ExpiresAt) {
+			return invalid
+		}
+		inv = found
+
+		user, err := s.userRepository.GetUserByIDTx(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := checkUserCanSignIn(op, user); err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, inv.Email) {
+			return errs.WithKind(op, errs.PermissionDenied, errors.New("invitation is for another email address"))
+		}
+
+		role = inv.Role
+		current, err := s.orgRepository.GetMembershipTx(ctx, tx, inv.OrgID, userID)
+		switch {
+		case err == nil:
+			role = current.Role
+		case errs.KindOf(err) == errs.NotFound:
+			if err := s.orgRepository.PutMembershipTx(ctx, tx, inv.OrgID, userID, inv.Role); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return s.orgRepository.MarkInvitationAcceptedTx(ctx, tx, inv.ID, userID, now)
+	})
+	if err != nil {
+		return contracts.UserOrgInfo{}, errs.Wrap(op, err)
+	}
+
+	org, err := s.orgRepository.GetOrg(ctx, inv.OrgID)
+	if err != nil {
+		return contracts.UserOrgInfo{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "organization invitation accepted", slog.String("op", op),
+		slog.String("orgID", org.ID.String()), slog.String("userID", userID.String()))
+	return contracts.UserOrgInfo{OrgInfo: toOrgInfo(org), Role: role}, nil
+}
+
+// ListUserOrgs returns the organizations the user belongs to.
+func (s *OrgService) ListUserOrgs(ctx context.Context, userID uuid.UUID) (infos []contracts.UserOrgInfo, err error) {
+	const op = "orgService.ListUserOrgs"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	orgs, err := s.orgRepository.ListUserOrgs(ctx, userID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.UserOrgInfo, 0, len(orgs))
+	for _, o := range orgs {
+		infos = append(infos, contracts.UserOrgInfo{OrgInfo: toOrgInfo(o.Organization), Role: o.Role})
+	}
+	return infos, nil
+}
+
+// managerRole returns the role the caller manages the organization with.
+// Service admins act as owners. Users who are not members get NotFound so
+// the organization is not revealed to them; plain members get
+// PermissionDenied.
+func (s *OrgService) managerRole(ctx context.Context, op string, orgID uuid.UUID,
+	membership func(ctx context.Context, orgID, userID uuid.UUID) (models.OrgMembership, error),
+) (string, error) {
+	if _, ok := reqctx.Admin(ctx); ok {
+		if _, err := s.orgRepository.GetOrg(ctx, orgID); err != nil {
+			return "", errs.Wrap(op, err)
+		}
+		return models.OrgRoleOwner, nil
+	}
+
+	userID, ok := reqctx.User(ctx)
+	if !ok {
+		return "", errs.WithKind(op, errs.Unauthenticated, errors.New("no authenticated caller"))
+	}
+	m, err := membership(ctx, orgID, userID)
+	if err != nil {
+		return "", errs.Wrap(op, err)
+	}
+	if !models.CanManageOrg(m.Role) {
+		return "", errs.WithKind(op, errs.PermissionDenied, errors.New("organization role "+m.Role+" may not manage members"))
+	}
+	return m.Role, nil
+}
+
+func (s *OrgService) membershipTx(tx pgx.Tx) func(ctx context.Context, orgID, userID uuid.UUID) (models.OrgMembership, error) {
+	return func(ctx context.Context, orgID, userID uuid.UUID) (models.OrgMembership, error) {
+		return s.orgRepository.GetMembershipTx(ctx, tx, orgID, userID)
+	}
+}
+
+// ensureOtherOwnerTx fails with Conflict when the organization has a single
+// owner, who is about to be demoted or removed.
+func (s *OrgService) ensureOtherOwnerTx(ctx context.Context, tx pgx.Tx, op string, orgID uuid.UUID) error {
+	owners, err := s.orgRepository.CountOwnersTx(ctx, tx, orgID)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return errs.WithKind(op, errs.Conflict, errors.New("an organization needs at least one owner"))
+	}
+	return nil
+}
+
+func (s *OrgService) invitationMessage(to string, org models.Organization, token string, expiresAt time.Time) mailer.Message {
+	accept := token
+	if s.acceptURL != "" {
+		u, err := url.Parse(s.acceptURL)
+		if err == nil {
+			q := u.Query()
+			q.Set("token", token)
+			u.RawQuery = q.Encode()
+			accept = u.String()
+		}
+	}
+
+	return mailer.Message{
+		To:      to,
+		Subject: "You are invited to join " + org.Name,
+		Body: "You have been invited to join the organization " + org.Name + ".\n\n" +
+			"To accept, sign in with this email address and use:\n\n" + accept + "\n\n" +
+			"This expires at " + expiresAt.Format(time.RFC1123) + ". If you did not expect it, ignore this email.\n",
+	}
+}
+
+func validateOrg(org models.Organization) error {
+	if org.Name == "" || utf8.RuneCountInString(org.Name) > maxOrgNameLength {
+		return errors.New("organization name must be 1 to 100 characters")
+	}
+	if !orgSlugPattern.MatchString(org.Slug) {
+		return errors.New("slug must be 2 to 63 lowercase letters, digits or dashes")
+	}
+	return nil
+}
+
+func toOrgInfo(o models.Organization) contracts.OrgInfo {
+	return contracts.OrgInfo{
+		ID:        o.ID,
+		Name:      o.Name,
+		Slug:      o.Slug,
+		CreatedAt: o.CreatedAt,
+		UpdatedAt: o.UpdatedAt,
+	}
+}
+
+func toOrgMemberInfo(m models.OrgMembership) contracts.OrgMemberInfo {
+	return contracts.OrgMemberInfo{
+		UserID:    m.UserID,
+		Email:     m.Email,
+		Role:      m.Role,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+func toOrgInvitationInfo(inv models.OrgInvitation) contracts.OrgInvitationInfo {
+	return contracts.OrgInvitationInfo{
+		ID:        inv.ID,
+		OrgID:     inv.OrgID,
+		Email:     inv.Email,
+		Role:      inv.Role,
+		InvitedBy: inv.InvitedBy,
+		CreatedAt: inv.CreatedAt,
+		ExpiresAt: inv.ExpiresAt,
+	}
+}
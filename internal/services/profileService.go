@@ -0,0 +1,453 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/blob"
+	"github.com/finaptica/sso/internal/lib/emailaddr"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/mailer"
+	tokenGen "github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// avatarTypes maps the accepted sniffed image types to a file extension.
+var avatarTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ProfileService lets a signed-in user manage their own profile.
+type ProfileService struct {
+	userRepository         UserRepository
+	refreshTokenRepository RefreshTokenRepository
+	emailChangeRepository  EmailChangeRepository
+	outboxRepository       OutboxRepository
+	uow                    UnitOfWork
+	blobs                  BlobStore
+	mailer                 Mailer
+	audit                  *auditor
+	emails                 *emailaddr.Normalizer
+	log                    *slog.Logger
+	maxAvatarBytes         int64
+	emailChangeTTL         time.Duration
+	emailConfirmURL        string
+}
+
+// NewProfileService returns a new instance of the ProfileService
+func NewProfileService(log *slog.Logger, repoContainer RepositoriesContainer, blobs BlobStore, mailer Mailer, cfg *config.Config) *ProfileService {
+	return &ProfileService{
+		userRepository:         repoContainer.UserRepo,
+		refreshTokenRepository: repoContainer.RtsRepo,
+		emailChangeRepository:  repoContainer.EmailChangeRepo,
+		outboxRepository:       repoContainer.OutboxRepo,
+		uow:                    repoContainer.Uow,
+		blobs:                  blobs,
+		mailer:                 mailer,
+		audit:                  newAuditor(log, repoContainer.AuditRepo),
+		emails:                 emailaddr.NewNormalizer(cfg.Email.LocalPart),
+		log:                    log,
+		maxAvatarBytes:         cfg.Avatar.MaxBytes,
+		emailChangeTTL:         cfg.EmailChange.TokenTTL,
+		emailConfirmURL:        cfg.EmailChange.ConfirmURL,
+	}
+}
+
+func (s *ProfileService) GetProfile(ctx context.Context, userID uuid.UUID) (info contracts.ProfileInfo, err error) {
+	const op = "profileService.GetProfile"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.activeUser(ctx, op, userID)
+	if err != nil {
+		return contracts.ProfileInfo{}, err
+	}
+
+	return toProfileInfo(user), nil
+}
+
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID uuid.UUID, params contracts.UpdateUserParams) (info contracts.ProfileInfo, err error) {
+	const op = "profileService.UpdateProfile"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	log := s.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+
+	user, err := s.activeUser(ctx, op, userID)
+	if err != nil {
+		return contracts.ProfileInfo{}, err
+	}
+
+	if err := applyProfileParams(&user, params); err != nil {
+		return contracts.ProfileInfo{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	if err := s.userRepository.UpdateProfile(ctx, userID, user.Name, user.Surname); err != nil {
+		return contracts.ProfileInfo{}, errs.Wrap(op, err)
+	}
+
+	user, err = s.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return contracts.ProfileInfo{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "profile updated")
+	return toProfileInfo(user), nil
+}
+
+// UploadAvatar validates the image read from r and makes it the user's
+// avatar, removing the previous one.
+func (s *ProfileService) UploadAvatar(ctx context.Context, userID uuid.UUID, r io.Reader) (info contracts.ProfileInfo, err error) {
+	const op = "profileService.UploadAvatar"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	log := s.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+
+	user, err := s.activeUser(ctx, op, userID)
+	if err != nil {
+		return contracts.ProfileInfo{}, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.maxAvatarBytes+1))
+	if err != nil {
+		return contracts.ProfileInfo{}, errs.WithKind(op, errs.Invalid, errors.New("failed to read avatar"))
+	}
+	if len(data) == 0 {
+		return contracts.ProfileInfo{}, errs.WithKind(op, errs.Invalid, errors.New("avatar is empty"))
+	}
+	if int64(len(data)) > s.maxAvatarBytes {
+		return contracts.ProfileInfo{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("avatar must be at most %d bytes", s.maxAvatarBytes))
+	}
+
+	// Trust the bytes, not the client supplied content type.
+	contentType := http.DetectContentType(data)
+	ext, ok := avatarTypes[contentType]
+	if !ok {
+		return contracts.ProfileInfo{}, errs.WithKind(op, errs.Invalid, errors.New("avatar must be a PNG, JPEG, GIF or WebP image"))
+	}
+
+	key := fmt.Sprintf("avatars/%s/%s%s", userID, uuid.New(), ext)
+	if err := s.blobs.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		log.ErrorContext(ctx, "failed to store avatar", sl.Err(err))
+		return contracts.ProfileInfo{}, errs.WithKind(op, errs.Unavailable, err)
+	}
+
+	// The replaced key is read under the row lock, so concurrent uploads each
+	// delete the blob they actually replaced, and only once that is committed.
+	previous, err := s.swapAvatarKey(ctx, userID, key)
+	if err != nil {
+		s.deleteBlob(context.WithoutCancel(ctx), log, key)
+		return contracts.ProfileInfo{}, errs.Wrap(op, err)
+	}
+	if previous != "" {
+		s.deleteBlob(ctx, log, previous)
+	}
+
+	user, err = s.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return contracts.ProfileInfo{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "avatar uploaded", slog.String("contentType", contentType), slog.Int("bytes", len(data)))
+	return toProfileInfo(user), nil
+}
+
+// GetAvatar opens the user's avatar. The caller closes the reader.
+func (s *ProfileService) GetAvatar(ctx context.Context, userID uuid.UUID) (rc io.ReadCloser, info blob.Info, err error) {
+	const op = "profileService.GetAvatar"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.activeUser(ctx, op, userID)
+	if err != nil {
+		return nil, blob.Info{}, err
+	}
+	if user.AvatarKey == "" {
+		return nil, blob.Info{}, errs.WithKind(op, errs.NotFound, errors.New("user has no avatar"))
+	}
+
+	rc, info, err = s.blobs.Get(ctx, user.AvatarKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return nil, blob.Info{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		return nil, blob.Info{}, errs.WithKind(op, errs.Unavailable, err)
+	}
+
+	return rc, info, nil
+}
+
+func (s *ProfileService) DeleteAvatar(ctx context.Context, userID uuid.UUID) (err error) {
+	const op = "profileService.DeleteAvatar"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	log := s.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+
+	user, err := s.activeUser(ctx, op, userID)
+	if err != nil {
+		return err
+	}
+	if user.AvatarKey == "" {
+		return nil
+	}
+
+	previous, err := s.swapAvatarKey(ctx, userID, "")
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	if previous != "" {
+		s.deleteBlob(ctx, log, previous)
+	}
+
+	log.InfoContext(ctx, "avatar deleted")
+	return nil
+}
+
+// RequestEmailChange starts moving the user to newEmail. The change only takes
+// effect once the token mailed to the new address is confirmed; the current
+// address is told about the request.
+func (s *ProfileService) RequestEmailChange(ctx context.Context, userID uuid.UUID, newEmail, currentPassword string) (err error) {
+	const op = "profileService.RequestEmailChange"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditEmailChangeRequest,
+			SubjectUserID: uuidRef(userID),
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+
+	user, err := s.activeUser(ctx, op, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.PassHash, []byte(currentPassword)); err != nil {
+		return errs.WithKind(op, errs.Unauthenticated, err)
+	}
+
+	newEmail, err = s.emails.Normalize(newEmail)
+	if err != nil {
+		return errs.WithKind(op, errs.Invalid, err)
+	}
+	if newEmail == user.Email {
+		return errs.WithKind(op, errs.Invalid, errors.New("new email is the current email"))
+	}
+
+	// The unique index is what really guards the address; this only spares
+	// the user a confirmation that could never succeed. A change of case
+	// keeps the user's own address, which is not a clash.
+	if !strings.EqualFold(newEmail, user.Email) {
+		taken, err := s.userRepository.IsUserExistByEmail(ctx, newEmail)
+		if err != nil {
+			return errs.Wrap(op, err)
+		}
+		if taken {
+			return errs.WithKind(op, errs.AlreadyExists, errors.New("email is already in use"))
+		}
+	}
+
+	token, err := tokenGen.NewSecret()
+	if err != nil {
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	expiresAt := time.Now().UTC().Add(s.emailChangeTTL)
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		_, err := s.emailChangeRepository.ReplacePendingTx(ctx, tx, models.EmailChange{
+			UserID:    userID,
+			NewEmail:  newEmail,
+			TokenHash: hashToken(token),
+			ExpiresAt: expiresAt,
+		})
+		return err
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	if err := s.mailer.Send(ctx, s.confirmEmailMessage(newEmail, token, expiresAt)); err != nil {
+		log.ErrorContext(ctx, "failed to send email confirmation", sl.Err(err))
+		return errs.WithKind(op, errs.Unavailable, err)
+	}
+	if err := s.mailer.Send(ctx, emailChangeRequestedMessage(user.Email, newEmail)); err != nil {
+		log.WarnContext(ctx, "failed to notify current email address", sl.Err(err))
+	}
+
+	log.InfoContext(ctx, "email change requested")
+	return nil
+}
+
+// ConfirmEmailChange applies the email change the token was issued for and
+// revokes every refresh token of the user.
+func (s *ProfileService) ConfirmEmailChange(ctx context.Context, token string) (err error) {
+	const op = "profileService.ConfirmEmailChange"
+	ctx, span := tracing.Start(ctx, op)
+	var userID uuid.UUID
+	var revoked int64
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditEmailChange,
+			Actor:         subjectActor(userID),
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"revoked_refresh_tokens": revoked},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op))
+
+	invalid := errs.WithKind(op, errs.Invalid, errors.New("invalid or expired token"))
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		change, err := s.emailChangeRepository.GetByTokenHashTx(ctx, tx, hashToken(token))
+		if err != nil {
+			if errs.KindOf(err) == errs.NotFound {
+				return invalid
+			}
+			return err
+		}
+		now := time.Now().UTC()
+		if change.ConfirmedAt != nil || now.After(change.ExpiresAt) {
+			return invalid
+		}
+		userID = change.UserID
+
+		user, err := s.userRepository.GetUserByIDTx(ctx, tx, change.UserID)
+		if err != nil {
+			return err
+		}
+		if err := checkUserCanSignIn(op, user); err != nil {
+			return err
+		}
+
+		if err := s.userRepository.UpdateEmailTx(ctx, tx, user.ID, change.NewEmail); err != nil {
+			return err
+		}
+		if err := s.emailChangeRepository.MarkConfirmedTx(ctx, tx, change.ID, now); err != nil {
+			return err
+		}
+		err = s.outboxRepository.AddTx(ctx, tx, models.OutboxEvent{
+			Type:    models.EventUserEmailChanged,
+			Subject: user.ID.String(),
+			UserID:  &user.ID,
+			Data:    map[string]any{"user_id": user.ID, "email": change.NewEmail},
+		})
+		if err != nil {
+			return err
+		}
+
+		n, err := s.refreshTokenRepository.RevokeAllForUserTx(ctx, tx, user.ID)
+		revoked = n
+		return err
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "email changed", slog.String("userID", userID.String()), slog.Int64("revokedRefreshTokens", revoked))
+	return nil
+}
+
+func (s *ProfileService) confirmEmailMessage(to, token string, expiresAt time.Time) mailer.Message {
+	confirm := token
+	if s.emailConfirmURL != "" {
+		u, err := url.Parse(s.emailConfirmURL)
+		if err == nil {
+			q := u.Query()
+			q.Set("token", token)
+			u.RawQuery = q.Encode()
+			confirm = u.String()
+		}
+	}
+
+	return mailer.Message{
+		To:      to,
+		Subject: "Confirm your new email address",
+		Body: "Someone asked to use this address for their account.\n\n" +
+			"To confirm, use:\n\n" + confirm + "\n\n" +
+			"This expires at " + expiresAt.Format(time.RFC1123) + ". If it was not you, ignore this email.\n",
+	}
+}
+
+func emailChangeRequestedMessage(to, newEmail string) mailer.Message {
+	return mailer.Message{
+		To:      to,
+		Subject: "Your email address is being changed",
+		Body: "A request was made to change the email address of your account to " + newEmail + ".\n\n" +
+			"The change only happens once the new address is confirmed. If you did not ask for it, " +
+			"change your password now.\n",
+	}
+}
+
+// activeUser loads the user behind an access token, refusing users that may
+// no longer sign in.
+func (s *ProfileService) activeUser(ctx context.Context, op string, userID uuid.UUID) (models.User, error) {
+	user, err := s.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		if errs.KindOf(err) == errs.NotFound {
+			return models.User{}, errs.WithKind(op, errs.Unauthenticated, err)
+		}
+		return models.User{}, errs.Wrap(op, err)
+	}
+
+	if err := checkUserCanSignIn(op, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// swapAvatarKey sets the user's avatar key and returns the one it replaced.
+func (s *ProfileService) swapAvatarKey(ctx context.Context, userID uuid.UUID, key string) (previous string, err error) {
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		previous, err = s.userRepository.SwapAvatarKeyTx(ctx, tx, userID, key)
+		return err
+	})
+	return previous, err
+}
+
+// deleteBlob removes an object that is no longer referenced. Failures only
+// leave an orphan behind, so they are logged and otherwise ignored.
+func (s *ProfileService) deleteBlob(ctx context.Context, log *slog.Logger, key string) {
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		log.WarnContext(ctx, "failed to delete avatar blob", slog.String("key", key), sl.Err(err))
+	}
+}
+
+func toProfileInfo(user models.User) contracts.ProfileInfo {
+	return contracts.ProfileInfo{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		Surname:   user.Surname,
+		HasAvatar: user.AvatarKey != "",
+		UpdatedAt: user.UpdatedAt,
+	}
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
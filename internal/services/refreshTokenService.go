@@ -8,88 +8,248 @@ import (
 
 	"github.com/finaptica/sso/internal/config"
 	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
 	"github.com/finaptica/sso/internal/lib/errs"
 	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/reqctx"
 	tokenGen "github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
 type RefreshTokenService struct {
 	refreshTokenRepository RefreshTokenRepository
+	sessionRepository      SessionRepository
+	rbacRepository         RBACRepository
+	orgRepository          OrgRepository
 	userRepository         UserRepository
 	appRepository          AppRepository
 	uow                    UnitOfWork
+	signer                 TokenSigner
+	audit                  *auditor
 	log                    *slog.Logger
-	refreshTokenTTL        time.Duration
+	sessions               sessionLimits
+	gracePeriod            time.Duration
 	accessTokenTTl         time.Duration
 }
 
 // NewRefreshTokenService() returns a new instance of a RefreshTokenService
-func NewRefreshTokenService(log *slog.Logger, repoCont RepositoriesContainer, cfg *config.Config) *RefreshTokenService {
+func NewRefreshTokenService(log *slog.Logger, repoCont RepositoriesContainer, signer TokenSigner, cfg *config.Config) *RefreshTokenService {
 	return &RefreshTokenService{
+		signer:                 signer,
 		refreshTokenRepository: repoCont.RtsRepo,
+		sessionRepository:      repoCont.SessionRepo,
+		rbacRepository:         repoCont.RBACRepo,
+		orgRepository:          repoCont.OrgRepo,
 		userRepository:         repoCont.UserRepo,
 		appRepository:          repoCont.AppRepo,
 		uow:                    repoCont.Uow,
 		log:                    log,
-		refreshTokenTTL:        cfg.RefreshTokenTTL,
+		audit:                  newAuditor(log, repoCont.AuditRepo),
+		sessions:               newSessionLimits(cfg),
+		gracePeriod:            cfg.Sessions.RotationGracePeriod,
 		accessTokenTTl:         cfg.AccessTokenTTL,
 	}
 }
 
-func (rts *RefreshTokenService) RefreshTokens(ctx context.Context, refreshToken string) (contracts.TokensInfo, error) {
+// RefreshTokens rotates a refresh token. The new token stays in the session
+// of the old one, which is revoked, and restarts the idle timeout without
+// outliving the session.
+//
+// A token presented again within the grace period of its rotation gets the
+// same successor back, so clients racing themselves do not fail. This never
+// mints a second successor: rotation and the grace lookup run in the same
+// serializable transaction, and the loser of a race is retried into the
+// grace path. Presented any later, a rotated token is taken as stolen and its
+// whole session is revoked.
+func (rts *RefreshTokenService) RefreshTokens(ctx context.Context, refreshToken string) (tokensInfo contracts.TokensInfo, err error) {
 	const op = "refreshTokenService.RefreshTokens"
-	log := rts.log.With(slog.String("op", op))
-
+	ctx, span := tracing.Start(ctx, op)
 	var result contracts.TokensInfo
+	var appID int
+	var userID uuid.UUID
+	var replayed, reused bool
+	defer func() {
+		var details map[string]any
+		switch {
+		case replayed:
+			details = map[string]any{"grace_replay": true}
+		case reused:
+			details = map[string]any{"reuse_detected": true}
+		}
+		rts.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditRefresh,
+			Actor:         subjectActor(userID),
+			SubjectUserID: uuidRef(userID),
+			AppID:         intRef(appID),
+			Details:       details,
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := rts.log.With(slog.String("op", op))
 
-	err := rts.uow.Do(ctx, func(tx pgx.Tx) error {
+	err = rts.uow.Do(ctx, func(tx pgx.Tx) error {
+		replayed, reused = false, false
 		token, err := rts.refreshTokenRepository.GetByValueTx(ctx, tx, refreshToken)
 		if err != nil {
-			return errs.WithKind(op, errs.NotFound, err)
+			if errs.KindOf(err) == errs.NotFound {
+				return errs.WithKind(op, errs.Unauthenticated, err)
+			}
+			return errs.Wrap(op, err)
 		}
+		appID = token.AppID
+		userID = token.UserID
+		reqctx.SetAppID(ctx, appID)
 
-		if token.IsRevoked || token.ExpiresAt.Before(time.Now().UTC()) {
+		now := time.Now().UTC()
+		if token.SessionID == nil {
 			return errs.WithKind(op, errs.Unauthenticated, errors.New("invalid token"))
 		}
 
-		if err := rts.refreshTokenRepository.RevokeTx(ctx, tx, token.ID); err != nil {
-			return errs.WithKind(op, errs.Internal, err)
+		var successor models.RefreshToken
+		if token.IsRevoked {
+			successor, replayed, err = rts.graceSuccessorTx(ctx, tx, token, now)
+			if err != nil {
+				return errs.Wrap(op, err)
+			}
+			if !replayed {
+				if token.ReplacedBy == nil {
+					return errs.WithKind(op, errs.Unauthenticated, errors.New("invalid token"))
+				}
+				// The revocation must commit, so the caller is failed only
+				// once the transaction is done.
+				reused = true
+				return rts.sessionRepository.RevokeTx(ctx, tx, *token.SessionID)
+			}
+		} else if token.ExpiresAt.Before(now) {
+			return errs.WithKind(op, errs.Unauthenticated, errors.New("invalid token"))
 		}
 
-		newValue := tokenGen.NewRefreshToken()
-		newExp := time.Now().UTC().Add(rts.refreshTokenTTL)
-		if _, err := rts.refreshTokenRepository.SaveNewRefreshTokenTx(ctx, tx, token.UserID, token.AppID, newValue, newExp); err != nil {
+		session, err := rts.sessionRepository.GetTx(ctx, tx, *token.SessionID)
+		if err != nil {
+			return errs.Wrap(op, err)
+		}
+		if session.RevokedAt != nil {
+			return errs.WithKind(op, errs.Unauthenticated, errors.New("session is revoked"))
+		}
+
+		app, err := rts.appRepository.GetAppByIDTx(ctx, tx, token.AppID)
+		if err != nil {
 			return errs.WithKind(op, errs.Internal, err)
 		}
 
+		if app.IsDisabled || !app.AllowsGrant(models.GrantTypeRefreshToken) {
+			return errs.WithKind(op, errs.PermissionDenied, errors.New("app does not allow refresh"))
+		}
+		if session.Offline && !app.OfflineAccess {
+			return errs.WithKind(op, errs.PermissionDenied, errors.New("app no longer allows offline access"))
+		}
+
+		// A lifetime shortened since sign-in applies to the session too.
+		idle, lifetime := rts.sessions.forApp(app, session.Offline)
+		sessionEnd := session.AbsoluteExpiresAt
+		if end := session.CreatedAt.Add(lifetime); end.Before(sessionEnd) {
+			sessionEnd = end
+		}
+		if !now.Before(sessionEnd) {
+			return errs.WithKind(op, errs.Unauthenticated, errors.New("session has expired"))
+		}
+
 		user, err := rts.userRepository.GetUserByIDTx(ctx, tx, token.UserID)
 		if err != nil {
 			return errs.WithKind(op, errs.Internal, err)
 		}
 
-		app, err := rts.appRepository.GetAppByIDTx(ctx, tx, token.AppID)
+		if err := checkUserCanSignIn(op, user); err != nil {
+			return err
+		}
+
+		if !replayed {
+			successor = models.RefreshToken{
+				UserID:    token.UserID,
+				AppID:     token.AppID,
+				SessionID: &session.ID,
+				Value:     tokenGen.NewRefreshToken(),
+				CreatedAt: now,
+				ExpiresAt: refreshExpiry(now, idle, sessionEnd),
+			}
+			successor.ID, err = rts.refreshTokenRepository.SaveNewRefreshTokenTx(ctx, tx, successor)
+			if err != nil {
+				return errs.Wrap(op, err)
+			}
+			if err := rts.refreshTokenRepository.RotateTx(ctx, tx, token.ID, successor.ID, now); err != nil {
+				return errs.Wrap(op, err)
+			}
+
+			ip, userAgent := requestClient(ctx)
+			if err := rts.sessionRepository.TouchTx(ctx, tx, session.ID, ip, userAgent, successor.ExpiresAt); err != nil {
+				return errs.Wrap(op, err)
+			}
+		}
+
+		authz, err := appAuthorization(ctx, op, rts.rbacRepository, rts.orgRepository, user.ID, app)
 		if err != nil {
-			return errs.WithKind(op, errs.Internal, err)
+			return err
 		}
 
-		accessToken, err := tokenGen.NewAccessToken(user, app, rts.accessTokenTTl)
+		// Issued at the successor's creation, a replay yields the very same
+		// access token as long as the user's grants are unchanged.
+		accessToken, err := rts.signer.NewAccessToken(user, app, authz, session.ID, successor.CreatedAt, ttlOr(app.AccessTokenTTL, rts.accessTokenTTl))
 		if err != nil {
 			return errs.WithKind(op, errs.Internal, err)
 		}
 
 		result = contracts.TokensInfo{
 			AccessToken:           accessToken,
-			RefreshToken:          newValue,
-			RefreshTokenExpiresAt: newExp,
+			RefreshToken:          successor.Value,
+			RefreshTokenExpiresAt: successor.ExpiresAt,
+			SessionID:             session.ID,
 		}
 		return nil
 	})
 
+	if err == nil && reused {
+		err = errs.WithKind(op, errs.Unauthenticated, errors.New("rotated refresh token reused"))
+		metrics.RefreshTokenReuses.Inc()
+		log.WarnContext(ctx, "rotated refresh token reused after the grace period, session revoked")
+	}
+
+	metrics.ObserveAuth("refresh", appID, err)
 	if err != nil {
-		log.Error("failed to refresh tokens", sl.Err(err))
+		log.ErrorContext(ctx, "failed to refresh tokens", sl.Err(err))
 		return contracts.TokensInfo{}, err
 	}
 
+	if replayed {
+		metrics.RefreshGraceReplays.Inc()
+		log.InfoContext(ctx, "rotated refresh token replayed within grace period")
+		return result, nil
+	}
+	metrics.RefreshRotations.Inc()
 	return result, nil
 }
+
+// graceSuccessorTx returns the token that replaced token, provided token was
+// rotated no longer than the grace period ago and the successor is still
+// live. Tokens revoked any other way have no successor.
+func (rts *RefreshTokenService) graceSuccessorTx(ctx context.Context, tx pgx.Tx, token models.RefreshToken, now time.Time) (models.RefreshToken, bool, error) {
+	if rts.gracePeriod <= 0 || token.ReplacedBy == nil || token.RotatedAt == nil || now.Sub(*token.RotatedAt) > rts.gracePeriod {
+		return models.RefreshToken{}, false, nil
+	}
+
+	successor, err := rts.refreshTokenRepository.GetByIDTx(ctx, tx, *token.ReplacedBy)
+	if err != nil {
+		if errs.KindOf(err) == errs.NotFound {
+			return models.RefreshToken{}, false, nil
+		}
+		return models.RefreshToken{}, false, err
+	}
+	if successor.IsRevoked || !successor.ExpiresAt.After(now) {
+		return models.RefreshToken{}, false, nil
+	}
+
+	return successor, true, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// refreshStore keeps tokens and sessions in memory. Its Do undoes every
+// change made by a function that fails, like a rolled back transaction.
+type refreshStore struct {
+	RefreshTokenRepository
+	SessionRepository
+
+	tokens   map[uuid.UUID]models.RefreshToken
+	sessions map[uuid.UUID]models.Session
+	saved    int
+}
+
+func (s *refreshStore) Do(ctx context.Context, fn func(pgx.Tx) error) error {
+	tokens, sessions := maps.Clone(s.tokens), maps.Clone(s.sessions)
+	if err := fn(nil); err != nil {
+		s.tokens, s.sessions = tokens, sessions
+		return err
+	}
+	return nil
+}
+
+func (s *refreshStore) GetByValueTx(_ context.Context, _ pgx.Tx, value string) (models.RefreshToken, error) {
+	for _, t := range s.tokens {
+		if t.Value == value {
+			return t, nil
+		}
+	}
+	return models.RefreshToken{}, errs.WithKind("test", errs.NotFound, pgx.ErrNoRows)
+}
+
+func (s *refreshStore) GetByIDTx(_ context.Context, _ pgx.Tx, id uuid.UUID) (models.RefreshToken, error) {
+	t, ok := s.tokens[id]
+	if !ok {
+		return models.RefreshToken{}, errs.WithKind("test", errs.NotFound, pgx.ErrNoRows)
+	}
+	return t, nil
+}
+
+func (s *refreshStore) SaveNewRefreshTokenTx(_ context.Context, _ pgx.Tx, t models.RefreshToken) (uuid.UUID, error) {
+	t.ID = uuid.New()
+	s.tokens[t.ID] = t
+	s.saved++
+	return t.ID, nil
+}
+
+func (s *refreshStore) RotateTx(_ context.Context, _ pgx.Tx, tokenID, successorID uuid.UUID, at time.Time) error {
+	t := s.tokens[tokenID]
+	t.IsRevoked, t.ReplacedBy, t.RotatedAt = true, &successorID, &at
+	s.tokens[tokenID] = t
+	return nil
+}
+
+func (s *refreshStore) GetTx(_ context.Context, _ pgx.Tx, id uuid.UUID) (models.Session, error) {
+	return s.sessions[id], nil
+}
+
+func (s *refreshStore) TouchTx(context.Context, pgx.Tx, uuid.UUID, string, string, time.Time) error {
+	return nil
+}
+
+func (s *refreshStore) RevokeTx(_ context.Context, _ pgx.Tx, id uuid.UUID) error {
+	sess := s.sessions[id]
+	now := time.Now()
+	sess.RevokedAt = &now
+	s.sessions[id] = sess
+	for tid, t := range s.tokens {
+		if t.SessionID != nil && *t.SessionID == id {
+			t.IsRevoked = true
+			s.tokens[tid] = t
+		}
+	}
+	return nil
+}
+
+type refreshApps struct {
+	AppRepository
+	app models.App
+}
+
+func (r refreshApps) GetAppByIDTx(context.Context, pgx.Tx, int) (models.App, error) {
+	return r.app, nil
+}
+
+type refreshUsers struct {
+	UserRepository
+	user models.User
+}
+
+func (r refreshUsers) GetUserByIDTx(context.Context, pgx.Tx, uuid.UUID) (models.User, error) {
+	return r.user, nil
+}
+
+type refreshRBAC struct{ RBACRepository }
+
+func (refreshRBAC) GetAuthorization(context.Context, uuid.UUID, int) (models.Authorization, error) {
+	return models.Authorization{}, nil
+}
+
+type refreshSigner struct{ TokenSigner }
+
+func (refreshSigner) NewAccessToken(models.User, models.App, models.Authorization, uuid.UUID, time.Time, time.Duration) (string, error) {
+	return "access", nil
+}
+
+type discardAudit struct{ AuditRepository }
+
+func (discardAudit) Insert(context.Context, models.AuditEvent) error { return nil }
+
+// rotatedFamily is a session whose first token was rotated rotatedAgo ago
+// into a live successor.
+type rotatedFamily struct {
+	store     *refreshStore
+	session   models.Session
+	rotated   models.RefreshToken
+	successor models.RefreshToken
+}
+
+func newRotatedFamily(rotatedAgo time.Duration) rotatedFamily {
+	now := time.Now().UTC()
+	userID := uuid.New()
+	session := models.Session{
+		ID:                uuid.New(),
+		UserID:            userID,
+		AppID:             1,
+		CreatedAt:         now.Add(-time.Hour),
+		ExpiresAt:         now.Add(time.Hour),
+		AbsoluteExpiresAt: now.Add(24 * time.Hour),
+	}
+	rotatedAt := now.Add(-rotatedAgo)
+	successor := models.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		AppID:     1,
+		SessionID: &session.ID,
+		Value:     "successor",
+		CreatedAt: rotatedAt,
+		ExpiresAt: now.Add(time.Hour),
+	}
+	rotated := models.RefreshToken{
+		ID:         uuid.New(),
+		UserID:     userID,
+		AppID:      1,
+		SessionID:  &session.ID,
+		Value:      "rotated",
+		IsRevoked:  true,
+		CreatedAt:  now.Add(-time.Hour),
+		ExpiresAt:  now.Add(time.Hour),
+		ReplacedBy: &successor.ID,
+		RotatedAt:  &rotatedAt,
+	}
+
+	return rotatedFamily{
+		store: &refreshStore{
+			tokens:   map[uuid.UUID]models.RefreshToken{rotated.ID: rotated, successor.ID: successor},
+			sessions: map[uuid.UUID]models.Session{session.ID: session},
+		},
+		session:   session,
+		rotated:   rotated,
+		successor: successor,
+	}
+}
+
+func (f rotatedFamily) service() *RefreshTokenService {
+	cfg := &config.Config{AccessTokenTTL: time.Minute, RefreshTokenTTL: time.Hour}
+	cfg.Sessions.Lifetime = 24 * time.Hour
+	cfg.Sessions.RotationGracePeriod = 10 * time.Second
+
+	return NewRefreshTokenService(slog.New(slog.NewTextHandler(io.Discard, nil)), RepositoriesContainer{
+		RtsRepo:     f.store,
+		SessionRepo: f.store,
+		Uow:         f.store,
+		AppRepo:     refreshApps{app: models.App{ID: 1, AllowedGrantTypes: []string{models.GrantTypeRefreshToken}}},
+		UserRepo:    refreshUsers{user: models.User{ID: f.session.UserID, Status: models.UserStatusActive}},
+		RBACRepo:    refreshRBAC{},
+		AuditRepo:   discardAudit{},
+	}, refreshSigner{}, cfg)
+}
+
+func TestRefreshTokensReplay(t *testing.T) {
+	t.Run("within the grace period returns the successor", func(t *testing.T) {
+		f := newRotatedFamily(2 * time.Second)
+
+		tokens, err := f.service().RefreshTokens(context.Background(), f.rotated.Value)
+		if err != nil {
+			t.Fatalf("RefreshTokens: %v", err)
+		}
+		if tokens.RefreshToken != f.successor.Value {
+			t.Fatalf("refresh token = %q, want the successor %q", tokens.RefreshToken, f.successor.Value)
+		}
+		if f.store.saved != 0 {
+			t.Fatalf("%d tokens minted, want none", f.store.saved)
+		}
+		if f.store.sessions[f.session.ID].RevokedAt != nil {
+			t.Fatal("session revoked")
+		}
+	})
+
+	t.Run("after the grace period revokes the family", func(t *testing.T) {
+		f := newRotatedFamily(time.Minute)
+
+		_, err := f.service().RefreshTokens(context.Background(), f.rotated.Value)
+		if errs.KindOf(err) != errs.Unauthenticated {
+			t.Fatalf("err = %v, want Unauthenticated", err)
+		}
+		if f.store.saved != 0 {
+			t.Fatalf("%d tokens minted, want none", f.store.saved)
+		}
+		if f.store.sessions[f.session.ID].RevokedAt == nil {
+			t.Fatal("session still live")
+		}
+		if !f.store.tokens[f.successor.ID].IsRevoked {
+			t.Fatal("successor still live")
+		}
+	})
+
+	t.Run("revoked token is rejected", func(t *testing.T) {
+		f := newRotatedFamily(0)
+		revoked := f.rotated
+		revoked.ReplacedBy, revoked.RotatedAt = nil, nil
+		f.store.tokens[revoked.ID] = revoked
+
+		_, err := f.service().RefreshTokens(context.Background(), revoked.Value)
+		if errs.KindOf(err) != errs.Unauthenticated {
+			t.Fatalf("err = %v, want Unauthenticated", err)
+		}
+		if f.store.saved != 0 {
+			t.Fatalf("%d tokens minted, want none", f.store.saved)
+		}
+	})
+}
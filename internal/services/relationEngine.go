@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+)
+
+// Expand tree node operations.
+const (
+	expandLeaf         = "leaf"
+	expandUnion        = "union"
+	expandIntersection = "intersection"
+	expandExclusion    = "exclusion"
+)
+
+// relationEngine evaluates checks and expansions of one app at one revision.
+// It caches tuple reads, so it must not outlive the request it serves.
+type relationEngine struct {
+	repo     RelationRepository
+	appID    int
+	revision int64
+	configs  map[string]models.NamespaceConfig
+	maxDepth int
+
+	subjects map[string][]models.RelationSubject
+	// path holds the object#relation pairs being evaluated, so a cycle in
+	// the stored tuples ends the branch instead of recursing to maxDepth.
+	path map[string]bool
+}
+
+func newRelationEngine(repo RelationRepository, appID int, revision int64, namespaces []models.RelationNamespace, maxDepth int) *relationEngine {
+	configs := make(map[string]models.NamespaceConfig, len(namespaces))
+	for _, ns := range namespaces {
+		configs[ns.Name] = ns.Config
+	}
+
+	return &relationEngine{
+		repo:     repo,
+		appID:    appID,
+		revision: revision,
+		configs:  configs,
+		maxDepth: maxDepth,
+		subjects: make(map[string][]models.RelationSubject),
+		path:     make(map[string]bool),
+	}
+}
+
+func (e *relationEngine) rewrite(object models.RelationObject, relation string) (*models.UsersetRewrite, error) {
+	const op = "relationEngine.rewrite"
+
+	cfg, ok := e.configs[object.Namespace]
+	if !ok {
+		return nil, errs.WithKind(op, errs.Invalid, fmt.Errorf("unknown namespace %q", object.Namespace))
+	}
+	r, ok := cfg.Relations[relation]
+	if !ok {
+		return nil, errs.WithKind(op, errs.Invalid, fmt.Errorf("namespace %s has no relation %q", object.Namespace, relation))
+	}
+	return r, nil
+}
+
+// hasRelation reports whether usersets naming relation on namespace objects
+// can be evaluated. Others are skipped like an empty set.
+func (e *relationEngine) hasRelation(namespace, relation string) bool {
+	_, ok := e.configs[namespace].Relations[relation]
+	return ok
+}
+
+func (e *relationEngine) read(ctx context.Context, object models.RelationObject, relation string) ([]models.RelationSubject, error) {
+	key := object.String() + "#" + relation
+	if subjects, ok := e.subjects[key]; ok {
+		return subjects, nil
+	}
+
+	subjects, err := e.repo.ReadSubjects(ctx, e.appID, e.revision, object, relation)
+	if err != nil {
+		return nil, err
+	}
+	e.subjects[key] = subjects
+	return subjects, nil
+}
+
+// enter marks object#relation as being evaluated and reports false when it
+// already is.
+func (e *relationEngine) enter(object models.RelationObject, relation string, depth int) (key string, ok bool, err error) {
+	const op = "relationEngine.enter"
+
+	if depth > e.maxDepth {
+		return "", false, errs.WithKind(op, errs.Invalid, fmt.Errorf("evaluation exceeded depth %d", e.maxDepth))
+	}
+
+	key = object.String() + "#" + relation
+	if e.path[key] {
+		return key, false, nil
+	}
+	e.path[key] = true
+	return key, true, nil
+}
+
+// check reports whether subject holds relation on object.
+func (e *relationEngine) check(ctx context.Context, object models.RelationObject, relation string, subject models.RelationSubject, depth int) (bool, error) {
+	r, err := e.rewrite(object, relation)
+	if err != nil {
+		return false, err
+	}
+
+	key, ok, err := e.enter(object, relation, depth)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer delete(e.path, key)
+
+	return e.checkRewrite(ctx, object, relation, r, subject, depth)
+}
+
+func (e *relationEngine) checkRewrite(ctx context.Context, object models.RelationObject, relation string, r *models.UsersetRewrite, subject models.RelationSubject, depth int) (bool, error) {
+	switch {
+	case r.IsThis():
+		subjects, err := e.read(ctx, object, relation)
+		if err != nil {
+			return false, err
+		}
+		for _, s := range subjects {
+			if s == subject {
+				return true, nil
+			}
+			if s.Relation == "" || !e.hasRelation(s.Namespace, s.Relation) {
+				continue
+			}
+			ok, err := e.check(ctx, s.RelationObject, s.Relation, subject, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case r.ComputedUserset != nil:
+		return e.check(ctx, object, r.ComputedUserset.Relation, subject, depth+1)
+
+	case r.TupleToUserset != nil:
+		subjects, err := e.read(ctx, object, r.TupleToUserset.Tupleset)
+		if err != nil {
+			return false, err
+		}
+		for _, s := range subjects {
+			if !e.hasRelation(s.Namespace, r.TupleToUserset.ComputedUserset) {
+				continue
+			}
+			ok, err := e.check(ctx, s.RelationObject, r.TupleToUserset.ComputedUserset, subject, depth+1)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case r.Union != nil:
+		for _, child := range r.Union {
+			ok, err := e.checkRewrite(ctx, object, relation, child, subject, depth)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case r.Intersection != nil:
+		for _, child := range r.Intersection {
+			ok, err := e.checkRewrite(ctx, object, relation, child, subject, depth)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+
+	default:
+		ok, err := e.checkRewrite(ctx, object, relation, r.Exclusion.Base, subject, depth)
+		if err != nil || !ok {
+			return false, err
+		}
+		excluded, err := e.checkRewrite(ctx, object, relation, r.Exclusion.Subtract, subject, depth)
+		return !excluded, err
+	}
+}
+
+// expand returns the userset tree of object#relation. Computed usersets are
+// followed; usersets stored as subjects are left for the caller to expand.
+func (e *relationEngine) expand(ctx context.Context, object models.RelationObject, relation string, depth int) (contracts.ExpandNode, error) {
+	r, err := e.rewrite(object, relation)
+	if err != nil {
+		return contracts.ExpandNode{}, err
+	}
+
+	key, ok, err := e.enter(object, relation, depth)
+	if err != nil {
+		return contracts.ExpandNode{}, err
+	}
+	if !ok {
+		return contracts.ExpandNode{Operation: expandLeaf, Object: object.String(), Relation: relation, Subjects: []string{key}}, nil
+	}
+	defer delete(e.path, key)
+
+	return e.expandRewrite(ctx, object, relation, r, depth)
+}
+
+func (e *relationEngine) expandRewrite(ctx context.Context, object models.RelationObject, relation string, r *models.UsersetRewrite, depth int) (contracts.ExpandNode, error) {
+	node := contracts.ExpandNode{Object: object.String(), Relation: relation}
+
+	var children []*models.UsersetRewrite
+	switch {
+	case r.IsThis():
+		subjects, err := e.read(ctx, object, relation)
+		if err != nil {
+			return contracts.ExpandNode{}, err
+		}
+		node.Operation = expandLeaf
+		for _, s := range subjects {
+			node.Subjects = append(node.Subjects, s.String())
+		}
+		return node, nil
+
+	case r.ComputedUserset != nil:
+		return e.expand(ctx, object, r.ComputedUserset.Relation, depth+1)
+
+	case r.TupleToUserset != nil:
+		subjects, err := e.read(ctx, object, r.TupleToUserset.Tupleset)
+		if err != nil {
+			return contracts.ExpandNode{}, err
+		}
+		node.Operation = expandUnion
+		for _, s := range subjects {
+			if !e.hasRelation(s.Namespace, r.TupleToUserset.ComputedUserset) {
+				continue
+			}
+			child, err := e.expand(ctx, s.RelationObject, r.TupleToUserset.ComputedUserset, depth+1)
+			if err != nil {
+				return contracts.ExpandNode{}, err
+			}
+			node.Children = append(node.Children, child)
+		}
+		return node, nil
+
+	case r.Union != nil:
+		node.Operation, children = expandUnion, r.Union
+	case r.Intersection != nil:
+		node.Operation, children = expandIntersection, r.Intersection
+	default:
+		node.Operation, children = expandExclusion, []*models.UsersetRewrite{r.Exclusion.Base, r.Exclusion.Subtract}
+	}
+
+	for _, c := range children {
+		child, err := e.expandRewrite(ctx, object, relation, c, depth)
+		if err != nil {
+			return contracts.ExpandNode{}, err
+		}
+		node.Children = append(node.Children, child)
+	}
+	return node, nil
+}
+
+// listObjects returns up to limit namespace objects on which subject holds
+// relation. Candidates are the objects with at least one stored tuple.
+func (e *relationEngine) listObjects(ctx context.Context, namespace, relation string, subject models.RelationSubject, limit int) ([]string, error) {
+	if _, err := e.rewrite(models.RelationObject{Namespace: namespace}, relation); err != nil {
+		return nil, err
+	}
+
+	ids, err := e.repo.ListObjectIDs(ctx, e.appID, e.revision, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]string, 0)
+	for _, id := range ids {
+		object := models.RelationObject{Namespace: namespace, ObjectID: id}
+		ok, err := e.check(ctx, object, relation, subject, 0)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		objects = append(objects, object.String())
+		if len(objects) == limit {
+			break
+		}
+	}
+	return objects, nil
+}
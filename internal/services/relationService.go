@@ -0,0 +1,436 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxRelationWriteSize = 100
+
+// RelationService stores relationship tuples of apps and answers
+// Zanzibar-style questions about them: Check, Expand and ListObjects.
+//
+// Every write creates a new revision of the app's tuples. Responses carry a
+// consistency token naming the revision they saw, which later requests can
+// pass to read their own writes or to repeat a read at the same snapshot.
+// Namespace configs are not versioned: a read at an old snapshot uses the
+// current config.
+type RelationService struct {
+	relationRepository RelationRepository
+	appRepository      AppRepository
+	uow                UnitOfWork
+	audit              *auditor
+	log                *slog.Logger
+	maxDepth           int
+	listObjectsLimit   int
+}
+
+// NewRelationService returns a new instance of the RelationService
+func NewRelationService(log *slog.Logger, repoContainer RepositoriesContainer, cfg *config.Config) *RelationService {
+	return &RelationService{
+		relationRepository: repoContainer.RelationRepo,
+		appRepository:      repoContainer.AppRepo,
+		uow:                repoContainer.Uow,
+		audit:              newAuditor(log, repoContainer.AuditRepo),
+		log:                log,
+		maxDepth:           cfg.Relations.MaxDepth,
+		listObjectsLimit:   cfg.Relations.ListObjectsLimit,
+	}
+}
+
+func (s *RelationService) ListNamespaces(ctx context.Context, appID int) (infos []contracts.RelationNamespaceInfo, err error) {
+	const op = "relationService.ListNamespaces"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.appRepository.GetAppById(ctx, appID); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	namespaces, err := s.relationRepository.ListNamespaces(ctx, appID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.RelationNamespaceInfo, 0, len(namespaces))
+	for _, ns := range namespaces {
+		info, err := toRelationNamespaceInfo(ns)
+		if err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// PutNamespace creates the namespace or replaces its config. Relations that
+// still have tuples cannot be removed.
+func (s *RelationService) PutNamespace(ctx context.Context, appID int, name string, raw json.RawMessage) (info contracts.RelationNamespaceInfo, err error) {
+	const op = "relationService.PutNamespace"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditRelationNamespacePut,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"namespace": name},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if !models.IsValidRelationName(name) {
+		return contracts.RelationNamespaceInfo{}, errs.WithKind(op, errs.Invalid, errors.New("invalid namespace name"))
+	}
+
+	var cfg models.NamespaceConfig
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return contracts.RelationNamespaceInfo{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("invalid namespace config: %w", err))
+	}
+	if err := cfg.Validate(); err != nil {
+		return contracts.RelationNamespaceInfo{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	var saved models.RelationNamespace
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		old, err := s.relationRepository.GetNamespaceTx(ctx, tx, appID, name)
+		if err != nil && errs.KindOf(err) != errs.NotFound {
+			return err
+		}
+
+		var removed []string
+		for rel := range old.Config.Relations {
+			if _, ok := cfg.Relations[rel]; !ok {
+				removed = append(removed, rel)
+			}
+		}
+		if len(removed) > 0 {
+			n, err := s.relationRepository.CountLiveTuplesTx(ctx, tx, appID, name, removed)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return errs.WithKind(op, errs.Conflict, fmt.Errorf("removed relations %v still have %d tuples", removed, n))
+			}
+		}
+
+		ns, err := s.relationRepository.PutNamespaceTx(ctx, tx, models.RelationNamespace{AppID: appID, Name: name, Config: cfg})
+		if err != nil {
+			return err
+		}
+		saved = ns
+		return nil
+	})
+	if err != nil {
+		return contracts.RelationNamespaceInfo{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "relation namespace saved", slog.String("op", op), slog.String("admin", adminName(ctx)), slog.Int("appID", appID), slog.String("namespace", name))
+
+	info, err = toRelationNamespaceInfo(saved)
+	if err != nil {
+		return contracts.RelationNamespaceInfo{}, errs.WithKind(op, errs.Internal, err)
+	}
+	return info, nil
+}
+
+// DeleteNamespace removes a namespace no tuple refers to any more.
+func (s *RelationService) DeleteNamespace(ctx context.Context, appID int, name string) (err error) {
+	const op = "relationService.DeleteNamespace"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditRelationNamespaceDelete,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"namespace": name},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		n, err := s.relationRepository.CountLiveTuplesTx(ctx, tx, appID, name, nil)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return errs.WithKind(op, errs.Conflict, fmt.Errorf("namespace still has %d tuples", n))
+		}
+		return s.relationRepository.DeleteNamespaceTx(ctx, tx, appID, name)
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+// WriteRelations applies deletes and writes atomically as one new revision.
+func (s *RelationService) WriteRelations(ctx context.Context, appID int, params contracts.WriteRelationsParams) (result contracts.WriteRelationsResult, err error) {
+	const op = "relationService.WriteRelations"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if n := len(params.Writes) + len(params.Deletes); n == 0 || n > maxRelationWriteSize {
+		return contracts.WriteRelationsResult{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("a write must change 1 to %d tuples", maxRelationWriteSize))
+	}
+
+	writes, err := parseRelationTuples(params.Writes)
+	if err != nil {
+		return contracts.WriteRelationsResult{}, errs.WithKind(op, errs.Invalid, err)
+	}
+	deletes, err := parseRelationTuples(params.Deletes)
+	if err != nil {
+		return contracts.WriteRelationsResult{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	var revision int64
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		if err := s.validateWritesTx(ctx, tx, appID, writes); err != nil {
+			return err
+		}
+
+		rev, err := s.relationRepository.WriteTuplesTx(ctx, tx, appID, writes, deletes)
+		if err != nil {
+			return err
+		}
+		revision = rev
+		return nil
+	})
+	if err != nil {
+		return contracts.WriteRelationsResult{}, errs.Wrap(op, err)
+	}
+
+	return contracts.WriteRelationsResult{ConsistencyToken: encodeConsistencyToken(appID, revision)}, nil
+}
+
+// validateWritesTx checks new tuples against the namespace configs read in
+// the same transaction, so a concurrent config change cannot slip a tuple
+// past its check.
+func (s *RelationService) validateWritesTx(ctx context.Context, tx pgx.Tx, appID int, writes []models.RelationTuple) error {
+	const op = "relationService.validateWritesTx"
+
+	configs := make(map[string]models.NamespaceConfig)
+	lookup := func(name string) (models.NamespaceConfig, error) {
+		if cfg, ok := configs[name]; ok {
+			return cfg, nil
+		}
+		ns, err := s.relationRepository.GetNamespaceTx(ctx, tx, appID, name)
+		if err != nil {
+			if errs.KindOf(err) == errs.NotFound {
+				return models.NamespaceConfig{}, errs.WithKind(op, errs.Invalid, fmt.Errorf("unknown namespace %q", name))
+			}
+			return models.NamespaceConfig{}, err
+		}
+		configs[name] = ns.Config
+		return ns.Config, nil
+	}
+
+	for _, t := range writes {
+		cfg, err := lookup(t.Object.Namespace)
+		if err != nil {
+			return err
+		}
+		if !cfg.AllowsTuples(t.Relation) {
+			return errs.WithKind(op, errs.Invalid, fmt.Errorf("%s does not take tuples for relation %q", t.Object.Namespace, t.Relation))
+		}
+
+		subjectCfg, err := lookup(t.Subject.Namespace)
+		if err != nil {
+			return err
+		}
+		if _, ok := subjectCfg.Relations[t.Subject.Relation]; t.Subject.Relation != "" && !ok {
+			return errs.WithKind(op, errs.Invalid, fmt.Errorf("%s has no relation %q", t.Subject.Namespace, t.Subject.Relation))
+		}
+	}
+	return nil
+}
+
+// Check reports whether the subject holds the relation on the object.
+func (s *RelationService) Check(ctx context.Context, appID int, params contracts.CheckParams) (result contracts.CheckResult, err error) {
+	const op = "relationService.Check"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		observeRelationCheck(result.Allowed, err)
+		tracing.End(span, err)
+	}()
+
+	object, err := models.ParseRelationObject(params.Object)
+	if err != nil {
+		return contracts.CheckResult{}, errs.WithKind(op, errs.Invalid, err)
+	}
+	subject, err := models.ParseRelationSubject(params.Subject)
+	if err != nil {
+		return contracts.CheckResult{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	engine, err := s.engine(ctx, appID, params.Consistency)
+	if err != nil {
+		return contracts.CheckResult{}, errs.Wrap(op, err)
+	}
+
+	allowed, err := engine.check(ctx, object, params.Relation, subject, 0)
+	if err != nil {
+		return contracts.CheckResult{}, errs.Wrap(op, err)
+	}
+
+	return contracts.CheckResult{Allowed: allowed, ConsistencyToken: encodeConsistencyToken(appID, engine.revision)}, nil
+}
+
+// Expand returns the userset tree of object#relation.
+func (s *RelationService) Expand(ctx context.Context, appID int, params contracts.ExpandParams) (result contracts.ExpandResult, err error) {
+	const op = "relationService.Expand"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	object, err := models.ParseRelationObject(params.Object)
+	if err != nil {
+		return contracts.ExpandResult{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	engine, err := s.engine(ctx, appID, params.Consistency)
+	if err != nil {
+		return contracts.ExpandResult{}, errs.Wrap(op, err)
+	}
+
+	tree, err := engine.expand(ctx, object, params.Relation, 0)
+	if err != nil {
+		return contracts.ExpandResult{}, errs.Wrap(op, err)
+	}
+
+	return contracts.ExpandResult{Tree: tree, ConsistencyToken: encodeConsistencyToken(appID, engine.revision)}, nil
+}
+
+// ListObjects returns the objects of a namespace on which the subject holds
+// the relation, up to the configured limit.
+func (s *RelationService) ListObjects(ctx context.Context, appID int, params contracts.ListObjectsParams) (result contracts.ListObjectsResult, err error) {
+	const op = "relationService.ListObjects"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	subject, err := models.ParseRelationSubject(params.Subject)
+	if err != nil {
+		return contracts.ListObjectsResult{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	engine, err := s.engine(ctx, appID, params.Consistency)
+	if err != nil {
+		return contracts.ListObjectsResult{}, errs.Wrap(op, err)
+	}
+
+	objects, err := engine.listObjects(ctx, params.Namespace, params.Relation, subject, s.listObjectsLimit)
+	if err != nil {
+		return contracts.ListObjectsResult{}, errs.Wrap(op, err)
+	}
+
+	return contracts.ListObjectsResult{Objects: objects, ConsistencyToken: encodeConsistencyToken(appID, engine.revision)}, nil
+}
+
+// engine returns an evaluator at the snapshot the consistency asks for.
+// There is a single primary, so the latest revision is always at least as
+// fresh as any token it handed out.
+func (s *RelationService) engine(ctx context.Context, appID int, consistency contracts.Consistency) (*relationEngine, error) {
+	const op = "relationService.engine"
+
+	token := consistency.AtLeastAsFresh
+	if consistency.AtExactSnapshot != "" {
+		if token != "" {
+			return nil, errs.WithKind(op, errs.Invalid, errors.New("at most one consistency token may be given"))
+		}
+		token = consistency.AtExactSnapshot
+	}
+
+	revision, err := s.relationRepository.Revision(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		tokenAppID, tokenRevision, err := decodeConsistencyToken(token)
+		if err != nil || tokenAppID != appID || tokenRevision > revision {
+			return nil, errs.WithKind(op, errs.Invalid, errors.New("invalid consistency token"))
+		}
+		if consistency.AtExactSnapshot != "" {
+			revision = tokenRevision
+		}
+	}
+
+	namespaces, err := s.relationRepository.ListNamespaces(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRelationEngine(s.relationRepository, appID, revision, namespaces, s.maxDepth), nil
+}
+
+func parseRelationTuples(params []contracts.RelationTuple) ([]models.RelationTuple, error) {
+	tuples := make([]models.RelationTuple, 0, len(params))
+	for _, p := range params {
+		object, err := models.ParseRelationObject(p.Object)
+		if err != nil {
+			return nil, err
+		}
+		if !models.IsValidRelationName(p.Relation) {
+			return nil, fmt.Errorf("invalid relation %q", p.Relation)
+		}
+		subject, err := models.ParseRelationSubject(p.Subject)
+		if err != nil {
+			return nil, err
+		}
+		tuples = append(tuples, models.RelationTuple{Object: object, Relation: p.Relation, Subject: subject})
+	}
+	return tuples, nil
+}
+
+func observeRelationCheck(allowed bool, err error) {
+	result := "denied"
+	switch {
+	case err != nil:
+		result = "error"
+	case allowed:
+		result = "allowed"
+	}
+	metrics.RelationChecks.WithLabelValues(result).Inc()
+}
+
+func toRelationNamespaceInfo(ns models.RelationNamespace) (contracts.RelationNamespaceInfo, error) {
+	cfg, err := json.Marshal(ns.Config)
+	if err != nil {
+		return contracts.RelationNamespaceInfo{}, err
+	}
+	return contracts.RelationNamespaceInfo{Name: ns.Name, Config: cfg, CreatedAt: ns.CreatedAt, UpdatedAt: ns.UpdatedAt}, nil
+}
+
+type consistencyTokenJSON struct {
+	AppID    int   `json:"a"`
+	Revision int64 `json:"r"`
+}
+
+func encodeConsistencyToken(appID int, revision int64) string {
+	b, _ := json.Marshal(consistencyTokenJSON{AppID: appID, Revision: revision})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeConsistencyToken(s string) (appID int, revision int64, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var t consistencyTokenJSON
+	if err := json.Unmarshal(b, &t); err != nil {
+		return 0, 0, err
+	}
+	return t.AppID, t.Revision, nil
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/schedule"
+	"github.com/finaptica/sso/internal/lib/tracing"
+)
+
+const (
+	// schedulerLock is held by the leader, the one replica that runs jobs
+	// on schedule.
+	schedulerLock = "sso.scheduler"
+	// jobLockPrefix names the lock held by whichever replica runs a job, so
+	// that a manual run never overlaps a scheduled one.
+	jobLockPrefix = "sso.job."
+
+	defaultJobRunsPageSize = 20
+	maxJobRunsPageSize     = 100
+)
+
+// Job is background work the scheduler runs. A job without a Schedule only
+// runs when triggered through the admin API.
+type Job struct {
+	Name     string
+	Schedule schedule.Schedule
+	Run      func(context.Context) error
+	// Quiet jobs run too often for every run to be worth a history row:
+	// their scheduled runs are recorded only when they fail.
+	Quiet bool
+}
+
+// SchedulerService runs background jobs. Every replica stands for leader;
+// the one holding the scheduler lock runs the jobs on schedule, and any
+// replica runs a job triggered by an admin.
+type SchedulerService struct {
+	jobRepository    JobRepository
+	locker           Locker
+	audit            *auditor
+	log              *slog.Logger
+	instance         string
+	electionInterval time.Duration
+	jobs             []Job
+
+	// triggered tracks runs started by TriggerJob, which outlive the
+	// requests that started them.
+	triggered sync.WaitGroup
+}
+
+// NewSchedulerService returns a new instance of the SchedulerService
+func NewSchedulerService(log *slog.Logger, repoContainer RepositoriesContainer, locker Locker, cfg *config.Config, jobs ...Job) *SchedulerService {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+
+	return &SchedulerService{
+		jobRepository:    repoContainer.JobRepo,
+		locker:           locker,
+		audit:            newAuditor(log, repoContainer.AuditRepo),
+		log:              log,
+		instance:         instance,
+		electionInterval: cfg.Scheduler.ElectionInterval,
+		jobs:             jobs,
+	}
+}
+
+// Run stands for leader until ctx is done, trying again every election
+// interval while another replica leads.
+func (s *SchedulerService) Run(ctx context.Context) {
+	for {
+		led, err := s.locker.TryLead(ctx, schedulerLock, s.electionInterval, s.lead)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			s.log.ErrorContext(ctx, "scheduler leadership failed", sl.Err(err))
+		case led:
+			s.log.InfoContext(ctx, "scheduler leadership released", slog.String("instance", s.instance))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.electionInterval):
+		}
+	}
+}
+
+// Wait blocks until every run started by TriggerJob has finished.
+func (s *SchedulerService) Wait() {
+	s.triggered.Wait()
+}
+
+// lead runs the jobs on schedule until ctx is done, then waits for the runs
+// in flight so the lock is not handed over while they still go.
+func (s *SchedulerService) lead(ctx context.Context) error {
+	s.log.InfoContext(ctx, "became scheduler leader", slog.String("instance", s.instance))
+	metrics.SchedulerLeader.Set(1)
+	defer metrics.SchedulerLeader.Set(0)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	now := time.Now()
+	next := make([]time.Time, len(s.jobs))
+	for i, job := range s.jobs {
+		if job.Schedule != nil {
+			next[i] = job.Schedule.Next(now)
+		}
+	}
+
+	for {
+		var wake time.Time
+		for _, t := range next {
+			if !t.IsZero() && (wake.IsZero() || t.Before(wake)) {
+				wake = t
+			}
+		}
+		if wake.IsZero() {
+			<-ctx.Done()
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case now = <-timer.C:
+		}
+
+		for i, job := range s.jobs {
+			if next[i].IsZero() || next[i].After(now) {
+				continue
+			}
+			next[i] = job.Schedule.Next(now)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.execute(ctx, job, models.JobTriggerSchedule, "", nil)
+			}()
+		}
+	}
+}
+
+// jobStart reports whether a run got past the job lock and, if so, the run
+// recorded for it.
+type jobStart struct {
+	run models.JobRun
+	ran bool
+	err error
+}
+
+// execute runs job under the job lock and records the run. A run finding
+// the lock taken is skipped. started, when not nil, hears back as soon as
+// the run is recorded or known not to happen.
+func (s *SchedulerService) execute(ctx context.Context, job Job, trigger, triggeredBy string, started chan<- jobStart) {
+	log := s.log.With(slog.String("job", job.Name), slog.String("trigger", trigger))
+
+	newRun := models.JobRun{
+		Job:         job.Name,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Instance:    s.instance,
+	}
+	recorded := !job.Quiet || trigger != models.JobTriggerSchedule
+
+	notified := false
+	ran, err := s.locker.TryWithLock(ctx, jobLockPrefix+job.Name, func(ctx context.Context) error {
+		var run models.JobRun
+		if recorded {
+			var err error
+			if run, err = s.jobRepository.StartRun(ctx, newRun); err != nil {
+				return err
+			}
+			if started != nil {
+				started <- jobStart{run: run, ran: true}
+				notified = true
+			}
+		}
+
+		begin := time.Now()
+		runErr := job.Run(ctx)
+		metrics.JobDuration.WithLabelValues(job.Name).Observe(time.Since(begin).Seconds())
+
+		status, msg := models.JobRunSucceeded, ""
+		if runErr != nil {
+			status, msg = models.JobRunFailed, runErr.Error()
+			log.ErrorContext(ctx, "job failed", sl.Err(runErr))
+		}
+		metrics.JobRuns.WithLabelValues(job.Name, status).Inc()
+
+		// The outcome is worth recording even when shutdown cut the run
+		// short.
+		recordCtx := context.WithoutCancel(ctx)
+		if !recorded {
+			if runErr == nil {
+				return nil
+			}
+			var err error
+			if run, err = s.jobRepository.StartRun(recordCtx, newRun); err != nil {
+				return err
+			}
+		}
+		return s.jobRepository.FinishRun(recordCtx, run.ID, status, msg, time.Now().UTC())
+	})
+	switch {
+	case err != nil:
+		log.ErrorContext(ctx, "failed to run job", sl.Err(err))
+	case !ran:
+		metrics.JobRuns.WithLabelValues(job.Name, "skipped").Inc()
+		log.InfoContext(ctx, "job already running, skipping")
+	}
+
+	if started != nil && !notified {
+		started <- jobStart{ran: ran, err: err}
+	}
+}
+
+// ListJobs returns every job with its latest run. NextRunAt is reckoned from
+// the schedule and the latest run, as only the leader knows its timers.
+func (s *SchedulerService) ListJobs(ctx context.Context) (infos []contracts.JobInfo, err error) {
+	const op = "schedulerService.ListJobs"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	runs, err := s.jobRepository.LastRuns(ctx)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+	lastRuns := make(map[string]models.JobRun, len(runs))
+	for _, run := range runs {
+		lastRuns[run.Job] = run
+	}
+
+	now := time.Now().UTC()
+	infos = make([]contracts.JobInfo, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		info := contracts.JobInfo{Name: job.Name}
+
+		last, hasRun := lastRuns[job.Name]
+		if hasRun {
+			lastInfo := toJobRunInfo(last)
+			info.LastRun = &lastInfo
+		}
+
+		if job.Schedule != nil {
+			info.Schedule = job.Schedule.String()
+			next := job.Schedule.Next(now)
+			if hasRun && last.Trigger == models.JobTriggerSchedule {
+				if after := job.Schedule.Next(last.StartedAt); after.After(now) {
+					next = after
+				}
+			}
+			if !next.IsZero() {
+				info.NextRunAt = &next
+			}
+		}
+
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// ListRuns returns the latest runs of a job, newest first.
+func (s *SchedulerService) ListRuns(ctx context.Context, name string, limit int) (infos []contracts.JobRunInfo, err error) {
+	const op = "schedulerService.ListRuns"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, ok := s.job(name); !ok {
+		return nil, errs.WithKind(op, errs.NotFound, errors.New("unknown job"))
+	}
+
+	switch {
+	case limit == 0:
+		limit = defaultJobRunsPageSize
+	case limit < 0 || limit > maxJobRunsPageSize:
+		return nil, errs.WithKind(op, errs.Invalid, errors.New("limit must be between 1 and 100"))
+	}
+
+	runs, err := s.jobRepository.ListRuns(ctx, name, limit)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.JobRunInfo, 0, len(runs))
+	for _, run := range runs {
+		infos = append(infos, toJobRunInfo(run))
+	}
+	return infos, nil
+}
+
+// TriggerJob starts a run of a job on this replica and returns it once it is
+// recorded, without waiting for it to finish. It fails with Conflict while
+// the job is running anywhere.
+func (s *SchedulerService) TriggerJob(ctx context.Context, name string) (info contracts.JobRunInfo, err error) {
+	const op = "schedulerService.TriggerJob"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditJobTrigger,
+			Details:   map[string]any{"job": name, "run_id": info.ID},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	job, ok := s.job(name)
+	if !ok {
+		return contracts.JobRunInfo{}, errs.WithKind(op, errs.NotFound, errors.New("unknown job"))
+	}
+
+	// The run outlives the request that asked for it.
+	started := make(chan jobStart, 1)
+	s.triggered.Add(1)
+	go func() {
+		defer s.triggered.Done()
+		s.execute(context.WithoutCancel(ctx), job, models.JobTriggerManual, actor(ctx), started)
+	}()
+
+	res := <-started
+	switch {
+	case res.err != nil:
+		return contracts.JobRunInfo{}, errs.Wrap(op, res.err)
+	case !res.ran:
+		return contracts.JobRunInfo{}, errs.WithKind(op, errs.Conflict, errors.New("job is already running"))
+	}
+
+	s.log.InfoContext(ctx, "job triggered",
+		slog.String("op", op),
+		slog.String("admin", adminName(ctx)),
+		slog.String("job", name),
+		slog.String("runID", res.run.ID.String()),
+	)
+	return toJobRunInfo(res.run), nil
+}
+
+func (s *SchedulerService) job(name string) (Job, bool) {
+	for _, job := range s.jobs {
+		if job.Name == name {
+			return job, true
+		}
+	}
+	return Job{}, false
+}
+
+func toJobRunInfo(run models.JobRun) contracts.JobRunInfo {
+	return contracts.JobRunInfo{
+		ID:          run.ID,
+		Job:         run.Job,
+		Trigger:     run.Trigger,
+		TriggeredBy: run.TriggeredBy,
+		Instance:    run.Instance,
+		Status:      run.Status,
+		Error:       run.Error,
+		StartedAt:   run.StartedAt,
+		FinishedAt:  run.FinishedAt,
+	}
+}
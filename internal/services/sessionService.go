@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/reqctx"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+)
+
+// maxDeviceNameLength bounds the label a client gives its session.
+const maxDeviceNameLength = 100
+
+// SessionService lists and revokes sessions. Users reach it for their own
+// sessions through /me, admins for anyone's through /admin/users.
+type SessionService struct {
+	sessionRepository SessionRepository
+	userRepository    UserRepository
+	audit             *auditor
+	log               *slog.Logger
+}
+
+// NewSessionService returns a new instance of the SessionService
+func NewSessionService(log *slog.Logger, repoContainer RepositoriesContainer) *SessionService {
+	return &SessionService{
+		sessionRepository: repoContainer.SessionRepo,
+		userRepository:    repoContainer.UserRepo,
+		audit:             newAuditor(log, repoContainer.AuditRepo),
+		log:               log,
+	}
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+func (s *SessionService) ListSessions(ctx context.Context, userID uuid.UUID) (infos []contracts.SessionInfo, err error) {
+	const op = "sessionService.ListSessions"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.userRepository.GetUserByID(ctx, userID); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	sessions, err := s.sessionRepository.ListActive(ctx, userID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	current, _ := reqctx.Session(ctx)
+	infos = make([]contracts.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		info := toSessionInfo(session)
+		info.Current = session.ID == current
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// RevokeSession ends one of the user's sessions. Access tokens already
+// issued under it stay valid until they expire.
+func (s *SessionService) RevokeSession(ctx context.Context, userID, id uuid.UUID) (err error) {
+	const op = "sessionService.RevokeSession"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditSessionRevoke,
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"session_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := s.sessionRepository.Revoke(ctx, userID, id); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "session revoked", slog.String("op", op), slog.String("actor", actor(ctx)),
+		slog.String("userID", userID.String()), slog.String("sessionID", id.String()))
+	return nil
+}
+
+// RevokeAllSessions ends every session of the user.
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID uuid.UUID) (err error) {
+	const op = "sessionService.RevokeAllSessions"
+	ctx, span := tracing.Start(ctx, op)
+	var revoked int64
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditSessionRevokeAll,
+			SubjectUserID: uuidRef(userID),
+			Details:       map[string]any{"revoked_sessions": revoked},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if _, err := s.userRepository.GetUserByID(ctx, userID); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	revoked, err = s.sessionRepository.RevokeAll(ctx, userID)
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "sessions revoked", slog.String("op", op), slog.String("actor", actor(ctx)),
+		slog.String("userID", userID.String()), slog.Int64("revoked", revoked))
+	return nil
+}
+
+// sessionLimits are the service-wide session timeouts apps fall back to.
+type sessionLimits struct {
+	idle            time.Duration
+	lifetime        time.Duration
+	offlineIdle     time.Duration
+	offlineLifetime time.Duration
+}
+
+func newSessionLimits(cfg *config.Config) sessionLimits {
+	return sessionLimits{
+		idle:            cfg.RefreshTokenTTL,
+		lifetime:        cfg.Sessions.Lifetime,
+		offlineIdle:     cfg.Sessions.OfflineIdleTimeout,
+		offlineLifetime: cfg.Sessions.OfflineLifetime,
+	}
+}
+
+// forApp returns the idle timeout and the absolute lifetime of a session in
+// app.
+func (l sessionLimits) forApp(app models.App, offline bool) (idle, lifetime time.Duration) {
+	if offline {
+		return ttlOr(app.OfflineIdleTimeout, l.offlineIdle), ttlOr(app.OfflineSessionLifetime, l.offlineLifetime)
+	}
+	return ttlOr(app.RefreshTokenTTL, l.idle), ttlOr(app.SessionLifetime, l.lifetime)
+}
+
+// refreshExpiry is when a refresh token issued at now expires: once the idle
+// timeout passes, and at the latest when the session ends.
+func refreshExpiry(now time.Time, idle time.Duration, sessionEnd time.Time) time.Time {
+	if exp := now.Add(idle); exp.Before(sessionEnd) {
+		return exp
+	}
+	return sessionEnd
+}
+
+// requestClient returns the IP and user agent of the request in ctx, empty
+// outside a request.
+func requestClient(ctx context.Context) (ip, userAgent string) {
+	if req, ok := reqctx.RequestFrom(ctx); ok {
+		return req.ClientIP, req.UserAgent
+	}
+	return "", ""
+}
+
+func toSessionInfo(s models.Session) contracts.SessionInfo {
+	return contracts.SessionInfo{
+		ID:                s.ID,
+		AppID:             s.AppID,
+		AppName:           s.AppName,
+		DeviceName:        s.DeviceName,
+		UserAgent:         s.UserAgent,
+		IP:                s.IP,
+		CreatedAt:         s.CreatedAt,
+		LastUsedAt:        s.LastUsedAt,
+		ExpiresAt:         s.ExpiresAt,
+		AbsoluteExpiresAt: s.AbsoluteExpiresAt,
+		Offline:           s.Offline,
+	}
+}
@@ -0,0 +1,423 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+	maxProfileNameLength = 255
+)
+
+// UserService backs the admin user management API.
+type UserService struct {
+	userRepository         UserRepository
+	refreshTokenRepository RefreshTokenRepository
+	outboxRepository       OutboxRepository
+	audit                  *auditor
+	uow                    UnitOfWork
+	log                    *slog.Logger
+}
+
+// NewUserService returns a new instance of the UserService
+func NewUserService(log *slog.Logger, repoContainer RepositoriesContainer) *UserService {
+	return &UserService{
+		userRepository:         repoContainer.UserRepo,
+		refreshTokenRepository: repoContainer.RtsRepo,
+		outboxRepository:       repoContainer.OutboxRepo,
+		audit:                  newAuditor(log, repoContainer.AuditRepo),
+		uow:                    repoContainer.Uow,
+		log:                    log,
+	}
+}
+
+// ListUsers returns one page of users, newest first.
+func (s *UserService) ListUsers(ctx context.Context, params contracts.ListUsersParams) (page contracts.UserPage, err error) {
+	const op = "userService.ListUsers"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	limit := params.Limit
+	switch {
+	case limit == 0:
+		limit = defaultUsersPageSize
+	case limit < 0 || limit > maxUsersPageSize:
+		return contracts.UserPage{}, errs.WithKind(op, errs.Invalid, errors.New("limit must be between 1 and 200"))
+	}
+
+	if params.Status != "" {
+		if !models.IsKnownUserStatus(params.Status) {
+			return contracts.UserPage{}, errs.WithKind(op, errs.Invalid, errors.New("unknown status "+params.Status))
+		}
+	}
+
+	var cursor *models.UserCursor
+	if params.Cursor != "" {
+		c, err := decodeUserCursor(params.Cursor)
+		if err != nil {
+			return contracts.UserPage{}, errs.WithKind(op, errs.Invalid, errors.New("invalid cursor"))
+		}
+		cursor = &c
+	}
+
+	filter := models.UserFilter{
+		EmailPrefix: strings.TrimSpace(params.EmailPrefix),
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+		Status:      params.Status,
+		AppID:       params.AppID,
+	}
+
+	// Fetch one extra row to learn whether another page follows.
+	users, err := s.userRepository.ListUsers(ctx, filter, cursor, limit+1)
+	if err != nil {
+		return contracts.UserPage{}, errs.Wrap(op, err)
+	}
+
+	page.Users = make([]contracts.UserInfo, 0, min(len(users), limit))
+	for i, u := range users {
+		if i == limit {
+			last := users[limit-1]
+			page.NextCursor = encodeUserCursor(models.UserCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+			break
+		}
+		page.Users = append(page.Users, toUserInfo(u))
+	}
+
+	return page, nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, id uuid.UUID) (info contracts.UserInfo, err error) {
+	const op = "userService.GetUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	user, err := s.userRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return contracts.UserInfo{}, errs.Wrap(op, err)
+	}
+
+	return toUserInfo(user), nil
+}
+
+func (s *UserService) UpdateUser(ctx context.Context, id uuid.UUID, params contracts.UpdateUserParams) (info contracts.UserInfo, err error) {
+	const op = "userService.UpdateUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{EventType: models.AuditUserUpdate, SubjectUserID: uuidRef(id)}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("userID", id.String()))
+
+	user, err := s.userRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return contracts.UserInfo{}, errs.Wrap(op, err)
+	}
+
+	if err := applyProfileParams(&user, params); err != nil {
+		return contracts.UserInfo{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	if err := s.userRepository.UpdateProfile(ctx, id, user.Name, user.Surname); err != nil {
+		return contracts.UserInfo{}, errs.Wrap(op, err)
+	}
+
+	user, err = s.userRepository.GetUserByID(ctx, id)
+	if err != nil {
+		return contracts.UserInfo{}, errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "user updated")
+	return toUserInfo(user), nil
+}
+
+// SetUserStatus moves the user to status, recording the admin and reason.
+// Leaving the active status revokes every refresh token so existing sessions
+// end at the next refresh.
+func (s *UserService) SetUserStatus(ctx context.Context, id uuid.UUID, status, reason string) (err error) {
+	const op = "userService.SetUserStatus"
+	ctx, span := tracing.Start(ctx, op)
+	var from string
+	var revoked int64
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditUserStatusChange,
+			SubjectUserID: uuidRef(id),
+			Details: map[string]any{
+				"from":                   from,
+				"to":                     status,
+				"reason":                 reason,
+				"revoked_refresh_tokens": revoked,
+			},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("userID", id.String()))
+
+	if !models.IsKnownUserStatus(status) {
+		return errs.WithKind(op, errs.Invalid, errors.New("unknown status "+status))
+	}
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		user, err := s.userRepository.GetUserByIDTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		from = user.Status
+
+		n, err := s.transitionStatusTx(ctx, tx, user, status, reason)
+		revoked = n
+		return err
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "user status changed",
+		slog.String("from", from),
+		slog.String("to", status),
+		slog.Int64("revokedRefreshTokens", revoked),
+	)
+	return nil
+}
+
+// ListStatusHistory returns the user's status transitions, oldest first.
+func (s *UserService) ListStatusHistory(ctx context.Context, id uuid.UUID) (infos []contracts.UserStatusTransitionInfo, err error) {
+	const op = "userService.ListStatusHistory"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.userRepository.GetUserByID(ctx, id); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	transitions, err := s.userRepository.ListStatusTransitions(ctx, id)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.UserStatusTransitionInfo, 0, len(transitions))
+	for _, t := range transitions {
+		infos = append(infos, contracts.UserStatusTransitionInfo{
+			FromStatus: t.FromStatus,
+			ToStatus:   t.ToStatus,
+			Actor:      t.Actor,
+			Reason:     t.Reason,
+			CreatedAt:  t.CreatedAt,
+		})
+	}
+
+	return infos, nil
+}
+
+// transitionStatusTx validates and applies a status change inside tx and
+// records it. It returns how many refresh tokens were revoked.
+func (s *UserService) transitionStatusTx(ctx context.Context, tx pgx.Tx, user models.User, to, reason string) (int64, error) {
+	const op = "userService.transitionStatusTx"
+
+	if user.Status == to {
+		return 0, nil
+	}
+	if !models.CanTransitionUserStatus(user.Status, to) {
+		return 0, errs.WithKind(op, errs.Conflict, fmt.Errorf("cannot change status from %s to %s", user.Status, to))
+	}
+
+	if err := s.userRepository.SetStatusTx(ctx, tx, user.ID, to); err != nil {
+		return 0, err
+	}
+
+	err := s.userRepository.AddStatusTransitionTx(ctx, tx, models.UserStatusTransition{
+		UserID:     user.ID,
+		FromStatus: user.Status,
+		ToStatus:   to,
+		Actor:      actor(ctx),
+		Reason:     reason,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.outboxRepository.AddTx(ctx, tx, models.OutboxEvent{
+		Type:    models.EventUserStatusChanged,
+		Subject: user.ID.String(),
+		UserID:  &user.ID,
+		Data:    map[string]any{"user_id": user.ID, "from": user.Status, "to": to, "reason": reason},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if to == models.UserStatusActive {
+		return 0, nil
+	}
+	return s.refreshTokenRepository.RevokeAllForUserTx(ctx, tx, user.ID)
+}
+
+// ForcePasswordReset blocks sign-in until the user changes their password
+// and revokes every refresh token they hold.
+func (s *UserService) ForcePasswordReset(ctx context.Context, id uuid.UUID) (err error) {
+	const op = "userService.ForcePasswordReset"
+	ctx, span := tracing.Start(ctx, op)
+	var revoked int64
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditUserPasswordReset,
+			SubjectUserID: uuidRef(id),
+			Details:       map[string]any{"revoked_refresh_tokens": revoked},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("userID", id.String()))
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		if err := s.userRepository.SetPasswordResetRequiredTx(ctx, tx, id, true); err != nil {
+			return err
+		}
+
+		n, err := s.refreshTokenRepository.RevokeAllForUserTx(ctx, tx, id)
+		revoked = n
+		return err
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "password reset forced", slog.Int64("revokedRefreshTokens", revoked))
+	return nil
+}
+
+// DeleteUser soft-deletes the user: the row is kept with the deleted status
+// and every refresh token is revoked.
+func (s *UserService) DeleteUser(ctx context.Context, id uuid.UUID, reason string) (err error) {
+	const op = "userService.DeleteUser"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.SetUserStatus(ctx, id, models.UserStatusDeleted, reason); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	return nil
+}
+
+// PurgeUser removes the user row together with their refresh tokens and
+// status history.
+func (s *UserService) PurgeUser(ctx context.Context, id uuid.UUID) (err error) {
+	const op = "userService.PurgeUser"
+	ctx, span := tracing.Start(ctx, op)
+	var deletedTokens int64
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType:     models.AuditUserPurge,
+			SubjectUserID: uuidRef(id),
+			Details:       map[string]any{"deleted_refresh_tokens": deletedTokens},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	log := s.log.With(slog.String("op", op), slog.String("admin", adminName(ctx)), slog.String("userID", id.String()))
+
+	err = s.uow.Do(ctx, func(tx pgx.Tx) error {
+		n, err := s.refreshTokenRepository.DeleteByUserTx(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		deletedTokens = n
+
+		return s.userRepository.DeleteUserTx(ctx, tx, id)
+	})
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	log.InfoContext(ctx, "user purged", slog.Int64("deletedRefreshTokens", deletedTokens))
+	return nil
+}
+
+// applyProfileParams validates and copies the set profile fields onto user.
+func applyProfileParams(user *models.User, params contracts.UpdateUserParams) error {
+	if params.Name != nil {
+		name, err := normalizeProfileName("name", *params.Name)
+		if err != nil {
+			return err
+		}
+		user.Name = name
+	}
+	if params.Surname != nil {
+		surname, err := normalizeProfileName("surname", *params.Surname)
+		if err != nil {
+			return err
+		}
+		user.Surname = surname
+	}
+	return nil
+}
+
+func normalizeProfileName(field, v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if utf8.RuneCountInString(v) > maxProfileNameLength {
+		return "", fmt.Errorf("%s must be at most %d characters", field, maxProfileNameLength)
+	}
+	for _, r := range v {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("%s must not contain control characters", field)
+		}
+	}
+	return v, nil
+}
+
+type userCursorJSON struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func encodeUserCursor(c models.UserCursor) string {
+	b, _ := json.Marshal(userCursorJSON{CreatedAt: c.CreatedAt, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeUserCursor(s string) (models.UserCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.UserCursor{}, err
+	}
+
+	var c userCursorJSON
+	if err := json.Unmarshal(b, &c); err != nil {
+		return models.UserCursor{}, err
+	}
+	return models.UserCursor{CreatedAt: c.CreatedAt, ID: c.ID}, nil
+}
+
+func toUserInfo(user models.User) contracts.UserInfo {
+	return contracts.UserInfo{
+		ID:                    user.ID,
+		Email:                 user.Email,
+		Name:                  user.Name,
+		Surname:               user.Surname,
+		Status:                user.Status,
+		PasswordResetRequired: user.PasswordResetRequired,
+		CreatedAt:             user.CreatedAt,
+		UpdatedAt:             user.UpdatedAt,
+	}
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/finaptica/sso/internal/config"
+	"github.com/finaptica/sso/internal/contracts"
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/finaptica/sso/internal/lib/token"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/finaptica/sso/internal/lib/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeliveriesPageSize = 50
+	maxDeliveriesPageSize     = 200
+	maxLastErrorLength        = 1024
+)
+
+// WebhookService manages webhook endpoints and delivers the outbox to them.
+type WebhookService struct {
+	webhookRepository WebhookRepository
+	outboxRepository  OutboxRepository
+	appRepository     AppRepository
+	sender            WebhookSender
+	audit             *auditor
+	log               *slog.Logger
+	source            string
+	batchSize         int
+	timeout           time.Duration
+	maxAttempts       int
+	backoffBase       time.Duration
+	backoffMax        time.Duration
+}
+
+// NewWebhookService returns a new instance of the WebhookService
+func NewWebhookService(log *slog.Logger, repoContainer RepositoriesContainer, sender WebhookSender, cfg *config.Config) *WebhookService {
+	return &WebhookService{
+		webhookRepository: repoContainer.WebhookRepo,
+		outboxRepository:  repoContainer.OutboxRepo,
+		appRepository:     repoContainer.AppRepo,
+		sender:            sender,
+		audit:             newAuditor(log, repoContainer.AuditRepo),
+		log:               log,
+		source:            cfg.Webhooks.Source,
+		batchSize:         cfg.Webhooks.BatchSize,
+		timeout:           cfg.Webhooks.Timeout,
+		maxAttempts:       cfg.Webhooks.MaxAttempts,
+		backoffBase:       cfg.Webhooks.BackoffBase,
+		backoffMax:        cfg.Webhooks.BackoffMax,
+	}
+}
+
+// CreateEndpoint subscribes a URL of the app to domain events. The returned
+// secret signs every delivery and is not shown again.
+func (s *WebhookService) CreateEndpoint(ctx context.Context, appID int, params contracts.CreateWebhookEndpointParams) (created contracts.CreatedWebhookEndpoint, err error) {
+	const op = "webhookService.CreateEndpoint"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditWebhookCreate,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"endpoint_id": created.ID, "url": params.URL},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := validateWebhookEndpoint(params); err != nil {
+		return contracts.CreatedWebhookEndpoint{}, errs.WithKind(op, errs.Invalid, err)
+	}
+
+	if _, err := s.appRepository.GetAppById(ctx, appID); err != nil {
+		return contracts.CreatedWebhookEndpoint{}, errs.Wrap(op, err)
+	}
+
+	secret, err := token.NewSecret()
+	if err != nil {
+		return contracts.CreatedWebhookEndpoint{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	eventTypes := params.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	endpoint, err := s.webhookRepository.CreateEndpoint(ctx, models.WebhookEndpoint{
+		AppID:      appID,
+		URL:        params.URL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+	})
+	if err != nil {
+		return contracts.CreatedWebhookEndpoint{}, errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "webhook endpoint created",
+		slog.String("op", op),
+		slog.String("admin", adminName(ctx)),
+		slog.Int("appID", appID),
+		slog.String("endpointID", endpoint.ID.String()),
+	)
+	return contracts.CreatedWebhookEndpoint{WebhookEndpointInfo: toWebhookEndpointInfo(endpoint), Secret: secret}, nil
+}
+
+func (s *WebhookService) ListEndpoints(ctx context.Context, appID int) (infos []contracts.WebhookEndpointInfo, err error) {
+	const op = "webhookService.ListEndpoints"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.appRepository.GetAppById(ctx, appID); err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	endpoints, err := s.webhookRepository.ListEndpoints(ctx, appID)
+	if err != nil {
+		return nil, errs.Wrap(op, err)
+	}
+
+	infos = make([]contracts.WebhookEndpointInfo, 0, len(endpoints))
+	for _, e := range endpoints {
+		infos = append(infos, toWebhookEndpointInfo(e))
+	}
+	return infos, nil
+}
+
+// SetEndpointDisabled pauses or resumes deliveries to the endpoint. Events
+// raised while it is disabled are not queued for it.
+func (s *WebhookService) SetEndpointDisabled(ctx context.Context, appID int, id uuid.UUID, disabled bool) (err error) {
+	const op = "webhookService.SetEndpointDisabled"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditWebhookStateChange,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"endpoint_id": id, "disabled": disabled},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := s.webhookRepository.SetEndpointDisabled(ctx, appID, id, disabled); err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+// DeleteEndpoint removes the endpoint and its delivery history.
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, appID int, id uuid.UUID) (err error) {
+	const op = "webhookService.DeleteEndpoint"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditWebhookDelete,
+			AppID:     intRef(appID),
+			Details:   map[string]any{"endpoint_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := s.webhookRepository.DeleteEndpoint(ctx, appID, id); err != nil {
+		return errs.Wrap(op, err)
+	}
+	return nil
+}
+
+// ListDeliveries returns one page of deliveries, newest first.
+func (s *WebhookService) ListDeliveries(ctx context.Context, params contracts.ListWebhookDeliveriesParams) (page contracts.WebhookDeliveryPage, err error) {
+	const op = "webhookService.ListDeliveries"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	limit := params.Limit
+	switch {
+	case limit == 0:
+		limit = defaultDeliveriesPageSize
+	case limit < 0 || limit > maxDeliveriesPageSize:
+		return contracts.WebhookDeliveryPage{}, errs.WithKind(op, errs.Invalid, errors.New("limit must be between 1 and 200"))
+	}
+
+	switch params.Status {
+	case "", models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead:
+	default:
+		return contracts.WebhookDeliveryPage{}, errs.WithKind(op, errs.Invalid, errors.New("status must be pending, delivered or dead"))
+	}
+
+	var cursor *models.WebhookDeliveryCursor
+	if params.Cursor != "" {
+		c, err := decodeDeliveryCursor(params.Cursor)
+		if err != nil {
+			return contracts.WebhookDeliveryPage{}, errs.WithKind(op, errs.Invalid, errors.New("invalid cursor"))
+		}
+		cursor = &c
+	}
+
+	filter := models.WebhookDeliveryFilter{
+		Status:     params.Status,
+		EndpointID: params.EndpointID,
+		AppID:      params.AppID,
+	}
+	deliveries, err := s.webhookRepository.ListDeliveries(ctx, filter, cursor, limit+1)
+	if err != nil {
+		return contracts.WebhookDeliveryPage{}, errs.Wrap(op, err)
+	}
+
+	page.Deliveries = make([]contracts.WebhookDeliveryInfo, 0, min(len(deliveries), limit))
+	for i, d := range deliveries {
+		if i == limit {
+			last := deliveries[limit-1]
+			page.NextCursor = encodeDeliveryCursor(models.WebhookDeliveryCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+			break
+		}
+		page.Deliveries = append(page.Deliveries, toWebhookDeliveryInfo(d))
+	}
+
+	return page, nil
+}
+
+func (s *WebhookService) GetDelivery(ctx context.Context, id uuid.UUID) (detail contracts.WebhookDeliveryDetail, err error) {
+	const op = "webhookService.GetDelivery"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	d, e, err := s.webhookRepository.GetDelivery(ctx, id)
+	if err != nil {
+		return contracts.WebhookDeliveryDetail{}, errs.Wrap(op, err)
+	}
+
+	return contracts.WebhookDeliveryDetail{
+		WebhookDeliveryInfo: toWebhookDeliveryInfo(d),
+		Event: contracts.OutboxEventInfo{
+			ID:         e.ID,
+			Type:       e.Type,
+			Subject:    e.Subject,
+			AppID:      e.AppID,
+			Data:       e.Data,
+			OccurredAt: e.OccurredAt,
+		},
+	}, nil
+}
+
+// ReplayDelivery queues the delivery again, whatever state it is in, with a
+// fresh attempt budget.
+func (s *WebhookService) ReplayDelivery(ctx context.Context, id uuid.UUID) (err error) {
+	const op = "webhookService.ReplayDelivery"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() {
+		s.audit.record(ctx, models.AuditEvent{
+			EventType: models.AuditWebhookReplay,
+			Details:   map[string]any{"delivery_id": id},
+		}, err)
+		tracing.End(span, err)
+	}()
+
+	if err := s.webhookRepository.Requeue(ctx, id); err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	s.log.InfoContext(ctx, "webhook delivery requeued",
+		slog.String("op", op),
+		slog.String("admin", adminName(ctx)),
+		slog.String("deliveryID", id.String()),
+	)
+	return nil
+}
+
+// Dispatch runs one round: fan out new outbox events, then attempt every due
+// delivery once.
+func (s *WebhookService) Dispatch(ctx context.Context) (err error) {
+	const op = "webhookService.Dispatch"
+	ctx, span := tracing.Start(ctx, op)
+	defer func() { tracing.End(span, err) }()
+
+	log := s.log.With(slog.String("op", op))
+
+	events, deliveries, err := s.outboxRepository.FanOut(ctx, s.batchSize)
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+	if events > 0 {
+		log.DebugContext(ctx, "outbox fanned out", slog.Int("events", events), slog.Int64("deliveries", deliveries))
+	}
+
+	// The lease has to outlast a send so a slow endpoint is not claimed
+	// twice.
+	jobs, err := s.webhookRepository.ClaimDue(ctx, s.batchSize, 2*s.timeout)
+	if err != nil {
+		return errs.Wrap(op, err)
+	}
+
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return nil
+		}
+		s.deliver(ctx, log, job)
+	}
+
+	return nil
+}
+
+func (s *WebhookService) deliver(ctx context.Context, log *slog.Logger, job models.WebhookJob) {
+	d := job.Delivery
+	log = log.With(slog.String("deliveryID", d.ID.String()), slog.String("eventType", job.Event.Type))
+
+	data := job.Event.Data
+	if data == nil {
+		data = map[string]any{}
+	}
+	body, err := json.Marshal(webhook.NewCloudEvent(
+		job.Event.ID.String(), s.source, job.Event.Type, job.Event.Subject, job.Event.OccurredAt, data,
+	))
+	var status int
+	if err == nil {
+		status, err = s.sender.Send(ctx, job.Endpoint.URL, job.Endpoint.Secret, d.ID.String(), body)
+	}
+
+	now := time.Now().UTC()
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.LastStatusCode = nil
+	if status != 0 {
+		d.LastStatusCode = &status
+	}
+
+	result := models.DeliveryDelivered
+	switch {
+	case err == nil:
+		d.Status = models.DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = &now
+	case d.Attempts >= s.maxAttempts:
+		d.Status = models.DeliveryDead
+		d.LastError = truncate(err.Error(), maxLastErrorLength)
+		result = models.DeliveryDead
+	default:
+		d.Status = models.DeliveryPending
+		d.LastError = truncate(err.Error(), maxLastErrorLength)
+		d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+		result = "retry"
+	}
+	metrics.WebhookAttempts.WithLabelValues(result).Inc()
+
+	if err != nil {
+		log.WarnContext(ctx, "webhook delivery failed", slog.Int("attempts", d.Attempts), slog.String("status", d.Status), sl.Err(err))
+	}
+
+	// Record the outcome even if the dispatcher is stopping, or the delivery
+	// would be sent again once its lease runs out.
+	if err := s.webhookRepository.RecordAttempt(context.WithoutCancel(ctx), d); err != nil {
+		log.ErrorContext(ctx, "failed to record webhook attempt", sl.Err(err))
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	wait := s.backoffBase
+	for i := 1; i < attempts && wait < s.backoffMax; i++ {
+		wait *= 2
+	}
+	return min(wait, s.backoffMax)
+}
+
+func validateWebhookEndpoint(params contracts.CreateWebhookEndpointParams) error {
+	u, err := url.Parse(params.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	for _, t := range params.EventTypes {
+		if !models.IsKnownEventType(t) {
+			return errors.New("unknown event type " + t)
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+type deliveryCursorJSON struct {
+	CreatedAt time.Time `json:"c"`
+	ID        uuid.UUID `json:"i"`
+}
+
+func encodeDeliveryCursor(c models.WebhookDeliveryCursor) string {
+	b, _ := json.Marshal(deliveryCursorJSON{CreatedAt: c.CreatedAt, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeDeliveryCursor(s string) (models.WebhookDeliveryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.WebhookDeliveryCursor{}, err
+	}
+
+	var c deliveryCursorJSON
+	if err := json.Unmarshal(b, &c); err != nil {
+		return models.WebhookDeliveryCursor{}, err
+	}
+	return models.WebhookDeliveryCursor{CreatedAt: c.CreatedAt, ID: c.ID}, nil
+}
+
+func toWebhookEndpointInfo(e models.WebhookEndpoint) contracts.WebhookEndpointInfo {
+	return contracts.WebhookEndpointInfo{
+		ID:         e.ID,
+		AppID:      e.AppID,
+		URL:        e.URL,
+		EventTypes: e.EventTypes,
+		IsDisabled: e.IsDisabled,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func toWebhookDeliveryInfo(d models.WebhookDelivery) contracts.WebhookDeliveryInfo {
+	info := contracts.WebhookDeliveryInfo{
+		ID:             d.ID,
+		EventID:        d.EventID,
+		EndpointID:     d.EndpointID,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastAttemptAt:  d.LastAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+	if d.Status == models.DeliveryPending {
+		next := d.NextAttemptAt
+		info.NextAttemptAt = &next
+	}
+	return info
+}
@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var errLockLost = errors.New("lock connection lost")
+
+// AdvisoryLocker runs work under Postgres advisory locks, so that of all the
+// replicas sharing a database only one does it at a time.
+//
+// The locks are session locks. All those a replica holds live on one
+// connection taken out of the pool while any is held, so holding them costs
+// a single connection however many jobs run.
+type AdvisoryLocker struct {
+	db *pgxpool.Pool
+
+	mu    sync.Mutex
+	conn  *pgxpool.Conn
+	locks map[string]bool
+	// gen changes whenever conn is replaced, which frees every lock taken
+	// on the old one.
+	gen int
+}
+
+func NewAdvisoryLocker(db *pgxpool.Pool) *AdvisoryLocker {
+	return &AdvisoryLocker{db: db, locks: make(map[string]bool)}
+}
+
+// TryWithLock runs fn while holding the advisory lock called name. It returns
+// false without running fn when another replica, or this one, holds the lock.
+// fn runs its queries through the pool like any other code.
+func (l *AdvisoryLocker) TryWithLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	locked, _, err := l.tryLock(ctx, name)
+	if err != nil || !locked {
+		return false, err
+	}
+	defer l.unlock(ctx, name)
+
+	return true, fn(ctx)
+}
+
+// TryLead is TryWithLock for fn that run until their context ends, such as
+// a leader's loop. It checks the lock every interval and cancels fn's
+// context once the lock connection, and with it the lock, is lost, so that
+// two replicas never lead at the same time for long.
+func (l *AdvisoryLocker) TryLead(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) (bool, error) {
+	locked, gen, err := l.tryLock(ctx, name)
+	if err != nil || !locked {
+		return false, err
+	}
+	defer l.unlock(ctx, name)
+
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	lost := make(chan error, 1)
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leadCtx.Done():
+				return
+			case <-ticker.C:
+				if err := l.check(leadCtx, gen); err != nil {
+					lost <- err
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(leadCtx)
+	cancel()
+	<-watching
+
+	select {
+	case lostErr := <-lost:
+		return true, fmt.Errorf("lost advisory lock %q: %w", name, lostErr)
+	default:
+		return true, err
+	}
+}
+
+// Queries on the lock connection ignore cancellation: pgx closes a
+// connection whose query is cancelled, which would free every lock on it.
+
+func (l *AdvisoryLocker) tryLock(ctx context.Context, name string) (bool, int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Session locks are reentrant, so Postgres would grant a second holder
+	// on this replica the lock the first one has.
+	if l.locks[name] {
+		return false, 0, nil
+	}
+
+	if l.conn == nil {
+		conn, err := l.db.Acquire(ctx)
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to acquire connection: %w", err)
+		}
+		l.conn = conn
+		l.gen++
+	}
+
+	var locked bool
+	err := l.conn.QueryRow(context.WithoutCancel(ctx), "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&locked)
+	if err != nil {
+		l.dropConn(ctx)
+		return false, 0, fmt.Errorf("failed to take advisory lock %q: %w", name, err)
+	}
+	if !locked {
+		l.releaseIfIdle()
+		return false, 0, nil
+	}
+
+	l.locks[name] = true
+	return true, l.gen, nil
+}
+
+func (l *AdvisoryLocker) unlock(ctx context.Context, name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The lock went with a connection dropped since it was taken.
+	if !l.locks[name] {
+		return
+	}
+	delete(l.locks, name)
+
+	if _, err := l.conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock(hashtext($1))", name); err != nil {
+		l.dropConn(ctx)
+		return
+	}
+	l.releaseIfIdle()
+}
+
+// check fails once the connection the lock was taken on at gen is gone.
+func (l *AdvisoryLocker) check(ctx context.Context, gen int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil || l.gen != gen {
+		return errLockLost
+	}
+	if err := l.conn.Ping(context.WithoutCancel(ctx)); err != nil {
+		l.dropConn(ctx)
+		return fmt.Errorf("%w: %w", errLockLost, err)
+	}
+	return nil
+}
+
+// dropConn closes the lock connection, which may still hold locks and so
+// must not go back to the pool. Ending the session frees them all.
+func (l *AdvisoryLocker) dropConn(ctx context.Context) {
+	l.conn.Conn().Close(context.WithoutCancel(ctx))
+	l.conn.Release()
+	l.conn = nil
+	clear(l.locks)
+}
+
+func (l *AdvisoryLocker) releaseIfIdle() {
+	if len(l.locks) == 0 {
+		l.conn.Release()
+		l.conn = nil
+	}
+}
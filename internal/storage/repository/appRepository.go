@@ -2,29 +2,40 @@ package repository
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"log/slog"
+	"time"
 
 	"github.com/finaptica/sso/internal/domain/models"
 	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const appColumns = `id, name, redirect_uris, allowed_grant_types, access_token_ttl_seconds,
+	refresh_token_ttl_seconds, session_lifetime_seconds, offline_access, offline_idle_timeout_seconds,
+	offline_session_lifetime_seconds, is_disabled, org_id, registration_policy, registration_domains, created_at, updated_at`
+
 type AppRepository struct {
-	db  *pgx.Conn
+	db  *pgxpool.Pool
 	log *slog.Logger
 }
 
-func NewAppRepository(log *slog.Logger, db *pgx.Conn) *AppRepository {
+func NewAppRepository(log *slog.Logger, db *pgxpool.Pool) *AppRepository {
 	return &AppRepository{log: log, db: db}
 }
 
 func (r *AppRepository) GetAppById(ctx context.Context, appId int) (models.App, error) {
 	const op = "appRepository.GetApp"
-	var app models.App
-	err := r.db.QueryRow(ctx, "SELECT id, name, secret FROM apps WHERE id = $1", appId).Scan(&app.ID, &app.Name, &app.Secret)
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	app, err := scanApp(r.db.QueryRow(ctx, "SELECT "+appColumns+" FROM apps WHERE id = $1", appId))
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return models.App{}, errs.WithKind(op, errs.NotFound, err)
 		}
 		return models.App{}, errs.WithKind(op, errs.Internal, err)
@@ -34,13 +45,387 @@ func (r *AppRepository) GetAppById(ctx context.Context, appId int) (models.App,
 
 func (r *AppRepository) GetAppByIDTx(ctx context.Context, tx pgx.Tx, id int) (models.App, error) {
 	const op = "appRepository.GetAppByIDTx"
-	var app models.App
-	err := tx.QueryRow(ctx, "SELECT id,name,secret FROM apps WHERE id = $1", id).Scan(&app.ID, &app.Name, &app.Secret)
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	app, err := scanApp(tx.QueryRow(ctx, "SELECT "+appColumns+" FROM apps WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.App{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		return models.App{}, errs.WithKind(op, errs.Internal, err)
+	}
+	return app, nil
+}
+
+// GetAppForUpdateTx reads an app and locks its row until tx ends, for a
+// read-modify-write that must not lose a concurrent update.
+func (r *AppRepository) GetAppForUpdateTx(ctx context.Context, tx pgx.Tx, id int) (models.App, error) {
+	const op = "appRepository.GetAppForUpdateTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	app, err := scanApp(tx.QueryRow(ctx, "SELECT "+appColumns+" FROM apps WHERE id = $1 FOR UPDATE", id))
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return models.App{}, errs.WithKind(op, errs.NotFound, err)
 		}
 		return models.App{}, errs.WithKind(op, errs.Internal, err)
 	}
 	return app, nil
 }
+
+func (r *AppRepository) ListApps(ctx context.Context) ([]models.App, error) {
+	const op = "appRepository.ListApps"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op))
+
+	rows, err := r.db.Query(ctx, "SELECT "+appColumns+" FROM apps ORDER BY id")
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list apps", sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to scan app", sl.Err(err))
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return apps, nil
+}
+
+func (r *AppRepository) CreateAppTx(ctx context.Context, tx pgx.Tx, app models.App) (int, error) {
+	const op = "appRepository.CreateAppTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("name", app.Name))
+
+	var id int
+	err := tx.QueryRow(ctx,
+		`INSERT INTO apps (name, redirect_uris, allowed_grant_types, access_token_ttl_seconds, refresh_token_ttl_seconds,
+			session_lifetime_seconds, offline_access, offline_idle_timeout_seconds, offline_session_lifetime_seconds,
+			org_id, registration_policy, registration_domains)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		app.Name, app.RedirectURIs, app.AllowedGrantTypes, ttlSeconds(app.AccessTokenTTL), ttlSeconds(app.RefreshTokenTTL),
+		ttlSeconds(app.SessionLifetime), app.OfflineAccess, ttlSeconds(app.OfflineIdleTimeout), ttlSeconds(app.OfflineSessionLifetime),
+		app.OrgID, app.RegistrationPolicy, app.RegistrationDomains,
+	).Scan(&id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return 0, errs.WithKind(op, errs.NotFound, err)
+		}
+		log.ErrorContext(ctx, "failed to create app", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return id, nil
+}
+
+func (r *AppRepository) UpdateAppTx(ctx context.Context, tx pgx.Tx, app models.App) error {
+	const op = "appRepository.UpdateAppTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", app.ID))
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE apps SET name = $2, redirect_uris = $3, allowed_grant_types = $4,
+		 access_token_ttl_seconds = $5, refresh_token_ttl_seconds = $6, session_lifetime_seconds = $7,
+		 offline_access = $8, offline_idle_timeout_seconds = $9, offline_session_lifetime_seconds = $10, org_id = $11,
+		 registration_policy = $12, registration_domains = $13, updated_at = now()
+		 WHERE id = $1`,
+		app.ID, app.Name, app.RedirectURIs, app.AllowedGrantTypes, ttlSeconds(app.AccessTokenTTL), ttlSeconds(app.RefreshTokenTTL),
+		ttlSeconds(app.SessionLifetime), app.OfflineAccess, ttlSeconds(app.OfflineIdleTimeout), ttlSeconds(app.OfflineSessionLifetime),
+		app.OrgID, app.RegistrationPolicy, app.RegistrationDomains,
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errs.WithKind(op, errs.NotFound, err)
+		}
+		log.ErrorContext(ctx, "failed to update app", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *AppRepository) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	const op = "appRepository.SetDisabled"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", id))
+
+	tag, err := r.db.Exec(ctx, "UPDATE apps SET is_disabled = $2, updated_at = now() WHERE id = $1", id, disabled)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to change app state", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *AppRepository) DeleteAppTx(ctx context.Context, tx pgx.Tx, id int) error {
+	const op = "appRepository.DeleteAppTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", id))
+
+	tag, err := tx.Exec(ctx, "DELETE FROM apps WHERE id = $1", id)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete app", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *AppRepository) AddSecretTx(ctx context.Context, tx pgx.Tx, appID int, secretHash []byte) (uuid.UUID, error) {
+	const op = "appRepository.AddSecretTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", appID))
+
+	var id uuid.UUID
+	err := tx.QueryRow(ctx,
+		"INSERT INTO app_secrets (app_id, secret_hash) VALUES ($1, $2) RETURNING id",
+		appID, string(secretHash),
+	).Scan(&id)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to add app secret", sl.Err(err))
+		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return id, nil
+}
+
+// ExpireSecretsTx makes every secret of the app that would outlive expiresAt
+// expire at expiresAt instead.
+func (r *AppRepository) ExpireSecretsTx(ctx context.Context, tx pgx.Tx, appID int, expiresAt time.Time) (int64, error) {
+	const op = "appRepository.ExpireSecretsTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", appID))
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE app_secrets SET expires_at = $2
+		 WHERE app_id = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		appID, expiresAt,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to expire app secrets", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r *AppRepository) ListSecrets(ctx context.Context, appID int) ([]models.AppSecret, error) {
+	const op = "appRepository.ListSecrets"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", appID))
+
+	rows, err := r.db.Query(ctx,
+		"SELECT id, app_id, secret_hash, created_at, expires_at FROM app_secrets WHERE app_id = $1 ORDER BY created_at",
+		appID,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list app secrets", sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var secrets []models.AppSecret
+	for rows.Next() {
+		var s models.AppSecret
+		var hash string
+		if err := rows.Scan(&s.ID, &s.AppID, &hash, &s.CreatedAt, &s.ExpiresAt); err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		s.SecretHash = []byte(hash)
+		secrets = append(secrets, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return secrets, nil
+}
+
+func (r *AppRepository) DeleteSecret(ctx context.Context, appID int, secretID uuid.UUID) error {
+	const op = "appRepository.DeleteSecret"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", appID))
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM app_secrets WHERE app_id = $1 AND id = $2", appID, secretID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete app secret", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func scanApp(row pgx.Row) (models.App, error) {
+	var app models.App
+	var accessTTL, refreshTTL, lifetime, offlineIdle, offlineLifetime *int32
+	err := row.Scan(
+		&app.ID, &app.Name, &app.RedirectURIs, &app.AllowedGrantTypes,
+		&accessTTL, &refreshTTL, &lifetime, &app.OfflineAccess, &offlineIdle, &offlineLifetime,
+		&app.IsDisabled, &app.OrgID, &app.RegistrationPolicy, &app.RegistrationDomains,
+		&app.CreatedAt, &app.UpdatedAt,
+	)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	app.AccessTokenTTL = secondsTTL(accessTTL)
+	app.RefreshTokenTTL = secondsTTL(refreshTTL)
+	app.SessionLifetime = secondsTTL(lifetime)
+	app.OfflineIdleTimeout = secondsTTL(offlineIdle)
+	app.OfflineSessionLifetime = secondsTTL(offlineLifetime)
+
+	return app, nil
+}
+
+// secondsTTL is the inverse of ttlSeconds; NULL reads as no override.
+func secondsTTL(s *int32) time.Duration {
+	if s == nil {
+		return 0
+	}
+	return time.Duration(*s) * time.Second
+}
+
+// ttlSeconds maps a zero TTL, meaning "use the global default", to NULL.
+func ttlSeconds(d time.Duration) *int32 {
+	if d <= 0 {
+		return nil
+	}
+	s := int32(d / time.Second)
+	return &s
+}
+
+const appInvitationColumns = `id, app_id, COALESCE(email, ''), role_ids, created_by, created_at, expires_at, used_at, used_by`
+
+func scanAppInvitation(row pgx.Row) (models.AppInvitation, error) {
+	var i models.AppInvitation
+	err := row.Scan(&i.ID, &i.AppID, &i.Email, &i.RoleIDs, &i.CreatedBy, &i.CreatedAt, &i.ExpiresAt, &i.UsedAt, &i.UsedBy)
+	return i, err
+}
+
+func (r *AppRepository) CreateInvitation(ctx context.Context, inv models.AppInvitation) (models.AppInvitation, error) {
+	const op = "appRepository.CreateInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var email *string
+	if inv.Email != "" {
+		email = &inv.Email
+	}
+
+	created, err := scanAppInvitation(r.db.QueryRow(ctx,
+		`INSERT INTO app_invitations (app_id, email, role_ids, created_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING `+appInvitationColumns,
+		inv.AppID, email, inv.RoleIDs, inv.CreatedBy, inv.ExpiresAt,
+	))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return models.AppInvitation{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to create app invitation", slog.String("op", op), sl.Err(err))
+		return models.AppInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return created, nil
+}
+
+// ListInvitations returns the app's invitations that were not used yet,
+// expired ones included.
+func (r *AppRepository) ListInvitations(ctx context.Context, appID int) ([]models.AppInvitation, error) {
+	const op = "appRepository.ListInvitations"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	invitations, err := collect(ctx, r.db, scanAppInvitation,
+		"SELECT "+appInvitationColumns+" FROM app_invitations WHERE app_id = $1 AND used_at IS NULL ORDER BY created_at", appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list app invitations", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return invitations, nil
+}
+
+// DeleteInvitation revokes an invitation that was not used yet.
+func (r *AppRepository) DeleteInvitation(ctx context.Context, appID int, id uuid.UUID) error {
+	const op = "appRepository.DeleteInvitation"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM app_invitations WHERE app_id = $1 AND id = $2 AND used_at IS NULL", appID, id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete app invitation", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// UseInvitationTx marks the invitation used by userID and returns it. It
+// fails with NotFound unless the invitation exists for the app, is unused
+// and has not expired at at, so a token is consumed at most once.
+func (r *AppRepository) UseInvitationTx(ctx context.Context, tx pgx.Tx, appID int, id, userID uuid.UUID, at time.Time) (models.AppInvitation, error) {
+	const op = "appRepository.UseInvitationTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	inv, err := scanAppInvitation(tx.QueryRow(ctx,
+		`UPDATE app_invitations SET used_at = $4, used_by = $3
+		 WHERE id = $2 AND app_id = $1 AND used_at IS NULL AND expires_at > $4
+		 RETURNING `+appInvitationColumns,
+		appID, id, userID, at,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.AppInvitation{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to use app invitation", slog.String("op", op), sl.Err(err))
+		return models.AppInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return inv, nil
+}
@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/auditchain"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const auditColumns = `id, occurred_at, event_type, actor, subject_user_id, app_id, ip, user_agent,
+	request_id, outcome, reason, details, prev_hash, hash`
+
+const auditCheckpointColumns = `id, event_id, event_hash, created_at, key_id, signature`
+
+// auditChainLockID is the advisory lock that serializes appends to the audit
+// chain, so every event links to the one committed right before it. Only the
+// chaining job takes it, never a request.
+const auditChainLockID = 0x61756469745f6368
+
+const auditPendingColumns = `id, occurred_at, event_type, actor, subject_user_id, app_id, ip, user_agent,
+	request_id, outcome, reason, details`
+
+type AuditRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewAuditRepository(log *slog.Logger, db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{log: log, db: db}
+}
+
+// Insert queues e for the audit chain. OccurredAt is set here; ChainPending
+// links the event into the chain later.
+func (r *AuditRepository) Insert(ctx context.Context, e models.AuditEvent) error {
+	const op = "auditRepository.Insert"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO audit_pending (occurred_at, event_type, actor, subject_user_id, app_id, ip, user_agent, request_id,
+			outcome, reason, details)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		auditchain.Timestamp(time.Now()), e.EventType, e.Actor, e.SubjectUserID, e.AppID, e.IP, e.UserAgent, e.RequestID,
+		e.Outcome, e.Reason, e.Details,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to queue audit event", slog.String("op", op), slog.String("eventType", e.EventType), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+// ChainPending moves up to limit queued events, oldest first, into the audit
+// chain and returns how many it moved.
+func (r *AuditRepository) ChainPending(ctx context.Context, limit int) (int, error) {
+	const op = "auditRepository.ChainPending"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op))
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to begin transaction", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", int64(auditChainLockID)); err != nil {
+		log.ErrorContext(ctx, "failed to lock audit chain", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	pending, err := collect(ctx, tx, scanPendingAuditEvent,
+		`DELETE FROM audit_pending WHERE id IN (SELECT id FROM audit_pending ORDER BY id LIMIT $1)
+		 RETURNING `+auditPendingColumns, limit)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to take queued audit events", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	// DELETE ... RETURNING does not keep the subquery's order.
+	slices.SortFunc(pending, func(a, b models.AuditEvent) int { return cmp.Compare(a.ID, b.ID) })
+
+	var prev []byte
+	err = tx.QueryRow(ctx, "SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&prev)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.ErrorContext(ctx, "failed to read audit chain head", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+	if prev == nil {
+		prev = auditchain.Genesis
+	}
+
+	batch := &pgx.Batch{}
+	for _, e := range pending {
+		e.PrevHash = prev
+		e.Hash, err = auditchain.Hash(prev, e)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to hash audit event", sl.Err(err))
+			return 0, errs.WithKind(op, errs.Internal, err)
+		}
+		prev = e.Hash
+
+		batch.Queue(
+			`INSERT INTO audit_events (occurred_at, event_type, actor, subject_user_id, app_id, ip, user_agent, request_id,
+				outcome, reason, details, prev_hash, hash)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			e.OccurredAt, e.EventType, e.Actor, e.SubjectUserID, e.AppID, e.IP, e.UserAgent, e.RequestID,
+			e.Outcome, e.Reason, e.Details, e.PrevHash, e.Hash,
+		)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		log.ErrorContext(ctx, "failed to insert audit events", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.ErrorContext(ctx, "failed to commit audit events", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return len(pending), nil
+}
+
+func scanPendingAuditEvent(row pgx.Row) (models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(
+		&e.ID, &e.OccurredAt, &e.EventType, &e.Actor, &e.SubjectUserID, &e.AppID, &e.IP, &e.UserAgent,
+		&e.RequestID, &e.Outcome, &e.Reason, &e.Details,
+	)
+	return e, err
+}
+
+// List returns up to limit events matching filter, newest first, with IDs
+// below beforeID when it is set.
+func (r *AuditRepository) List(ctx context.Context, filter models.AuditFilter, beforeID int64, limit int) ([]models.AuditEvent, error) {
+	const op = "auditRepository.List"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op))
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.EventType != "" {
+		where = append(where, "event_type = "+arg(filter.EventType))
+	}
+	if filter.Actor != "" {
+		where = append(where, "actor = "+arg(filter.Actor))
+	}
+	if filter.SubjectUserID != uuid.Nil {
+		where = append(where, "subject_user_id = "+arg(filter.SubjectUserID))
+	}
+	if filter.AppID != 0 {
+		where = append(where, "app_id = "+arg(filter.AppID))
+	}
+	if filter.Outcome != "" {
+		where = append(where, "outcome = "+arg(filter.Outcome))
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "occurred_at >= "+arg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "occurred_at < "+arg(filter.To))
+	}
+	if beforeID > 0 {
+		where = append(where, "id < "+arg(beforeID))
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_events"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list audit events", sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to scan audit event", sl.Err(err))
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return events, nil
+}
+
+func scanAuditEvent(row pgx.Row) (models.AuditEvent, error) {
+	var e models.AuditEvent
+	err := row.Scan(
+		&e.ID, &e.OccurredAt, &e.EventType, &e.Actor, &e.SubjectUserID, &e.AppID, &e.IP, &e.UserAgent,
+		&e.RequestID, &e.Outcome, &e.Reason, &e.Details, &e.PrevHash, &e.Hash,
+	)
+	return e, err
+}
+
+// ChainHead returns the newest chained event.
+func (r *AuditRepository) ChainHead(ctx context.Context) (models.AuditEvent, error) {
+	const op = "auditRepository.ChainHead"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	row := r.db.QueryRow(ctx,
+		"SELECT "+auditColumns+" FROM audit_events WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1")
+	e, err := scanAuditEvent(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.AuditEvent{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to read audit chain head", slog.String("op", op), sl.Err(err))
+		return models.AuditEvent{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return e, nil
+}
+
+// EventIDRange returns the first and last event IDs whose occurred_at falls
+// in [from, to). Zero bounds are open. Everything between the two IDs is
+// verified, so events with skewed timestamps cannot slip through a gap.
+func (r *AuditRepository) EventIDRange(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	const op = "auditRepository.EventIDRange"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var first, last *int64
+	err := r.db.QueryRow(ctx,
+		`SELECT min(id), max(id) FROM audit_events
+		 WHERE ($1::timestamptz IS NULL OR occurred_at >= $1) AND ($2::timestamptz IS NULL OR occurred_at < $2)`,
+		nullTime(from), nullTime(to),
+	).Scan(&first, &last)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to read audit event range", slog.String("op", op), sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+	if first == nil {
+		return 0, 0, errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return *first, *last, nil
+}
+
+// HashBefore returns the hash of the event right before id, nil when there is
+// none or it predates the chain.
+func (r *AuditRepository) HashBefore(ctx context.Context, id int64) ([]byte, error) {
+	const op = "auditRepository.HashBefore"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var hash []byte
+	err := r.db.QueryRow(ctx, "SELECT hash FROM audit_events WHERE id < $1 ORDER BY id DESC LIMIT 1", id).Scan(&hash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		r.log.ErrorContext(ctx, "failed to read preceding audit event", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return hash, nil
+}
+
+// Walk calls fn for every event with an ID in [firstID, lastID], oldest first,
+// and stops at the first error fn returns.
+func (r *AuditRepository) Walk(ctx context.Context, firstID, lastID int64, fn func(models.AuditEvent) error) error {
+	const op = "auditRepository.Walk"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+auditColumns+" FROM audit_events WHERE id BETWEEN $1 AND $2 ORDER BY id", firstID, lastID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to walk audit events", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e, err := scanAuditEvent(rows)
+		if err != nil {
+			return errs.WithKind(op, errs.Internal, err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (r *AuditRepository) InsertCheckpoint(ctx context.Context, cp models.AuditCheckpoint) error {
+	const op = "auditRepository.InsertCheckpoint"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO audit_checkpoints (event_id, event_hash, created_at, key_id, signature)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (event_id) DO NOTHING`,
+		cp.EventID, cp.EventHash, cp.CreatedAt, cp.KeyID, cp.Signature,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to insert audit checkpoint", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.AlreadyExists, errors.New("event already checkpointed"))
+	}
+
+	return nil
+}
+
+// ListCheckpoints returns the checkpoints for events in [firstID, lastID],
+// ordered by event.
+func (r *AuditRepository) ListCheckpoints(ctx context.Context, firstID, lastID int64) ([]models.AuditCheckpoint, error) {
+	const op = "auditRepository.ListCheckpoints"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+auditCheckpointColumns+" FROM audit_checkpoints WHERE event_id BETWEEN $1 AND $2 ORDER BY event_id",
+		firstID, lastID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list audit checkpoints", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var checkpoints []models.AuditCheckpoint
+	for rows.Next() {
+		var cp models.AuditCheckpoint
+		if err := rows.Scan(&cp.ID, &cp.EventID, &cp.EventHash, &cp.CreatedAt, &cp.KeyID, &cp.Signature); err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return checkpoints, nil
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type EmailChangeRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewEmailChangeRepository(log *slog.Logger, db *pgxpool.Pool) *EmailChangeRepository {
+	return &EmailChangeRepository{log: log, db: db}
+}
+
+// ReplacePendingTx drops the user's unconfirmed requests and stores change in
+// their place, so only the latest confirmation token works.
+func (r *EmailChangeRepository) ReplacePendingTx(ctx context.Context, tx pgx.Tx, change models.EmailChange) (uuid.UUID, error) {
+	const op = "emailChangeRepository.ReplacePendingTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("userID", change.UserID.String()))
+
+	_, err := tx.Exec(ctx, "DELETE FROM email_changes WHERE user_id = $1 AND confirmed_at IS NULL", change.UserID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to drop pending email changes", sl.Err(err))
+		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	var id uuid.UUID
+	err = tx.QueryRow(ctx,
+		`INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		 VALUES ($1, $2, $3, $4) RETURNING id`,
+		change.UserID, change.NewEmail, change.TokenHash, change.ExpiresAt,
+	).Scan(&id)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to create email change", sl.Err(err))
+		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return id, nil
+}
+
+func (r *EmailChangeRepository) GetByTokenHashTx(ctx context.Context, tx pgx.Tx, tokenHash []byte) (models.EmailChange, error) {
+	const op = "emailChangeRepository.GetByTokenHashTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var c models.EmailChange
+	err := tx.QueryRow(ctx,
+		`SELECT id, user_id, new_email, token_hash, created_at, expires_at, confirmed_at
+		 FROM email_changes WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&c.ID, &c.UserID, &c.NewEmail, &c.TokenHash, &c.CreatedAt, &c.ExpiresAt, &c.ConfirmedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.EmailChange{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get email change", slog.String("op", op), sl.Err(err))
+		return models.EmailChange{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return c, nil
+}
+
+func (r *EmailChangeRepository) MarkConfirmedTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, at time.Time) error {
+	const op = "emailChangeRepository.MarkConfirmedTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx, "UPDATE email_changes SET confirmed_at = $2 WHERE id = $1 AND confirmed_at IS NULL", id, at)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to confirm email change", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// purgeQueries delete at most $2 rows of an artifact that stopped being
+// usable before $1. Revoked refresh tokens count from their rotation, or
+// from their creation when they were revoked some other way.
+var purgeQueries = map[string]string{
+	models.ArtifactRefreshTokens: `DELETE FROM refresh_tokens WHERE id IN (
+		SELECT id FROM refresh_tokens
+		WHERE expires_at < $1 OR (is_revoked AND COALESCE(rotated_at, created_at) < $1)
+		LIMIT $2)`,
+	models.ArtifactSessions: `DELETE FROM sessions WHERE id IN (
+		SELECT id FROM sessions WHERE expires_at < $1 OR revoked_at < $1 LIMIT $2)`,
+	models.ArtifactEmailChanges: `DELETE FROM email_changes WHERE id IN (
+		SELECT id FROM email_changes WHERE expires_at < $1 OR confirmed_at < $1 LIMIT $2)`,
+	models.ArtifactAppInvitations: `DELETE FROM app_invitations WHERE id IN (
+		SELECT id FROM app_invitations WHERE expires_at < $1 OR used_at < $1 LIMIT $2)`,
+	models.ArtifactOrgInvitations: `DELETE FROM org_invitations WHERE id IN (
+		SELECT id FROM org_invitations WHERE expires_at < $1 OR accepted_at < $1 LIMIT $2)`,
+	models.ArtifactJobRuns: `DELETE FROM job_runs WHERE id IN (
+		SELECT id FROM job_runs WHERE finished_at < $1 LIMIT $2)`,
+}
+
+type JanitorRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewJanitorRepository(log *slog.Logger, db *pgxpool.Pool) *JanitorRepository {
+	return &JanitorRepository{log: log, db: db}
+}
+
+// Purge deletes one batch of at most limit dead rows of artifact, one of the
+// models.Artifact* constants, and returns how many it deleted.
+func (r *JanitorRepository) Purge(ctx context.Context, artifact string, before time.Time, limit int) (int64, error) {
+	const op = "janitorRepository.Purge"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	query, ok := purgeQueries[artifact]
+	if !ok {
+		return 0, errs.WithKind(op, errs.Internal, fmt.Errorf("unknown artifact %q", artifact))
+	}
+
+	tag, err := r.db.Exec(ctx, query, before, limit)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to purge", slog.String("op", op), slog.String("artifact", artifact), sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
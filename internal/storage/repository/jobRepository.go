@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const jobRunColumns = `id, job, trigger, triggered_by, instance, status, error, started_at, finished_at`
+
+type JobRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewJobRepository(log *slog.Logger, db *pgxpool.Pool) *JobRepository {
+	return &JobRepository{log: log, db: db}
+}
+
+func scanJobRun(row pgx.Row) (models.JobRun, error) {
+	var run models.JobRun
+	err := row.Scan(&run.ID, &run.Job, &run.Trigger, &run.TriggeredBy, &run.Instance, &run.Status,
+		&run.Error, &run.StartedAt, &run.FinishedAt)
+	return run, err
+}
+
+// StartRun records a run of run.Job as running. The caller holds the job's
+// lock, so any other run of the job still marked running was cut short and
+// is marked failed on the way.
+func (r *JobRepository) StartRun(ctx context.Context, run models.JobRun) (models.JobRun, error) {
+	const op = "jobRepository.StartRun"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	started, err := scanJobRun(r.db.QueryRow(ctx,
+		`WITH interrupted AS (
+			UPDATE job_runs SET status = 'failed', error = 'interrupted', finished_at = now()
+			WHERE job = $1 AND status = 'running'
+		)
+		INSERT INTO job_runs (job, trigger, triggered_by, instance)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+jobRunColumns,
+		run.Job, run.Trigger, run.TriggeredBy, run.Instance,
+	))
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to start job run", slog.String("op", op), slog.String("job", run.Job), sl.Err(err))
+		return models.JobRun{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return started, nil
+}
+
+// FinishRun records the outcome of a running run.
+func (r *JobRepository) FinishRun(ctx context.Context, id uuid.UUID, status, runErr string, finishedAt time.Time) error {
+	const op = "jobRepository.FinishRun"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx,
+		`UPDATE job_runs SET status = $2, error = $3, finished_at = $4
+		 WHERE id = $1 AND status = 'running'`,
+		id, status, runErr, finishedAt,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to finish job run", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ListRuns returns the latest runs of job, newest first.
+func (r *JobRepository) ListRuns(ctx context.Context, job string, limit int) ([]models.JobRun, error) {
+	const op = "jobRepository.ListRuns"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	runs, err := collect(ctx, r.db, scanJobRun,
+		`SELECT `+jobRunColumns+` FROM job_runs
+		 WHERE job = $1
+		 ORDER BY started_at DESC, id
+		 LIMIT $2`,
+		job, limit,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list job runs", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return runs, nil
+}
+
+// LastRuns returns the latest run of every job that has run at all.
+func (r *JobRepository) LastRuns(ctx context.Context) ([]models.JobRun, error) {
+	const op = "jobRepository.LastRuns"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	runs, err := collect(ctx, r.db, scanJobRun,
+		`SELECT DISTINCT ON (job) `+jobRunColumns+` FROM job_runs
+		 ORDER BY job, started_at DESC`,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list last job runs", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return runs, nil
+}
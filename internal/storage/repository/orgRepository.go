@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const orgColumns = `id, name, slug, created_at, updated_at`
+
+const membershipSelect = `SELECT m.org_id, m.user_id, u.email, m.role, m.created_at, m.updated_at
+	FROM org_memberships m JOIN users u ON u.id = m.user_id`
+
+const invitationColumns = `id, org_id, email, role, token_hash, invited_by, created_at, expires_at, accepted_at, accepted_by`
+
+type OrgRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewOrgRepository(log *slog.Logger, db *pgxpool.Pool) *OrgRepository {
+	return &OrgRepository{log: log, db: db}
+}
+
+func scanOrg(row pgx.Row) (models.Organization, error) {
+	var o models.Organization
+	err := row.Scan(&o.ID, &o.Name, &o.Slug, &o.CreatedAt, &o.UpdatedAt)
+	return o, err
+}
+
+func scanMembership(row pgx.Row) (models.OrgMembership, error) {
+	var m models.OrgMembership
+	err := row.Scan(&m.OrgID, &m.UserID, &m.Email, &m.Role, &m.CreatedAt, &m.UpdatedAt)
+	return m, err
+}
+
+func scanInvitation(row pgx.Row) (models.OrgInvitation, error) {
+	var i models.OrgInvitation
+	err := row.Scan(&i.ID, &i.OrgID, &i.Email, &i.Role, &i.TokenHash, &i.InvitedBy,
+		&i.CreatedAt, &i.ExpiresAt, &i.AcceptedAt, &i.AcceptedBy)
+	return i, err
+}
+
+func (r *OrgRepository) CreateOrgTx(ctx context.Context, tx pgx.Tx, org models.Organization) (models.Organization, error) {
+	const op = "orgRepository.CreateOrgTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	created, err := scanOrg(tx.QueryRow(ctx,
+		"INSERT INTO organizations (name, slug) VALUES ($1, $2) RETURNING "+orgColumns,
+		org.Name, org.Slug,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Organization{}, errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		r.log.ErrorContext(ctx, "failed to create organization", slog.String("op", op), sl.Err(err))
+		return models.Organization{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return created, nil
+}
+
+func (r *OrgRepository) GetOrg(ctx context.Context, id uuid.UUID) (models.Organization, error) {
+	const op = "orgRepository.GetOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	org, err := scanOrg(r.db.QueryRow(ctx, "SELECT "+orgColumns+" FROM organizations WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Organization{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get organization", slog.String("op", op), sl.Err(err))
+		return models.Organization{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return org, nil
+}
+
+func (r *OrgRepository) ListOrgs(ctx context.Context) ([]models.Organization, error) {
+	const op = "orgRepository.ListOrgs"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	orgs, err := collect(ctx, r.db, scanOrg, "SELECT "+orgColumns+" FROM organizations ORDER BY slug")
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list organizations", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return orgs, nil
+}
+
+func (r *OrgRepository) UpdateOrg(ctx context.Context, org models.Organization) (models.Organization, error) {
+	const op = "orgRepository.UpdateOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	updated, err := scanOrg(r.db.QueryRow(ctx,
+		"UPDATE organizations SET name = $2, slug = $3, updated_at = now() WHERE id = $1 RETURNING "+orgColumns,
+		org.ID, org.Name, org.Slug,
+	))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Organization{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		if isUniqueViolation(err) {
+			return models.Organization{}, errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		r.log.ErrorContext(ctx, "failed to update organization", slog.String("op", op), sl.Err(err))
+		return models.Organization{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return updated, nil
+}
+
+// DeleteOrg removes the organization with its memberships and invitations.
+// It fails with Conflict while apps still belong to it.
+func (r *OrgRepository) DeleteOrg(ctx context.Context, id uuid.UUID) error {
+	const op = "orgRepository.DeleteOrg"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM organizations WHERE id = $1", id)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errs.WithKind(op, errs.Conflict, errors.New("organization still owns apps"))
+		}
+		r.log.ErrorContext(ctx, "failed to delete organization", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *OrgRepository) ListMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrgMembership, error) {
+	const op = "orgRepository.ListMembers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	members, err := collect(ctx, r.db, scanMembership, membershipSelect+" WHERE m.org_id = $1 ORDER BY u.email", orgID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list organization members", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return members, nil
+}
+
+func (r *OrgRepository) GetMembership(ctx context.Context, orgID, userID uuid.UUID) (models.OrgMembership, error) {
+	return r.getMembership(ctx, "orgRepository.GetMembership", r.db, orgID, userID)
+}
+
+func (r *OrgRepository) GetMembershipTx(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID) (models.OrgMembership, error) {
+	return r.getMembership(ctx, "orgRepository.GetMembershipTx", tx, orgID, userID)
+}
+
+func (r *OrgRepository) getMembership(ctx context.Context, op string, q interface {
+	QueryRow(context.Context, string, ...any) pgx.Row
+}, orgID, userID uuid.UUID) (models.OrgMembership, error) {
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	m, err := scanMembership(q.QueryRow(ctx, membershipSelect+" WHERE m.org_id = $1 AND m.user_id = $2", orgID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.OrgMembership{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get organization membership", slog.String("op", op), sl.Err(err))
+		return models.OrgMembership{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return m, nil
+}
+
+// ListUserOrgs returns the organizations the user belongs to with the user's
+// role in each.
+func (r *OrgRepository) ListUserOrgs(ctx context.Context, userID uuid.UUID) ([]models.UserOrganization, error) {
+	const op = "orgRepository.ListUserOrgs"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	orgs, err := collect(ctx, r.db, func(row pgx.Row) (models.UserOrganization, error) {
+		var o models.UserOrganization
+		err := row.Scan(&o.ID, &o.Name, &o.Slug, &o.CreatedAt, &o.UpdatedAt, &o.Role)
+		return o, err
+	},
+		`SELECT o.id, o.name, o.slug, o.created_at, o.updated_at, m.role
+		 FROM organizations o JOIN org_memberships m ON m.org_id = o.id
+		 WHERE m.user_id = $1 ORDER BY o.slug`,
+		userID,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list user organizations", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return orgs, nil
+}
+
+// PutMembershipTx adds the user to the organization or changes their role.
+func (r *OrgRepository) PutMembershipTx(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID, role string) error {
+	const op = "orgRepository.PutMembershipTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO org_memberships (org_id, user_id, role) VALUES ($1, $2, $3)
+		 ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role, updated_at = now()`,
+		orgID, userID, role,
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to put organization membership", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (r *OrgRepository) DeleteMembershipTx(ctx context.Context, tx pgx.Tx, orgID, userID uuid.UUID) error {
+	const op = "orgRepository.DeleteMembershipTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx, "DELETE FROM org_memberships WHERE org_id = $1 AND user_id = $2", orgID, userID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete organization membership", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *OrgRepository) CountOwnersTx(ctx context.Context, tx pgx.Tx, orgID uuid.UUID) (int, error) {
+	const op = "orgRepository.CountOwnersTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var n int
+	err := tx.QueryRow(ctx,
+		"SELECT count(*) FROM org_memberships WHERE org_id = $1 AND role = $2", orgID, models.OrgRoleOwner,
+	).Scan(&n)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to count organization owners", slog.String("op", op), sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return n, nil
+}
+
+// ReplaceInvitationTx drops the pending invitations of the same address to
+// the organization and stores inv in their place, so only the latest token
+// works.
+func (r *OrgRepository) ReplaceInvitationTx(ctx context.Context, tx pgx.Tx, inv models.OrgInvitation) (models.OrgInvitation, error) {
+	const op = "orgRepository.ReplaceInvitationTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("orgID", inv.OrgID.String()))
+
+	_, err := tx.Exec(ctx,
+		"DELETE FROM org_invitations WHERE org_id = $1 AND lower(email) = lower($2) AND accepted_at IS NULL",
+		inv.OrgID, inv.Email,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to drop pending invitations", sl.Err(err))
+		return models.OrgInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	created, err := scanInvitation(tx.QueryRow(ctx,
+		`INSERT INTO org_invitations (org_id, email, role, token_hash, invited_by, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+invitationColumns,
+		inv.OrgID, inv.Email, inv.Role, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt,
+	))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return models.OrgInvitation{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		log.ErrorContext(ctx, "failed to create invitation", sl.Err(err))
+		return models.OrgInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return created, nil
+}
+
+// ListPendingInvitations returns the organization's invitations that were
+// not accepted yet, expired ones included.
+func (r *OrgRepository) ListPendingInvitations(ctx context.Context, orgID uuid.UUID) ([]models.OrgInvitation, error) {
+	const op = "orgRepository.ListPendingInvitations"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	invitations, err := collect(ctx, r.db, scanInvitation,
+		"SELECT "+invitationColumns+" FROM org_invitations WHERE org_id = $1 AND accepted_at IS NULL ORDER BY created_at",
+		orgID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list invitations", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return invitations, nil
+}
+
+func (r *OrgRepository) GetInvitationTx(ctx context.Context, tx pgx.Tx, orgID, id uuid.UUID) (models.OrgInvitation, error) {
+	const op = "orgRepository.GetInvitationTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	inv, err := scanInvitation(tx.QueryRow(ctx,
+		"SELECT "+invitationColumns+" FROM org_invitations WHERE org_id = $1 AND id = $2", orgID, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.OrgInvitation{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get invitation", slog.String("op", op), sl.Err(err))
+		return models.OrgInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return inv, nil
+}
+
+func (r *OrgRepository) GetInvitationByTokenHashTx(ctx context.Context, tx pgx.Tx, tokenHash []byte) (models.OrgInvitation, error) {
+	const op = "orgRepository.GetInvitationByTokenHashTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	inv, err := scanInvitation(tx.QueryRow(ctx,
+		"SELECT "+invitationColumns+" FROM org_invitations WHERE token_hash = $1", tokenHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.OrgInvitation{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get invitation", slog.String("op", op), sl.Err(err))
+		return models.OrgInvitation{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return inv, nil
+}
+
+func (r *OrgRepository) MarkInvitationAcceptedTx(ctx context.Context, tx pgx.Tx, id, userID uuid.UUID, at time.Time) error {
+	const op = "orgRepository.MarkInvitationAcceptedTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE org_invitations SET accepted_at = $3, accepted_by = $2 WHERE id = $1 AND accepted_at IS NULL",
+		id, userID, at)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to accept invitation", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *OrgRepository) DeleteInvitationTx(ctx context.Context, tx pgx.Tx, orgID, id uuid.UUID) error {
+	const op = "orgRepository.DeleteInvitationTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx,
+		"DELETE FROM org_invitations WHERE org_id = $1 AND id = $2 AND accepted_at IS NULL", orgID, id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete invitation", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
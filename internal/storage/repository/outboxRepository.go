@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewOutboxRepository(log *slog.Logger, db *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{log: log, db: db}
+}
+
+// AddTx records e as part of tx, so the event exists exactly when the change
+// it describes was committed.
+func (r *OutboxRepository) AddTx(ctx context.Context, tx pgx.Tx, e models.OutboxEvent) error {
+	const op = "outboxRepository.AddTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	data := e.Data
+	if data == nil {
+		data = map[string]any{}
+	}
+
+	_, err := tx.Exec(ctx,
+		"INSERT INTO outbox_events (event_type, subject, app_id, user_id, data) VALUES ($1, $2, $3, $4, $5)",
+		e.Type, e.Subject, e.AppID, e.UserID, data,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to add outbox event", slog.String("op", op), slog.String("eventType", e.Type), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+// FanOut turns up to limit undispatched events into one pending delivery per
+// subscribed endpoint and marks the events dispatched. Events with an app go
+// to that app's endpoints only, the rest to the endpoints of the apps their
+// user has signed in to or belongs to through an organization. It returns how
+// many events and deliveries were handled.
+func (r *OutboxRepository) FanOut(ctx context.Context, limit int) (int, int64, error) {
+	const op = "outboxRepository.FanOut"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op))
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to begin transaction", sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT id FROM outbox_events
+		 WHERE dispatched_at IS NULL
+		 ORDER BY occurred_at
+		 LIMIT $1
+		 FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to select outbox events", sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		log.ErrorContext(ctx, "failed to scan outbox events", sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO webhook_deliveries (event_id, endpoint_id)
+		 SELECT e.id, w.id
+		 FROM outbox_events e
+		 JOIN webhook_endpoints w
+		   ON NOT w.is_disabled
+		  AND (w.app_id = e.app_id
+		       OR e.app_id IS NULL AND (
+		          EXISTS (SELECT 1 FROM user_apps ua WHERE ua.user_id = e.user_id AND ua.app_id = w.app_id)
+		          OR EXISTS (SELECT 1 FROM apps a JOIN org_memberships m ON m.org_id = a.org_id
+		                     WHERE a.id = w.app_id AND m.user_id = e.user_id)))
+		  AND (cardinality(w.event_types) = 0 OR e.event_type = ANY(w.event_types))
+		 WHERE e.id = ANY($1)
+		 ON CONFLICT (event_id, endpoint_id) DO NOTHING`, ids)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to create webhook deliveries", sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE outbox_events SET dispatched_at = now() WHERE id = ANY($1)", ids); err != nil {
+		log.ErrorContext(ctx, "failed to mark outbox events dispatched", sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.ErrorContext(ctx, "failed to commit fan-out", sl.Err(err))
+		return 0, 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return len(ids), tag.RowsAffected(), nil
+}
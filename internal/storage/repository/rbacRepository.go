@@ -0,0 +1,539 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const permissionColumns = `id, app_id, name, description, created_at`
+
+// roleSelect reads roles with the names of their permissions. Callers add a
+// WHERE clause and must end with roleGroupBy.
+const roleSelect = `SELECT r.id, r.app_id, r.name, r.description,
+	COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}'),
+	r.created_at, r.updated_at
+	FROM app_roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	LEFT JOIN app_permissions p ON p.id = rp.permission_id`
+
+const roleGroupBy = ` GROUP BY r.id`
+
+const groupColumns = `id, name, description, created_at`
+
+type RBACRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewRBACRepository(log *slog.Logger, db *pgxpool.Pool) *RBACRepository {
+	return &RBACRepository{log: log, db: db}
+}
+
+func scanPermission(row pgx.Row) (models.Permission, error) {
+	var p models.Permission
+	err := row.Scan(&p.ID, &p.AppID, &p.Name, &p.Description, &p.CreatedAt)
+	return p, err
+}
+
+func scanRole(row pgx.Row) (models.Role, error) {
+	var r models.Role
+	err := row.Scan(&r.ID, &r.AppID, &r.Name, &r.Description, &r.Permissions, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+func scanGroup(row pgx.Row) (models.Group, error) {
+	var g models.Group
+	err := row.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt)
+	return g, err
+}
+
+// collect runs query and scans every row with scan.
+func collect[T any](ctx context.Context, q interface {
+	Query(context.Context, string, ...any) (pgx.Rows, error)
+}, scan func(pgx.Row) (T, error), query string, args ...any) ([]T, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []T
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (r *RBACRepository) CreatePermission(ctx context.Context, p models.Permission) (models.Permission, error) {
+	const op = "rbacRepository.CreatePermission"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	created, err := scanPermission(r.db.QueryRow(ctx,
+		`INSERT INTO app_permissions (app_id, name, description) VALUES ($1, $2, $3)
+		 RETURNING `+permissionColumns,
+		p.AppID, p.Name, p.Description,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Permission{}, errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		if isForeignKeyViolation(err) {
+			return models.Permission{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to create permission", slog.String("op", op), sl.Err(err))
+		return models.Permission{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return created, nil
+}
+
+func (r *RBACRepository) ListPermissions(ctx context.Context, appID int) ([]models.Permission, error) {
+	const op = "rbacRepository.ListPermissions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	permissions, err := collect(ctx, r.db, scanPermission,
+		"SELECT "+permissionColumns+" FROM app_permissions WHERE app_id = $1 ORDER BY name", appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list permissions", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return permissions, nil
+}
+
+// DeletePermission removes the permission from the app and from every role
+// that granted it.
+func (r *RBACRepository) DeletePermission(ctx context.Context, appID int, id uuid.UUID) error {
+	const op = "rbacRepository.DeletePermission"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM app_permissions WHERE id = $1 AND app_id = $2", id, appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete permission", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) CreateRoleTx(ctx context.Context, tx pgx.Tx, role models.Role) (uuid.UUID, error) {
+	const op = "rbacRepository.CreateRoleTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var id uuid.UUID
+	err := tx.QueryRow(ctx,
+		"INSERT INTO app_roles (app_id, name, description) VALUES ($1, $2, $3) RETURNING id",
+		role.AppID, role.Name, role.Description,
+	).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return uuid.UUID{}, errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		if isForeignKeyViolation(err) {
+			return uuid.UUID{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to create role", slog.String("op", op), sl.Err(err))
+		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return id, nil
+}
+
+func (r *RBACRepository) UpdateRoleTx(ctx context.Context, tx pgx.Tx, role models.Role) error {
+	const op = "rbacRepository.UpdateRoleTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE app_roles SET name = $3, description = $4, updated_at = now() WHERE id = $1 AND app_id = $2",
+		role.ID, role.AppID, role.Name, role.Description,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		r.log.ErrorContext(ctx, "failed to update role", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// SetRolePermissionsTx replaces the role's permissions with the app's
+// permissions named in names. It returns how many of the names matched.
+func (r *RBACRepository) SetRolePermissionsTx(ctx context.Context, tx pgx.Tx, appID int, roleID uuid.UUID, names []string) (int64, error) {
+	const op = "rbacRepository.SetRolePermissionsTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("roleID", roleID.String()))
+
+	if _, err := tx.Exec(ctx, "DELETE FROM role_permissions WHERE role_id = $1", roleID); err != nil {
+		log.ErrorContext(ctx, "failed to clear role permissions", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO role_permissions (role_id, permission_id)
+		 SELECT $1, id FROM app_permissions WHERE app_id = $2 AND name = ANY($3)`,
+		roleID, appID, names,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to set role permissions", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r *RBACRepository) GetRole(ctx context.Context, appID int, id uuid.UUID) (models.Role, error) {
+	const op = "rbacRepository.GetRole"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	role, err := scanRole(r.db.QueryRow(ctx, roleSelect+" WHERE r.id = $1 AND r.app_id = $2"+roleGroupBy, id, appID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Role{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get role", slog.String("op", op), sl.Err(err))
+		return models.Role{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return role, nil
+}
+
+func (r *RBACRepository) ListRoles(ctx context.Context, appID int) ([]models.Role, error) {
+	const op = "rbacRepository.ListRoles"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	roles, err := collect(ctx, r.db, scanRole, roleSelect+" WHERE r.app_id = $1"+roleGroupBy+" ORDER BY r.name", appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list roles", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return roles, nil
+}
+
+// DeleteRole removes the role and every grant of it.
+func (r *RBACRepository) DeleteRole(ctx context.Context, appID int, id uuid.UUID) error {
+	const op = "rbacRepository.DeleteRole"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM app_roles WHERE id = $1 AND app_id = $2", id, appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete role", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// AssignUserRole grants the role to the user directly. Granting it twice is
+// not an error.
+func (r *RBACRepository) AssignUserRole(ctx context.Context, userID, roleID uuid.UUID) error {
+	const op = "rbacRepository.AssignUserRole"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, roleID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to assign role", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) UnassignUserRole(ctx context.Context, userID, roleID uuid.UUID) error {
+	const op = "rbacRepository.UnassignUserRole"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role_id = $2", userID, roleID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to unassign role", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ListUserRoles returns the roles granted to the user directly, across apps.
+func (r *RBACRepository) ListUserRoles(ctx context.Context, userID uuid.UUID) ([]models.Role, error) {
+	const op = "rbacRepository.ListUserRoles"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	roles, err := collect(ctx, r.db, scanRole,
+		roleSelect+" WHERE r.id IN (SELECT role_id FROM user_roles WHERE user_id = $1)"+roleGroupBy+" ORDER BY r.app_id, r.name",
+		userID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list user roles", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return roles, nil
+}
+
+func (r *RBACRepository) CreateGroup(ctx context.Context, g models.Group) (models.Group, error) {
+	const op = "rbacRepository.CreateGroup"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	created, err := scanGroup(r.db.QueryRow(ctx,
+		"INSERT INTO user_groups (name, description) VALUES ($1, $2) RETURNING "+groupColumns,
+		g.Name, g.Description,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Group{}, errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		r.log.ErrorContext(ctx, "failed to create group", slog.String("op", op), sl.Err(err))
+		return models.Group{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return created, nil
+}
+
+func (r *RBACRepository) GetGroup(ctx context.Context, id uuid.UUID) (models.Group, error) {
+	const op = "rbacRepository.GetGroup"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	g, err := scanGroup(r.db.QueryRow(ctx, "SELECT "+groupColumns+" FROM user_groups WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Group{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get group", slog.String("op", op), sl.Err(err))
+		return models.Group{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return g, nil
+}
+
+func (r *RBACRepository) ListGroups(ctx context.Context) ([]models.Group, error) {
+	const op = "rbacRepository.ListGroups"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	groups, err := collect(ctx, r.db, scanGroup, "SELECT "+groupColumns+" FROM user_groups ORDER BY name")
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list groups", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return groups, nil
+}
+
+func (r *RBACRepository) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	const op = "rbacRepository.DeleteGroup"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM user_groups WHERE id = $1", id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete group", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) AddGroupMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	const op = "rbacRepository.AddGroupMember"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO group_members (group_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", groupID, userID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to add group member", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) RemoveGroupMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	const op = "rbacRepository.RemoveGroupMember"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM group_members WHERE group_id = $1 AND user_id = $2", groupID, userID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to remove group member", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) ListGroupMembers(ctx context.Context, groupID uuid.UUID) ([]models.User, error) {
+	const op = "rbacRepository.ListGroupMembers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	users, err := collect(ctx, r.db, scanUser,
+		"SELECT "+userColumns+" FROM users WHERE id IN (SELECT user_id FROM group_members WHERE group_id = $1) ORDER BY email",
+		groupID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list group members", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return users, nil
+}
+
+func (r *RBACRepository) AssignGroupRole(ctx context.Context, groupID, roleID uuid.UUID) error {
+	const op = "rbacRepository.AssignGroupRole"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := r.db.Exec(ctx,
+		"INSERT INTO group_roles (group_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", groupID, roleID)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to assign group role", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) UnassignGroupRole(ctx context.Context, groupID, roleID uuid.UUID) error {
+	const op = "rbacRepository.UnassignGroupRole"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM group_roles WHERE group_id = $1 AND role_id = $2", groupID, roleID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to unassign group role", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (r *RBACRepository) ListGroupRoles(ctx context.Context, groupID uuid.UUID) ([]models.Role, error) {
+	const op = "rbacRepository.ListGroupRoles"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	roles, err := collect(ctx, r.db, scanRole,
+		roleSelect+" WHERE r.id IN (SELECT role_id FROM group_roles WHERE group_id = $1)"+roleGroupBy+" ORDER BY r.app_id, r.name",
+		groupID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list group roles", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return roles, nil
+}
+
+// GetAuthorization returns the names of the app's roles the user holds,
+// directly or through a group, and of the permissions those roles grant.
+func (r *RBACRepository) GetAuthorization(ctx context.Context, userID uuid.UUID, appID int) (models.Authorization, error) {
+	const op = "rbacRepository.GetAuthorization"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	authz := models.Authorization{Roles: []string{}, Permissions: []string{}}
+	err := r.db.QueryRow(ctx,
+		`WITH granted AS (
+			SELECT role_id FROM user_roles WHERE user_id = $1
+			UNION
+			SELECT gr.role_id FROM group_roles gr
+			JOIN group_members gm ON gm.group_id = gr.group_id
+			WHERE gm.user_id = $1
+		)
+		SELECT
+			COALESCE((SELECT array_agg(r.name ORDER BY r.name)
+				FROM app_roles r JOIN granted g ON g.role_id = r.id
+				WHERE r.app_id = $2), '{}'),
+			COALESCE((SELECT array_agg(DISTINCT p.name ORDER BY p.name)
+				FROM app_permissions p
+				JOIN role_permissions rp ON rp.permission_id = p.id
+				JOIN granted g ON g.role_id = rp.role_id
+				WHERE p.app_id = $2), '{}')`,
+		userID, appID,
+	).Scan(&authz.Roles, &authz.Permissions)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to read authorization", slog.String("op", op), sl.Err(err))
+		return models.Authorization{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return authz, nil
+}
+
+// AssignAppRolesTx grants the user those of roleIDs that still exist in the
+// app and returns how many were granted.
+func (r *RBACRepository) AssignAppRolesTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID, appID int, roleIDs []uuid.UUID) (int64, error) {
+	const op = "rbacRepository.AssignAppRolesTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO user_roles (user_id, role_id)
+		 SELECT $1, id FROM app_roles WHERE app_id = $2 AND id = ANY($3)
+		 ON CONFLICT DO NOTHING`,
+		userID, appID, roleIDs,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to assign roles", slog.String("op", op), sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -2,65 +2,70 @@ package repository
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"log/slog"
 	"time"
 
 	"github.com/finaptica/sso/internal/domain/models"
 	"github.com/finaptica/sso/internal/lib/errs"
 	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const refreshTokenColumns = `id, user_id, app_id, session_id, value, is_revoked, created_at, expires_at, replaced_by, rotated_at`
+
 type RefreshTokenRepository struct {
-	db  *pgx.Conn
+	db  *pgxpool.Pool
 	log *slog.Logger
 }
 
-func NewRefreshTokenRepository(log *slog.Logger, db *pgx.Conn) *RefreshTokenRepository {
+func NewRefreshTokenRepository(log *slog.Logger, db *pgxpool.Pool) *RefreshTokenRepository {
 	return &RefreshTokenRepository{log: log, db: db}
 }
 
-func (r *RefreshTokenRepository) SaveNewRefreshToken(ctx context.Context, userId uuid.UUID, appId int, value string, expiresAt time.Time) (uuid.UUID, error) {
-	const op = "refreshTokenRepository.SaveNewRefreshToken"
-	log := r.log.With(slog.String("op", op), slog.String("refreshTokenValue", value))
-	var id uuid.UUID
-	err := r.db.QueryRow(ctx, "INSERT INTO refresh_tokens (user_id, app_id, value, created_at, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id", userId, appId, value, time.Now().UTC(), expiresAt).Scan(&id)
-	if err != nil {
-		log.Error("failed to create refresh token", sl.Err(err))
-		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
-	}
-
-	return id, nil
-}
-
-func (r *RefreshTokenRepository) SaveNewRefreshTokenTx(ctx context.Context, tx pgx.Tx, userId uuid.UUID, appId int, value string, expiresAt time.Time) (uuid.UUID, error) {
+// SaveNewRefreshTokenTx stores a refresh token issued under its session.
+func (r *RefreshTokenRepository) SaveNewRefreshTokenTx(ctx context.Context, tx pgx.Tx, token models.RefreshToken) (uuid.UUID, error) {
 	const op = "refreshTokenRepository.SaveNewRefreshTokenTx"
-	log := r.log.With(slog.String("op", op), slog.String("refreshTokenValue", value))
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("refreshTokenValue", token.Value))
 
 	var id uuid.UUID
 	err := tx.QueryRow(
 		ctx,
-		`INSERT INTO refresh_tokens (user_id, app_id, value, created_at, expires_at) 
-		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
-		userId, appId, value, time.Now().UTC(), expiresAt,
+		`INSERT INTO refresh_tokens (user_id, app_id, session_id, value, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		token.UserID, token.AppID, token.SessionID, token.Value, token.CreatedAt, token.ExpiresAt,
 	).Scan(&id)
 
 	if err != nil {
-		log.Error("failed to create refresh token", sl.Err(err))
+		log.ErrorContext(ctx, "failed to create refresh token", sl.Err(err))
 		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
 	}
 
 	return id, nil
 }
 
+func scanRefreshToken(row pgx.Row) (models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := row.Scan(&t.ID, &t.UserID, &t.AppID, &t.SessionID, &t.Value, &t.IsRevoked,
+		&t.CreatedAt, &t.ExpiresAt, &t.ReplacedBy, &t.RotatedAt)
+	return t, err
+}
+
 func (r *RefreshTokenRepository) Revoke(ctx context.Context, tokenId uuid.UUID) error {
 	const op = "refreshTokenRepository.Revoke"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	log := r.log.With(slog.String("op", op))
 	_, err := r.db.Exec(ctx, "UPDATE refresh_tokens SET is_revoked = true WHERE id = $1", tokenId)
 	if err != nil {
-		log.Error("failed to revoke token", sl.Err(err))
+		log.ErrorContext(ctx, "failed to revoke token", sl.Err(err))
 		return errs.WithKind(op, errs.Internal, err)
 	}
 
@@ -69,27 +74,124 @@ func (r *RefreshTokenRepository) Revoke(ctx context.Context, tokenId uuid.UUID)
 
 func (r *RefreshTokenRepository) GetByValue(ctx context.Context, tokenValue string) (*models.RefreshToken, error) {
 	const op = "refreshTokenRepository.GetByValue"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	log := r.log.With(slog.String("op", op))
-	var token models.RefreshToken
-	err := r.db.QueryRow(ctx, "SELECT id, user_id, app_id, value, is_revoked, created_at, expires_at FROM refresh_tokens WHERE value = $1", tokenValue).Scan(&token.ID, &token.UserID, &token.AppID, &token.Value, &token.IsRevoked, &token.CreatedAt, &token.ExpiresAt)
+	token, err := scanRefreshToken(r.db.QueryRow(ctx, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE value = $1", tokenValue))
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, pgx.ErrNoRows) {
 			return &models.RefreshToken{}, errs.WithKind(op, errs.NotFound, err)
 		}
-		log.Error("failed to get token by value", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get token by value", sl.Err(err))
 		return &models.RefreshToken{}, errs.WithKind(op, errs.Internal, err)
 	}
 
 	return &token, nil
 }
 
-func (r *RefreshTokenRepository) RevokeTx(ctx context.Context, tx pgx.Tx, tokenID uuid.UUID) error {
-	_, err := tx.Exec(ctx, "UPDATE refresh_tokens SET is_revoked = true WHERE id = $1", tokenID)
-	return err
+// RotateTx revokes a live token in favour of its successor. It fails with
+// Conflict when the token is no longer live.
+func (r *RefreshTokenRepository) RotateTx(ctx context.Context, tx pgx.Tx, tokenID, successorID uuid.UUID, at time.Time) error {
+	const op = "refreshTokenRepository.RotateTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE refresh_tokens SET is_revoked = true, replaced_by = $2, rotated_at = $3 WHERE id = $1 AND NOT is_revoked",
+		tokenID, successorID, at,
+	)
+	if err != nil {
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.Conflict, errors.New("refresh token is no longer live"))
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepository) GetByIDTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (models.RefreshToken, error) {
+	const op = "refreshTokenRepository.GetByIDTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	token, err := scanRefreshToken(tx.QueryRow(ctx, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get token by id", slog.String("op", op), sl.Err(err))
+		return models.RefreshToken{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return token, nil
 }
 
 func (r *RefreshTokenRepository) GetByValueTx(ctx context.Context, tx pgx.Tx, tokenValue string) (models.RefreshToken, error) {
-	var token models.RefreshToken
-	err := tx.QueryRow(ctx, "SELECT id, user_id, app_id, value, is_revoked, created_at, expires_at  FROM refresh_tokens WHERE value = $1", tokenValue).Scan(&token.ID, &token.UserID, &token.AppID, &token.Value, &token.IsRevoked, &token.CreatedAt, &token.ExpiresAt)
-	return token, err
+	const op = "refreshTokenRepository.GetByValueTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	token, err := scanRefreshToken(tx.QueryRow(ctx, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE value = $1", tokenValue))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get token by value", slog.String("op", op), sl.Err(err))
+		return models.RefreshToken{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return token, nil
+}
+
+func (r *RefreshTokenRepository) DeleteByAppTx(ctx context.Context, tx pgx.Tx, appID int) (int64, error) {
+	const op = "refreshTokenRepository.DeleteByAppTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", appID))
+	tag, err := tx.Exec(ctx, "DELETE FROM refresh_tokens WHERE app_id = $1", appID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete app refresh tokens", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RevokeAllForUserTx revokes every live refresh token of the user, and with
+// them the user's sessions.
+func (r *RefreshTokenRepository) RevokeAllForUserTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error) {
+	const op = "refreshTokenRepository.RevokeAllForUserTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+	tag, err := tx.Exec(ctx, `
+		WITH s AS (
+			UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL
+		)
+		UPDATE refresh_tokens SET is_revoked = true WHERE user_id = $1 AND NOT is_revoked`, userID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to revoke user refresh tokens", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+func (r *RefreshTokenRepository) DeleteByUserTx(ctx context.Context, tx pgx.Tx, userID uuid.UUID) (int64, error) {
+	const op = "refreshTokenRepository.DeleteByUserTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+	tag, err := tx.Exec(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete user refresh tokens", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return tag.RowsAffected(), nil
 }
@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const relationNamespaceColumns = `app_id, name, config, created_at, updated_at`
+
+// visibleAt limits relation_tuples to those that exist at revision $2.
+const visibleAt = ` created_revision <= $2 AND (deleted_revision IS NULL OR deleted_revision > $2)`
+
+type RelationRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewRelationRepository(log *slog.Logger, db *pgxpool.Pool) *RelationRepository {
+	return &RelationRepository{log: log, db: db}
+}
+
+func scanRelationNamespace(row pgx.Row) (models.RelationNamespace, error) {
+	var ns models.RelationNamespace
+	err := row.Scan(&ns.AppID, &ns.Name, &ns.Config, &ns.CreatedAt, &ns.UpdatedAt)
+	return ns, err
+}
+
+func (r *RelationRepository) ListNamespaces(ctx context.Context, appID int) ([]models.RelationNamespace, error) {
+	const op = "relationRepository.ListNamespaces"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	namespaces, err := collect(ctx, r.db, scanRelationNamespace,
+		"SELECT "+relationNamespaceColumns+" FROM relation_namespaces WHERE app_id = $1 ORDER BY name", appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list relation namespaces", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return namespaces, nil
+}
+
+func (r *RelationRepository) GetNamespaceTx(ctx context.Context, tx pgx.Tx, appID int, name string) (models.RelationNamespace, error) {
+	const op = "relationRepository.GetNamespaceTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	ns, err := scanRelationNamespace(tx.QueryRow(ctx,
+		"SELECT "+relationNamespaceColumns+" FROM relation_namespaces WHERE app_id = $1 AND name = $2", appID, name))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RelationNamespace{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get relation namespace", slog.String("op", op), sl.Err(err))
+		return models.RelationNamespace{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return ns, nil
+}
+
+// PutNamespaceTx creates the namespace or replaces its config.
+func (r *RelationRepository) PutNamespaceTx(ctx context.Context, tx pgx.Tx, ns models.RelationNamespace) (models.RelationNamespace, error) {
+	const op = "relationRepository.PutNamespaceTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	saved, err := scanRelationNamespace(tx.QueryRow(ctx,
+		`INSERT INTO relation_namespaces (app_id, name, config)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (app_id, name) DO UPDATE SET config = EXCLUDED.config, updated_at = now()
+		 RETURNING `+relationNamespaceColumns,
+		ns.AppID, ns.Name, ns.Config,
+	))
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return models.RelationNamespace{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to put relation namespace", slog.String("op", op), sl.Err(err))
+		return models.RelationNamespace{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return saved, nil
+}
+
+func (r *RelationRepository) DeleteNamespaceTx(ctx context.Context, tx pgx.Tx, appID int, name string) error {
+	const op = "relationRepository.DeleteNamespaceTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx, "DELETE FROM relation_namespaces WHERE app_id = $1 AND name = $2", appID, name)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete relation namespace", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// CountLiveTuplesTx counts current tuples that use the given relations of
+// namespace, as object relation or as subject userset. Nil relations counts
+// every tuple touching the namespace.
+func (r *RelationRepository) CountLiveTuplesTx(ctx context.Context, tx pgx.Tx, appID int, namespace string, relations []string) (int64, error) {
+	const op = "relationRepository.CountLiveTuplesTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var n int64
+	err := tx.QueryRow(ctx,
+		`SELECT count(*) FROM relation_tuples
+		 WHERE app_id = $1 AND deleted_revision IS NULL
+		   AND ((namespace = $2 AND ($3::text[] IS NULL OR relation = ANY($3)))
+		     OR (subject_namespace = $2 AND ($3::text[] IS NULL OR subject_relation = ANY($3))))`,
+		appID, namespace, relations,
+	).Scan(&n)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to count relation tuples", slog.String("op", op), sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return n, nil
+}
+
+// WriteTuplesTx applies deletes and then writes as one new revision and
+// returns it. Writing a tuple that exists and deleting one that does not are
+// no-ops.
+func (r *RelationRepository) WriteTuplesTx(ctx context.Context, tx pgx.Tx, appID int, writes, deletes []models.RelationTuple) (int64, error) {
+	const op = "relationRepository.WriteTuplesTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := r.log.With(slog.String("op", op), slog.Int("appID", appID))
+
+	var revision int64
+	err := tx.QueryRow(ctx,
+		`INSERT INTO relation_revisions (app_id, revision) VALUES ($1, 1)
+		 ON CONFLICT (app_id) DO UPDATE SET revision = relation_revisions.revision + 1
+		 RETURNING revision`,
+		appID,
+	).Scan(&revision)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return 0, errs.WithKind(op, errs.NotFound, err)
+		}
+		log.ErrorContext(ctx, "failed to bump relation revision", sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	for _, t := range deletes {
+		_, err := tx.Exec(ctx,
+			`UPDATE relation_tuples SET deleted_revision = $2
+			 WHERE app_id = $1 AND namespace = $3 AND object_id = $4 AND relation = $5
+			   AND subject_namespace = $6 AND subject_object_id = $7 AND subject_relation = $8
+			   AND deleted_revision IS NULL`,
+			appID, revision, t.Object.Namespace, t.Object.ObjectID, t.Relation,
+			t.Subject.Namespace, t.Subject.ObjectID, t.Subject.Relation,
+		)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to delete relation tuple", sl.Err(err))
+			return 0, errs.WithKind(op, errs.Internal, err)
+		}
+	}
+
+	for _, t := range writes {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO relation_tuples (app_id, created_revision, namespace, object_id, relation,
+				subject_namespace, subject_object_id, subject_relation)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			 ON CONFLICT (app_id, namespace, object_id, relation, subject_namespace, subject_object_id, subject_relation)
+				WHERE deleted_revision IS NULL DO NOTHING`,
+			appID, revision, t.Object.Namespace, t.Object.ObjectID, t.Relation,
+			t.Subject.Namespace, t.Subject.ObjectID, t.Subject.Relation,
+		)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to write relation tuple", sl.Err(err))
+			return 0, errs.WithKind(op, errs.Internal, err)
+		}
+	}
+
+	return revision, nil
+}
+
+// Revision returns the app's latest committed revision, 0 before the first
+// write.
+func (r *RelationRepository) Revision(ctx context.Context, appID int) (int64, error) {
+	const op = "relationRepository.Revision"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var revision int64
+	err := r.db.QueryRow(ctx, "SELECT revision FROM relation_revisions WHERE app_id = $1", appID).Scan(&revision)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		r.log.ErrorContext(ctx, "failed to read relation revision", slog.String("op", op), sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return revision, nil
+}
+
+// ReadSubjects returns the subjects stored for object#relation at revision.
+func (r *RelationRepository) ReadSubjects(ctx context.Context, appID int, revision int64, object models.RelationObject, relation string) ([]models.RelationSubject, error) {
+	const op = "relationRepository.ReadSubjects"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	subjects, err := collect(ctx, r.db, func(row pgx.Row) (models.RelationSubject, error) {
+		var s models.RelationSubject
+		err := row.Scan(&s.Namespace, &s.ObjectID, &s.Relation)
+		return s, err
+	},
+		`SELECT subject_namespace, subject_object_id, subject_relation FROM relation_tuples
+		 WHERE app_id = $1 AND namespace = $3 AND object_id = $4 AND relation = $5 AND`+visibleAt+`
+		 ORDER BY subject_namespace, subject_object_id, subject_relation`,
+		appID, revision, object.Namespace, object.ObjectID, relation,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to read relation tuples", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return subjects, nil
+}
+
+// ListObjectIDs returns the IDs of namespace objects that have any tuple at
+// revision.
+func (r *RelationRepository) ListObjectIDs(ctx context.Context, appID int, revision int64, namespace string) ([]string, error) {
+	const op = "relationRepository.ListObjectIDs"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	ids, err := collect(ctx, r.db, func(row pgx.Row) (string, error) {
+		var id string
+		err := row.Scan(&id)
+		return id, err
+	},
+		`SELECT DISTINCT object_id FROM relation_tuples
+		 WHERE app_id = $1 AND namespace = $3 AND`+visibleAt+`
+		 ORDER BY object_id`,
+		appID, revision, namespace,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list relation objects", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return ids, nil
+}
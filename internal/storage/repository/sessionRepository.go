@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const sessionSelect = `SELECT s.id, s.user_id, s.app_id, a.name, s.device_name, s.user_agent, s.ip,
+	s.created_at, s.last_used_at, s.expires_at, s.absolute_expires_at, s.offline, s.revoked_at
+	FROM sessions s JOIN apps a ON a.id = s.app_id`
+
+type SessionRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewSessionRepository(log *slog.Logger, db *pgxpool.Pool) *SessionRepository {
+	return &SessionRepository{log: log, db: db}
+}
+
+func scanSession(row pgx.Row) (models.Session, error) {
+	var s models.Session
+	err := row.Scan(&s.ID, &s.UserID, &s.AppID, &s.AppName, &s.DeviceName, &s.UserAgent, &s.IP,
+		&s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt, &s.AbsoluteExpiresAt, &s.Offline, &s.RevokedAt)
+	return s, err
+}
+
+func (r *SessionRepository) CreateTx(ctx context.Context, tx pgx.Tx, s models.Session) (uuid.UUID, error) {
+	const op = "sessionRepository.CreateTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var id uuid.UUID
+	err := tx.QueryRow(ctx,
+		`INSERT INTO sessions (user_id, app_id, device_name, user_agent, ip, expires_at, absolute_expires_at, offline)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		s.UserID, s.AppID, s.DeviceName, s.UserAgent, s.IP, s.ExpiresAt, s.AbsoluteExpiresAt, s.Offline,
+	).Scan(&id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to create session", slog.String("op", op), sl.Err(err))
+		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return id, nil
+}
+
+func (r *SessionRepository) GetTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (models.Session, error) {
+	const op = "sessionRepository.GetTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	s, err := scanSession(tx.QueryRow(ctx, sessionSelect+" WHERE s.id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Session{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get session", slog.String("op", op), sl.Err(err))
+		return models.Session{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return s, nil
+}
+
+// TouchTx records a use of the session from the given client and moves its
+// expiry to that of the refresh token just issued under it.
+func (r *SessionRepository) TouchTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, ip, userAgent string, expiresAt time.Time) error {
+	const op = "sessionRepository.TouchTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE sessions SET ip = $2, user_agent = $3, last_used_at = now(), expires_at = $4 WHERE id = $1",
+		id, ip, userAgent, expiresAt,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to touch session", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, errors.New("session not found"))
+	}
+
+	return nil
+}
+
+// ListActive returns the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func (r *SessionRepository) ListActive(ctx context.Context, userID uuid.UUID) ([]models.Session, error) {
+	const op = "sessionRepository.ListActive"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	sessions, err := collect(ctx, r.db, scanSession,
+		sessionSelect+" WHERE s.user_id = $1 AND s.revoked_at IS NULL AND s.expires_at > now() ORDER BY s.last_used_at DESC",
+		userID,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list sessions", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return sessions, nil
+}
+
+// Revoke ends one live session of the user along with its refresh tokens.
+func (r *SessionRepository) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	const op = "sessionRepository.Revoke"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var n int64
+	err := r.db.QueryRow(ctx, `
+		WITH s AS (
+			UPDATE sessions SET revoked_at = now()
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+			RETURNING id
+		), t AS (
+			UPDATE refresh_tokens SET is_revoked = true
+			WHERE session_id IN (SELECT id FROM s) AND NOT is_revoked
+		)
+		SELECT count(*) FROM s`, id, userID).Scan(&n)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to revoke session", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if n == 0 {
+		return errs.WithKind(op, errs.NotFound, errors.New("session not found"))
+	}
+
+	return nil
+}
+
+// RevokeTx ends the session, if still live, along with its refresh tokens.
+func (r *SessionRepository) RevokeTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	const op = "sessionRepository.RevokeTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := tx.Exec(ctx, `
+		WITH s AS (
+			UPDATE sessions SET revoked_at = now()
+			WHERE id = $1 AND revoked_at IS NULL
+			RETURNING id
+		)
+		UPDATE refresh_tokens SET is_revoked = true
+		WHERE session_id IN (SELECT id FROM s) AND NOT is_revoked`, id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to revoke session", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+// RevokeAll ends every live session of the user along with their refresh
+// tokens and returns how many sessions were ended.
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID uuid.UUID) (int64, error) {
+	const op = "sessionRepository.RevokeAll"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var n int64
+	err := r.db.QueryRow(ctx, `
+		WITH s AS (
+			UPDATE sessions SET revoked_at = now()
+			WHERE user_id = $1 AND revoked_at IS NULL
+			RETURNING id
+		), t AS (
+			UPDATE refresh_tokens SET is_revoked = true
+			WHERE user_id = $1 AND NOT is_revoked
+		)
+		SELECT count(*) FROM s`, userID).Scan(&n)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to revoke sessions", slog.String("op", op), sl.Err(err))
+		return 0, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return n, nil
+}
@@ -2,32 +2,47 @@ package repository
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
+	"strings"
 
 	"github.com/finaptica/sso/internal/domain/models"
 	"github.com/finaptica/sso/internal/lib/errs"
 	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
 	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const userColumns = `id, email, pass_hash, COALESCE(name, ''), COALESCE(surname, ''), COALESCE(avatar_key, ''),
+	status, password_reset_required, created_at, updated_at, deleted_at`
+
 type UserRepository struct {
-	db  *pgx.Conn
+	db  *pgxpool.Pool
 	log *slog.Logger
 }
 
-func NewUserRepository(log *slog.Logger, db *pgx.Conn) *UserRepository {
+func NewUserRepository(log *slog.Logger, db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{log: log, db: db}
 }
 
-func (u *UserRepository) CreateUser(ctx context.Context, email string, passHash []byte) (uuid.UUID, error) {
-	const op = "userRepository.CreateUser"
+func (u *UserRepository) CreateUserTx(ctx context.Context, tx pgx.Tx, email string, passHash []byte) (uuid.UUID, error) {
+	const op = "userRepository.CreateUserTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	log := u.log.With(slog.String("op", op), slog.String("email", email))
 	var id uuid.UUID
-	err := u.db.QueryRow(ctx, "INSERT INTO users (email, pass_hash) VALUES ($1, $2) RETURNING id", email, passHash).Scan(&id)
+	err := tx.QueryRow(ctx, "INSERT INTO users (email, pass_hash) VALUES ($1, $2) RETURNING id", email, passHash).Scan(&id)
 	if err != nil {
-		log.Error("failed to create user", sl.Err(err))
+		if isUniqueViolation(err) {
+			return uuid.UUID{}, errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		log.ErrorContext(ctx, "failed to create user", sl.Err(err))
 		return uuid.UUID{}, errs.WithKind(op, errs.Internal, err)
 	}
 
@@ -36,36 +51,56 @@ func (u *UserRepository) CreateUser(ctx context.Context, email string, passHash
 
 func (u *UserRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
 	const op = "userRepository.GetUserByEmail"
-	log := u.log.With(slog.String("op", op), slog.String("email", email))
-	var user models.User
-	err := u.db.QueryRow(ctx,
-		`SELECT id, email, pass_hash FROM users WHERE email = $1`, email,
-	).Scan(&user.ID, &user.Email, &user.PassHash)
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
 
+	log := u.log.With(slog.String("op", op), slog.String("email", email))
+	user, err := scanUser(u.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1)", email))
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			log.Info("user not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.InfoContext(ctx, "user not found")
 			return models.User{}, errs.WithKind(op, errs.NotFound, err)
 		}
 
-		log.Error("failed to get user by email", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user by email", sl.Err(err))
+		return models.User{}, errs.WithKind(op, errs.Internal, err)
+	}
+	return user, nil
+}
+
+func (u *UserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (models.User, error) {
+	const op = "userRepository.GetUserByID"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	user, err := scanUser(u.db.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		log.ErrorContext(ctx, "failed to get user by ID", sl.Err(err))
 		return models.User{}, errs.WithKind(op, errs.Internal, err)
 	}
+
 	return user, nil
 }
 
 func (u *UserRepository) GetUserByIDTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) (models.User, error) {
 	const op = "userRepository.GetUserByIDTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
 
-	var user models.User
-	err := tx.QueryRow(ctx, `SELECT id, email, pass_hash FROM users WHERE id = $1`, id).Scan(&user.ID, &user.Email, &user.PassHash)
+	user, err := scanUser(tx.QueryRow(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
 	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Info("user not found")
+		if errors.Is(err, pgx.ErrNoRows) {
+			log.InfoContext(ctx, "user not found")
 			return models.User{}, errs.WithKind(op, errs.NotFound, err)
 		}
-		log.Error("failed to get user by ID", sl.Err(err))
+		log.ErrorContext(ctx, "failed to get user by ID", sl.Err(err))
 		return models.User{}, errs.WithKind(op, errs.Internal, err)
 	}
 
@@ -74,13 +109,339 @@ func (u *UserRepository) GetUserByIDTx(ctx context.Context, tx pgx.Tx, id uuid.U
 
 func (u *UserRepository) IsUserExistByEmail(ctx context.Context, email string) (bool, error) {
 	const op = "userRepository.IsUserExistByEmail"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
 	log := u.log.With(slog.String("op", op), slog.String("email", email))
 	var isExist bool
-	err := u.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", email).Scan(&isExist)
+	err := u.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))", email).Scan(&isExist)
 	if err != nil {
-		log.Error("failed to check exist user or not", sl.Err(err))
+		log.ErrorContext(ctx, "failed to check exist user or not", sl.Err(err))
 		return false, errs.WithKind(op, errs.Internal, err)
 	}
 
 	return isExist, nil
 }
+
+// ListUsers returns up to limit users matching filter, newest first, starting
+// after cursor when it is set.
+func (u *UserRepository) ListUsers(ctx context.Context, filter models.UserFilter, cursor *models.UserCursor, limit int) ([]models.User, error) {
+	const op = "userRepository.ListUsers"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op))
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.EmailPrefix != "" {
+		where = append(where, "lower(email) LIKE lower("+arg(escapeLike(filter.EmailPrefix)+"%")+")")
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, "created_at >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, "created_at < "+arg(filter.CreatedTo))
+	}
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(filter.Status))
+	}
+	if filter.AppID != 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM user_apps ua WHERE ua.user_id = users.id AND ua.app_id = "+arg(filter.AppID)+")")
+	}
+	if cursor != nil {
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(cursor.CreatedAt), arg(cursor.ID)))
+	}
+
+	query := "SELECT " + userColumns + " FROM users"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(limit)
+
+	rows, err := u.db.Query(ctx, query, args...)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list users", sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to scan user", sl.Err(err))
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return users, nil
+}
+
+func (u *UserRepository) UpdateProfile(ctx context.Context, id uuid.UUID, name, surname string) error {
+	const op = "userRepository.UpdateProfile"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	tag, err := u.db.Exec(ctx,
+		"UPDATE users SET name = NULLIF($2, ''), surname = NULLIF($3, ''), updated_at = now() WHERE id = $1",
+		id, name, surname,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to update profile", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// UpdateEmailTx changes the user's login email. A clash with another user's
+// address is reported as AlreadyExists.
+func (u *UserRepository) UpdateEmailTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, email string) error {
+	const op = "userRepository.UpdateEmailTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	tag, err := tx.Exec(ctx, "UPDATE users SET email = $2, updated_at = now() WHERE id = $1", id, email)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errs.WithKind(op, errs.AlreadyExists, err)
+		}
+		log.ErrorContext(ctx, "failed to update email", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// SwapAvatarKeyTx records the blob key of the user's avatar and returns the
+// key it replaced, empty when there was none. An empty key clears it.
+func (u *UserRepository) SwapAvatarKeyTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, key string) (string, error) {
+	const op = "userRepository.SwapAvatarKeyTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	var previous *string
+	err := tx.QueryRow(ctx, "SELECT avatar_key FROM users WHERE id = $1 FOR UPDATE", id).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errs.WithKind(op, errs.NotFound, err)
+		}
+		log.ErrorContext(ctx, "failed to get avatar key", sl.Err(err))
+		return "", errs.WithKind(op, errs.Internal, err)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE users SET avatar_key = NULLIF($2, ''), updated_at = now() WHERE id = $1", id, key); err != nil {
+		log.ErrorContext(ctx, "failed to set avatar key", sl.Err(err))
+		return "", errs.WithKind(op, errs.Internal, err)
+	}
+
+	if previous == nil {
+		return "", nil
+	}
+	return *previous, nil
+}
+
+func (u *UserRepository) SetStatusTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, status string) error {
+	const op = "userRepository.SetStatusTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE users SET status = $2, updated_at = now(),
+		 deleted_at = CASE WHEN $2 = 'deleted' THEN now() END
+		 WHERE id = $1`,
+		id, status,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to set user status", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (u *UserRepository) AddStatusTransitionTx(ctx context.Context, tx pgx.Tx, t models.UserStatusTransition) error {
+	const op = "userRepository.AddStatusTransitionTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", t.UserID.String()))
+
+	_, err := tx.Exec(ctx,
+		`INSERT INTO user_status_transitions (user_id, from_status, to_status, actor, reason)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		t.UserID, t.FromStatus, t.ToStatus, t.Actor, t.Reason,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to record status transition", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (u *UserRepository) ListStatusTransitions(ctx context.Context, userID uuid.UUID) ([]models.UserStatusTransition, error) {
+	const op = "userRepository.ListStatusTransitions"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", userID.String()))
+
+	rows, err := u.db.Query(ctx,
+		`SELECT id, user_id, from_status, to_status, actor, reason, created_at
+		 FROM user_status_transitions WHERE user_id = $1 ORDER BY created_at`,
+		userID,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list status transitions", sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var transitions []models.UserStatusTransition
+	for rows.Next() {
+		var t models.UserStatusTransition
+		if err := rows.Scan(&t.ID, &t.UserID, &t.FromStatus, &t.ToStatus, &t.Actor, &t.Reason, &t.CreatedAt); err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		transitions = append(transitions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return transitions, nil
+}
+
+func (u *UserRepository) SetPasswordResetRequiredTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, required bool) error {
+	const op = "userRepository.SetPasswordResetRequiredTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	tag, err := tx.Exec(ctx, "UPDATE users SET password_reset_required = $2, updated_at = now() WHERE id = $1", id, required)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to set password reset flag", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// UpdatePasswordTx stores a new password hash and clears any pending forced
+// reset.
+func (u *UserRepository) UpdatePasswordTx(ctx context.Context, tx pgx.Tx, id uuid.UUID, passHash []byte) error {
+	const op = "userRepository.UpdatePasswordTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	tag, err := tx.Exec(ctx,
+		"UPDATE users SET pass_hash = $2, password_reset_required = false, updated_at = now() WHERE id = $1",
+		id, passHash,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to update password", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+func (u *UserRepository) DeleteUserTx(ctx context.Context, tx pgx.Tx, id uuid.UUID) error {
+	const op = "userRepository.DeleteUserTx"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", id.String()))
+
+	tag, err := tx.Exec(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete user", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// TouchAppMembership records that the user signed in to the app.
+func (u *UserRepository) TouchAppMembership(ctx context.Context, userID uuid.UUID, appID int) error {
+	const op = "userRepository.TouchAppMembership"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	log := u.log.With(slog.String("op", op), slog.String("userID", userID.String()), slog.Int("appID", appID))
+
+	_, err := u.db.Exec(ctx,
+		`INSERT INTO user_apps (user_id, app_id) VALUES ($1, $2)
+		 ON CONFLICT (user_id, app_id) DO UPDATE SET last_login_at = now()`,
+		userID, appID,
+	)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to record app membership", sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func scanUser(row pgx.Row) (models.User, error) {
+	var user models.User
+	err := row.Scan(
+		&user.ID, &user.Email, &user.PassHash, &user.Name, &user.Surname, &user.AvatarKey,
+		&user.Status, &user.PasswordResetRequired, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt,
+	)
+	return user, err
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgerrcode.ForeignKeyViolation
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
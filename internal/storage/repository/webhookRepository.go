@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/finaptica/sso/internal/domain/models"
+	"github.com/finaptica/sso/internal/lib/errs"
+	"github.com/finaptica/sso/internal/lib/logger/sl"
+	"github.com/finaptica/sso/internal/lib/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const webhookEndpointColumns = `id, app_id, url, secret, event_types, is_disabled, created_at`
+
+const webhookDeliveryColumns = `id, event_id, endpoint_id, status, attempts, next_attempt_at, last_attempt_at,
+	last_status_code, last_error, created_at, delivered_at`
+
+type WebhookRepository struct {
+	db  *pgxpool.Pool
+	log *slog.Logger
+}
+
+func NewWebhookRepository(log *slog.Logger, db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{log: log, db: db}
+}
+
+func scanWebhookEndpoint(row pgx.Row) (models.WebhookEndpoint, error) {
+	var e models.WebhookEndpoint
+	err := row.Scan(&e.ID, &e.AppID, &e.URL, &e.Secret, &e.EventTypes, &e.IsDisabled, &e.CreatedAt)
+	return e, err
+}
+
+func scanWebhookDelivery(row pgx.Row) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(
+		&d.ID, &d.EventID, &d.EndpointID, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+	)
+	return d, err
+}
+
+func (r *WebhookRepository) CreateEndpoint(ctx context.Context, e models.WebhookEndpoint) (models.WebhookEndpoint, error) {
+	const op = "webhookRepository.CreateEndpoint"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	created, err := scanWebhookEndpoint(r.db.QueryRow(ctx,
+		`INSERT INTO webhook_endpoints (app_id, url, secret, event_types)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+webhookEndpointColumns,
+		e.AppID, e.URL, e.Secret, e.EventTypes,
+	))
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to create webhook endpoint", slog.String("op", op), sl.Err(err))
+		return models.WebhookEndpoint{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return created, nil
+}
+
+func (r *WebhookRepository) ListEndpoints(ctx context.Context, appID int) ([]models.WebhookEndpoint, error) {
+	const op = "webhookRepository.ListEndpoints"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	rows, err := r.db.Query(ctx,
+		"SELECT "+webhookEndpointColumns+" FROM webhook_endpoints WHERE app_id = $1 ORDER BY created_at", appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list webhook endpoints", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var endpoints []models.WebhookEndpoint
+	for rows.Next() {
+		e, err := scanWebhookEndpoint(rows)
+		if err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		endpoints = append(endpoints, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return endpoints, nil
+}
+
+func (r *WebhookRepository) SetEndpointDisabled(ctx context.Context, appID int, id uuid.UUID, disabled bool) error {
+	const op = "webhookRepository.SetEndpointDisabled"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx,
+		"UPDATE webhook_endpoints SET is_disabled = $3 WHERE id = $1 AND app_id = $2", id, appID, disabled)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to update webhook endpoint", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// DeleteEndpoint removes the endpoint together with its deliveries.
+func (r *WebhookRepository) DeleteEndpoint(ctx context.Context, appID int, id uuid.UUID) error {
+	const op = "webhookRepository.DeleteEndpoint"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx, "DELETE FROM webhook_endpoints WHERE id = $1 AND app_id = $2", id, appID)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to delete webhook endpoint", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
+
+// ClaimDue leases up to limit pending deliveries that are due, pushing their
+// next attempt lease into the future so other dispatchers skip them while
+// they are being sent.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookJob, error) {
+	const op = "webhookRepository.ClaimDue"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	rows, err := r.db.Query(ctx,
+		`WITH due AS (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_endpoints w ON w.id = d.endpoint_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND NOT w.is_disabled
+			ORDER BY d.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM due, outbox_events e, webhook_endpoints w
+		WHERE d.id = due.id AND e.id = d.event_id AND w.id = d.endpoint_id
+		RETURNING d.id, d.event_id, d.endpoint_id, d.status, d.attempts, d.next_attempt_at, d.last_attempt_at,
+			d.last_status_code, d.last_error, d.created_at, d.delivered_at,
+			e.event_type, e.subject, e.app_id, e.data, e.occurred_at,
+			w.url, w.secret`,
+		limit, lease.Seconds(),
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to claim webhook deliveries", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var jobs []models.WebhookJob
+	for rows.Next() {
+		var j models.WebhookJob
+		d := &j.Delivery
+		err := rows.Scan(
+			&d.ID, &d.EventID, &d.EndpointID, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+			&j.Event.Type, &j.Event.Subject, &j.Event.AppID, &j.Event.Data, &j.Event.OccurredAt,
+			&j.Endpoint.URL, &j.Endpoint.Secret,
+		)
+		if err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		j.Event.ID = d.EventID
+		j.Endpoint.ID = d.EndpointID
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return jobs, nil
+}
+
+// RecordAttempt stores the outcome of an attempt made on d.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d models.WebhookDelivery) error {
+	const op = "webhookRepository.RecordAttempt"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	_, err := r.db.Exec(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = $5,
+			last_status_code = $6, last_error = $7, delivered_at = $8
+		 WHERE id = $1`,
+		d.ID, d.Status, d.Attempts, d.NextAttemptAt, d.LastAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt,
+	)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to record webhook attempt", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+
+	return nil
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, filter models.WebhookDeliveryFilter, cursor *models.WebhookDeliveryCursor, limit int) ([]models.WebhookDelivery, error) {
+	const op = "webhookRepository.ListDeliveries"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.Status != "" {
+		where = append(where, "status = "+arg(filter.Status))
+	}
+	if filter.EndpointID != uuid.Nil {
+		where = append(where, "endpoint_id = "+arg(filter.EndpointID))
+	}
+	if filter.AppID != 0 {
+		where = append(where, "endpoint_id IN (SELECT id FROM webhook_endpoints WHERE app_id = "+arg(filter.AppID)+")")
+	}
+	if cursor != nil {
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(cursor.CreatedAt), arg(cursor.ID)))
+	}
+
+	query := "SELECT " + webhookDeliveryColumns + " FROM webhook_deliveries"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(limit)
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to list webhook deliveries", slog.String("op", op), sl.Err(err))
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, errs.WithKind(op, errs.Internal, err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return deliveries, nil
+}
+
+// GetDelivery returns the delivery with the event it carries.
+func (r *WebhookRepository) GetDelivery(ctx context.Context, id uuid.UUID) (models.WebhookDelivery, models.OutboxEvent, error) {
+	const op = "webhookRepository.GetDelivery"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	d, err := scanWebhookDelivery(r.db.QueryRow(ctx, "SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.WebhookDelivery{}, models.OutboxEvent{}, errs.WithKind(op, errs.NotFound, err)
+		}
+		r.log.ErrorContext(ctx, "failed to get webhook delivery", slog.String("op", op), sl.Err(err))
+		return models.WebhookDelivery{}, models.OutboxEvent{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	var e models.OutboxEvent
+	err = r.db.QueryRow(ctx,
+		"SELECT id, event_type, subject, app_id, user_id, data, occurred_at, dispatched_at FROM outbox_events WHERE id = $1", d.EventID,
+	).Scan(&e.ID, &e.Type, &e.Subject, &e.AppID, &e.UserID, &e.Data, &e.OccurredAt, &e.DispatchedAt)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to get outbox event", slog.String("op", op), sl.Err(err))
+		return models.WebhookDelivery{}, models.OutboxEvent{}, errs.WithKind(op, errs.Internal, err)
+	}
+
+	return d, e, nil
+}
+
+// Requeue makes the delivery pending again with a fresh attempt budget.
+func (r *WebhookRepository) Requeue(ctx context.Context, id uuid.UUID) error {
+	const op = "webhookRepository.Requeue"
+	ctx, span := tracing.Start(ctx, op)
+	defer span.End()
+
+	tag, err := r.db.Exec(ctx,
+		`UPDATE webhook_deliveries
+		 SET status = 'pending', attempts = 0, next_attempt_at = now(), last_error = '', delivered_at = NULL
+		 WHERE id = $1`, id)
+	if err != nil {
+		r.log.ErrorContext(ctx, "failed to requeue webhook delivery", slog.String("op", op), sl.Err(err))
+		return errs.WithKind(op, errs.Internal, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errs.WithKind(op, errs.NotFound, pgx.ErrNoRows)
+	}
+
+	return nil
+}
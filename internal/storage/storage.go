@@ -2,15 +2,30 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 
+	"github.com/exaring/otelpgx"
+	"github.com/finaptica/sso/internal/lib/metrics"
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 )
 
-func New(logger *slog.Logger, postgresConnectionString string) (*pgx.Conn, error) {
-	db, err := pgx.Connect(context.Background(), postgresConnectionString)
+// SchemaVersion is the migration version this build expects the database to be at.
+const SchemaVersion = 22
+
+func New(logger *slog.Logger, postgresConnectionString string) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(postgresConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection string: %w", err)
+	}
+	poolCfg.ConnConfig.Tracer = otelpgx.NewTracer()
+
+	db, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect postgres: %w", err)
 	}
@@ -18,15 +33,58 @@ func New(logger *slog.Logger, postgresConnectionString string) (*pgx.Conn, error
 	return db, nil
 }
 
+// CheckSchemaVersion reports an error unless the migrations applied to db are
+// exactly SchemaVersion and not left dirty.
+func CheckSchemaVersion(ctx context.Context, db *pgxpool.Pool) error {
+	var version int64
+	var dirty bool
+	err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New("no migrations applied")
+		}
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+
+	if version != SchemaVersion {
+		return fmt.Errorf("schema version %d, expected %d", version, SchemaVersion)
+	}
+
+	return nil
+}
+
 type UnitOfWork struct {
-	db *pgx.Conn
+	db *pgxpool.Pool
 }
 
-func NewUnitOfWork(db *pgx.Conn) *UnitOfWork {
+func NewUnitOfWork(db *pgxpool.Pool) *UnitOfWork {
 	return &UnitOfWork{db: db}
 }
 
+// maxTxAttempts bounds how many times Do runs fn when Postgres aborts the
+// serializable transaction because of a concurrent one.
+const maxTxAttempts = 3
+
+// Do runs fn inside a serializable transaction, retrying it from scratch on
+// serialization failures and deadlocks. fn must therefore be safe to re-run.
 func (u *UnitOfWork) Do(ctx context.Context, fn func(pgx.Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = u.do(ctx, fn)
+		if !isRetryable(err) || attempt == maxTxAttempts {
+			break
+		}
+		metrics.TxRetries.Inc()
+	}
+
+	return err
+}
+
+func (u *UnitOfWork) do(ctx context.Context, fn func(pgx.Tx) error) error {
 	tx, err := u.db.BeginTx(ctx, pgx.TxOptions{
 		IsoLevel: pgx.Serializable,
 	})
@@ -42,3 +100,12 @@ func (u *UnitOfWork) Do(ctx context.Context, fn func(pgx.Tx) error) error {
 
 	return tx.Commit(ctx)
 }
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return pgErr.Code == pgerrcode.SerializationFailure || pgErr.Code == pgerrcode.DeadlockDetected
+}
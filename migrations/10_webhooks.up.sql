@@ -0,0 +1,55 @@
+-- Domain events are written here in the same transaction as the change they
+-- describe and fanned out to webhook deliveries afterwards.
+CREATE TABLE "outbox_events" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"event_type" TEXT NOT NULL,
+	"subject" TEXT NOT NULL,
+	"app_id" INTEGER,
+	"data" JSONB NOT NULL DEFAULT '{}',
+	"occurred_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"dispatched_at" TIMESTAMPTZ,
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_outbox_events_pending"
+ON "outbox_events" ("occurred_at")
+WHERE "dispatched_at" IS NULL;
+
+CREATE TABLE "webhook_endpoints" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"url" TEXT NOT NULL,
+	-- Kept in the clear: the dispatcher needs it to sign every delivery.
+	"secret" TEXT NOT NULL,
+	-- Empty means every event type.
+	"event_types" TEXT[] NOT NULL DEFAULT '{}',
+	"is_disabled" BOOLEAN NOT NULL DEFAULT FALSE,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_webhook_endpoints_app_id"
+ON "webhook_endpoints" ("app_id");
+
+CREATE TABLE "webhook_deliveries" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"event_id" UUID NOT NULL REFERENCES "outbox_events" ("id") ON DELETE CASCADE,
+	"endpoint_id" UUID NOT NULL REFERENCES "webhook_endpoints" ("id") ON DELETE CASCADE,
+	"status" TEXT NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'delivered', 'dead')),
+	"attempts" INTEGER NOT NULL DEFAULT 0,
+	"next_attempt_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"last_attempt_at" TIMESTAMPTZ,
+	"last_status_code" INTEGER,
+	"last_error" TEXT NOT NULL DEFAULT '',
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"delivered_at" TIMESTAMPTZ,
+	PRIMARY KEY("id"),
+	UNIQUE("event_id", "endpoint_id")
+);
+
+CREATE INDEX "idx_webhook_deliveries_due"
+ON "webhook_deliveries" ("next_attempt_at")
+WHERE "status" = 'pending';
+
+CREATE INDEX "idx_webhook_deliveries_endpoint_id"
+ON "webhook_deliveries" ("endpoint_id", "created_at");
@@ -0,0 +1,70 @@
+CREATE TABLE "app_permissions" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"name" TEXT NOT NULL,
+	"description" TEXT NOT NULL DEFAULT '',
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("id"),
+	UNIQUE("app_id", "name")
+);
+
+CREATE TABLE "app_roles" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"name" TEXT NOT NULL,
+	"description" TEXT NOT NULL DEFAULT '',
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"updated_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("id"),
+	UNIQUE("app_id", "name")
+);
+
+-- Roles and permissions are only linked within one app; the service checks
+-- that before inserting.
+CREATE TABLE "role_permissions" (
+	"role_id" UUID NOT NULL REFERENCES "app_roles" ("id") ON DELETE CASCADE,
+	"permission_id" UUID NOT NULL REFERENCES "app_permissions" ("id") ON DELETE CASCADE,
+	PRIMARY KEY("role_id", "permission_id")
+);
+
+CREATE INDEX "idx_role_permissions_permission_id"
+ON "role_permissions" ("permission_id");
+
+-- Groups are not tied to an app: one group can hold roles of several apps.
+CREATE TABLE "user_groups" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"name" TEXT NOT NULL UNIQUE,
+	"description" TEXT NOT NULL DEFAULT '',
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("id")
+);
+
+CREATE TABLE "group_members" (
+	"group_id" UUID NOT NULL REFERENCES "user_groups" ("id") ON DELETE CASCADE,
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("group_id", "user_id")
+);
+
+CREATE INDEX "idx_group_members_user_id"
+ON "group_members" ("user_id");
+
+CREATE TABLE "group_roles" (
+	"group_id" UUID NOT NULL REFERENCES "user_groups" ("id") ON DELETE CASCADE,
+	"role_id" UUID NOT NULL REFERENCES "app_roles" ("id") ON DELETE CASCADE,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("group_id", "role_id")
+);
+
+CREATE INDEX "idx_group_roles_role_id"
+ON "group_roles" ("role_id");
+
+CREATE TABLE "user_roles" (
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"role_id" UUID NOT NULL REFERENCES "app_roles" ("id") ON DELETE CASCADE,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("user_id", "role_id")
+);
+
+CREATE INDEX "idx_user_roles_role_id"
+ON "user_roles" ("role_id");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "relation_tuples";
+DROP TABLE IF EXISTS "relation_revisions";
+DROP TABLE IF EXISTS "relation_namespaces";
@@ -0,0 +1,43 @@
+-- Namespace configs describe the object types of an app and how their
+-- relations are computed from stored tuples.
+CREATE TABLE "relation_namespaces" (
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"name" TEXT NOT NULL,
+	"config" JSONB NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"updated_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("app_id", "name")
+);
+
+-- Every tuple write bumps the app's revision. Writers hold the row lock
+-- until commit, so revisions become visible in order.
+CREATE TABLE "relation_revisions" (
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"revision" BIGINT NOT NULL,
+	PRIMARY KEY("app_id")
+);
+
+-- Tuples are object#relation@subject, where the subject is an object or a
+-- userset (subject_relation set). Deletes only stamp deleted_revision so
+-- reads at an earlier revision still see the tuple.
+CREATE TABLE "relation_tuples" (
+	"id" BIGINT GENERATED ALWAYS AS IDENTITY,
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"namespace" TEXT NOT NULL,
+	"object_id" TEXT NOT NULL,
+	"relation" TEXT NOT NULL,
+	"subject_namespace" TEXT NOT NULL,
+	"subject_object_id" TEXT NOT NULL,
+	"subject_relation" TEXT NOT NULL DEFAULT '',
+	"created_revision" BIGINT NOT NULL,
+	"deleted_revision" BIGINT,
+	PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX "idx_relation_tuples_live"
+	ON "relation_tuples" ("app_id", "namespace", "object_id", "relation",
+		"subject_namespace", "subject_object_id", "subject_relation")
+	WHERE "deleted_revision" IS NULL;
+
+CREATE INDEX "idx_relation_tuples_object"
+	ON "relation_tuples" ("app_id", "namespace", "object_id", "relation");
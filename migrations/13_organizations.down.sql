@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "org_invitations";
+DROP TABLE IF EXISTS "org_memberships";
+DROP INDEX IF EXISTS "idx_apps_org_id";
+ALTER TABLE "apps" DROP COLUMN IF EXISTS "org_id";
+DROP TABLE IF EXISTS "organizations";
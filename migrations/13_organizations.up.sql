@@ -0,0 +1,48 @@
+CREATE TABLE "organizations" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"name" TEXT NOT NULL,
+	"slug" TEXT NOT NULL UNIQUE,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"updated_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("id")
+);
+
+-- An app owned by an organization only signs in its members. Apps must be
+-- moved out before their organization can be deleted.
+ALTER TABLE "apps"
+	ADD COLUMN "org_id" UUID REFERENCES "organizations" ("id") ON DELETE RESTRICT;
+
+CREATE INDEX "idx_apps_org_id"
+ON "apps" ("org_id");
+
+CREATE TABLE "org_memberships" (
+	"org_id" UUID NOT NULL REFERENCES "organizations" ("id") ON DELETE CASCADE,
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"role" TEXT NOT NULL CHECK ("role" IN ('owner', 'admin', 'member')),
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"updated_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("org_id", "user_id")
+);
+
+CREATE INDEX "idx_org_memberships_user_id"
+ON "org_memberships" ("user_id");
+
+CREATE TABLE "org_invitations" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"org_id" UUID NOT NULL REFERENCES "organizations" ("id") ON DELETE CASCADE,
+	"email" VARCHAR(255) NOT NULL,
+	"role" TEXT NOT NULL CHECK ("role" IN ('owner', 'admin', 'member')),
+	"token_hash" BYTEA NOT NULL,
+	"invited_by" TEXT NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"expires_at" TIMESTAMPTZ NOT NULL,
+	"accepted_at" TIMESTAMPTZ,
+	"accepted_by" UUID REFERENCES "users" ("id") ON DELETE SET NULL,
+	PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX "idx_org_invitations_token_hash"
+ON "org_invitations" ("token_hash");
+
+CREATE INDEX "idx_org_invitations_org_id"
+ON "org_invitations" ("org_id");
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "app_invitations";
+ALTER TABLE "apps"
+	DROP COLUMN IF EXISTS "registration_domains",
+	DROP COLUMN IF EXISTS "registration_policy";
@@ -0,0 +1,22 @@
+ALTER TABLE "apps"
+	ADD COLUMN "registration_policy" TEXT NOT NULL DEFAULT 'open'
+		CHECK ("registration_policy" IN ('open', 'invitation', 'domains', 'disabled')),
+	ADD COLUMN "registration_domains" TEXT[] NOT NULL DEFAULT '{}';
+
+-- The invitation token is a JWT naming the row by its ID. The row is what
+-- makes a token single-use and revocable.
+CREATE TABLE "app_invitations" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"email" VARCHAR(255),
+	"role_ids" UUID[] NOT NULL DEFAULT '{}',
+	"created_by" TEXT NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"expires_at" TIMESTAMPTZ NOT NULL,
+	"used_at" TIMESTAMPTZ,
+	"used_by" UUID REFERENCES "users" ("id") ON DELETE SET NULL,
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_app_invitations_app_id"
+ON "app_invitations" ("app_id");
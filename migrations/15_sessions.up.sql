@@ -0,0 +1,38 @@
+-- A session is one sign-in on one device. Every refresh token issued by
+-- rotation points at the session of the login it descends from, so the
+-- session keeps its identity while the token changes.
+CREATE TABLE "sessions" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"device_name" TEXT NOT NULL DEFAULT '',
+	"user_agent" TEXT NOT NULL DEFAULT '',
+	"ip" TEXT NOT NULL DEFAULT '',
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"last_used_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"expires_at" TIMESTAMPTZ NOT NULL,
+	"revoked_at" TIMESTAMPTZ,
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_sessions_user_id"
+ON "sessions" ("user_id", "last_used_at");
+
+ALTER TABLE "refresh_tokens"
+	ADD COLUMN "session_id" UUID REFERENCES "sessions" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "idx_refresh_tokens_session_id"
+ON "refresh_tokens" ("session_id");
+
+-- Live tokens each become a session of their own, reusing the token ID.
+-- Dead tokens are left without one; refresh rejects them either way.
+INSERT INTO "sessions" ("id", "user_id", "app_id", "created_at", "last_used_at", "expires_at")
+SELECT rt."id", rt."user_id", rt."app_id", rt."created_at", rt."created_at", rt."expires_at"
+FROM "refresh_tokens" rt
+JOIN "users" u ON u."id" = rt."user_id"
+JOIN "apps" a ON a."id" = rt."app_id"
+WHERE NOT rt."is_revoked" AND rt."expires_at" > now();
+
+UPDATE "refresh_tokens" rt
+SET "session_id" = rt."id"
+WHERE EXISTS (SELECT 1 FROM "sessions" s WHERE s."id" = rt."id");
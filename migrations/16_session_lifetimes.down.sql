@@ -0,0 +1,8 @@
+ALTER TABLE "sessions"
+	DROP COLUMN IF EXISTS "absolute_expires_at",
+	DROP COLUMN IF EXISTS "offline";
+ALTER TABLE "apps"
+	DROP COLUMN IF EXISTS "offline_session_lifetime_seconds",
+	DROP COLUMN IF EXISTS "offline_idle_timeout_seconds",
+	DROP COLUMN IF EXISTS "offline_access",
+	DROP COLUMN IF EXISTS "session_lifetime_seconds";
@@ -0,0 +1,22 @@
+-- refresh_token_ttl_seconds is the idle timeout of a session; these bound
+-- its total length and configure offline_access sessions. NULL falls back to
+-- the service configuration.
+ALTER TABLE "apps"
+	ADD COLUMN "session_lifetime_seconds" INTEGER CHECK ("session_lifetime_seconds" > 0),
+	ADD COLUMN "offline_access" BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN "offline_idle_timeout_seconds" INTEGER CHECK ("offline_idle_timeout_seconds" > 0),
+	ADD COLUMN "offline_session_lifetime_seconds" INTEGER CHECK ("offline_session_lifetime_seconds" > 0);
+
+ALTER TABLE "sessions"
+	ADD COLUMN "offline" BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN "absolute_expires_at" TIMESTAMPTZ;
+
+-- Sessions opened before absolute lifetimes existed get 30 days, the default
+-- of sessions.lifetime. A migration cannot read the service configuration,
+-- and no app has a lifetime of its own yet, so an operator who configured a
+-- different one gets it only for sessions opened from now on.
+UPDATE "sessions"
+SET "absolute_expires_at" = GREATEST("expires_at", "created_at" + INTERVAL '30 days');
+
+ALTER TABLE "sessions"
+	ALTER COLUMN "absolute_expires_at" SET NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE "refresh_tokens"
+	DROP COLUMN IF EXISTS "rotated_at",
+	DROP COLUMN IF EXISTS "replaced_by";
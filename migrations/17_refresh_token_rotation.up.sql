@@ -0,0 +1,5 @@
+-- A token revoked by rotation remembers its successor, so a client that
+-- presents it again within the grace period gets that successor back.
+ALTER TABLE "refresh_tokens"
+	ADD COLUMN "replaced_by" UUID REFERENCES "refresh_tokens" ("id") ON DELETE SET NULL,
+	ADD COLUMN "rotated_at" TIMESTAMPTZ;
@@ -0,0 +1,6 @@
+-- Let the janitor find dead rows without scanning whole tables.
+CREATE INDEX "idx_refresh_tokens_expires_at"
+ON "refresh_tokens" ("expires_at");
+
+CREATE INDEX "idx_sessions_expires_at"
+ON "sessions" ("expires_at");
@@ -0,0 +1,22 @@
+-- One row per execution of a background job, on whichever replica ran it.
+-- A row still running after its replica died is marked failed by the next
+-- run of the same job.
+CREATE TABLE "job_runs" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"job" TEXT NOT NULL,
+	"trigger" TEXT NOT NULL CHECK ("trigger" IN ('schedule', 'manual')),
+	"triggered_by" TEXT NOT NULL DEFAULT '',
+	"instance" TEXT NOT NULL,
+	"status" TEXT NOT NULL DEFAULT 'running'
+		CHECK ("status" IN ('running', 'succeeded', 'failed')),
+	"error" TEXT NOT NULL DEFAULT '',
+	"started_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"finished_at" TIMESTAMPTZ,
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_job_runs_job_started_at"
+ON "job_runs" ("job", "started_at" DESC);
+
+CREATE INDEX "idx_job_runs_finished_at"
+ON "job_runs" ("finished_at");
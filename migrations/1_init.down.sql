@@ -1,4 +1,4 @@
-DROP TABLE IF EXISTS apps;
 DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS apps;
 DROP INDEX IF EXISTS idx_users_email;
 DROP TABLE IF EXISTS users;
\ No newline at end of file
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS "idx_refresh_tokens_user_id";
+DROP INDEX IF EXISTS "idx_refresh_tokens_value";
+
+ALTER TABLE "sessions" DROP CONSTRAINT IF EXISTS "sessions_expires_at_check";
+
+ALTER TABLE "refresh_tokens"
+	DROP CONSTRAINT IF EXISTS "refresh_tokens_expires_at_check",
+	DROP CONSTRAINT IF EXISTS "refresh_tokens_app_id_fkey",
+	DROP CONSTRAINT IF EXISTS "refresh_tokens_user_id_fkey";
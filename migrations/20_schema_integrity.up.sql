@@ -0,0 +1,27 @@
+-- refresh_tokens predates foreign keys. Tokens left behind by users and apps
+-- deleted since can never be used again, nor can tokens or sessions that
+-- expired before they were created.
+DELETE FROM "refresh_tokens" rt
+WHERE NOT EXISTS (SELECT 1 FROM "users" u WHERE u."id" = rt."user_id")
+	OR NOT EXISTS (SELECT 1 FROM "apps" a WHERE a."id" = rt."app_id")
+	OR rt."expires_at" <= rt."created_at";
+
+DELETE FROM "sessions" WHERE "expires_at" <= "created_at";
+
+ALTER TABLE "refresh_tokens"
+	ADD CONSTRAINT "refresh_tokens_user_id_fkey"
+		FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE,
+	ADD CONSTRAINT "refresh_tokens_app_id_fkey"
+		FOREIGN KEY ("app_id") REFERENCES "apps" ("id") ON DELETE CASCADE,
+	ADD CONSTRAINT "refresh_tokens_expires_at_check" CHECK ("expires_at" > "created_at");
+
+ALTER TABLE "sessions"
+	ADD CONSTRAINT "sessions_expires_at_check" CHECK ("expires_at" > "created_at");
+
+-- Every refresh looks its token up by value; revoking and purging a user's
+-- tokens goes by user_id.
+CREATE UNIQUE INDEX "idx_refresh_tokens_value"
+ON "refresh_tokens" ("value");
+
+CREATE INDEX "idx_refresh_tokens_user_id"
+ON "refresh_tokens" ("user_id");
@@ -0,0 +1,3 @@
+-- Events not chained yet are lost with the table; run the audit_chain job
+-- before migrating down.
+DROP TABLE IF EXISTS "audit_pending";
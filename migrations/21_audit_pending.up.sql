@@ -0,0 +1,18 @@
+-- Audited operations append here without taking any lock. The audit_chain
+-- job, the chain's only writer, moves the rows into audit_events in order,
+-- hashing each onto the one before.
+CREATE TABLE "audit_pending" (
+	"id" BIGINT NOT NULL GENERATED ALWAYS AS IDENTITY,
+	"occurred_at" TIMESTAMPTZ NOT NULL,
+	"event_type" TEXT NOT NULL,
+	"actor" TEXT NOT NULL,
+	"subject_user_id" UUID,
+	"app_id" INTEGER,
+	"ip" TEXT NOT NULL DEFAULT '',
+	"user_agent" TEXT NOT NULL DEFAULT '',
+	"request_id" TEXT NOT NULL DEFAULT '',
+	"outcome" TEXT NOT NULL CHECK ("outcome" IN ('success', 'failure')),
+	"reason" TEXT NOT NULL DEFAULT '',
+	"details" JSONB NOT NULL DEFAULT '{}',
+	PRIMARY KEY("id")
+);
@@ -0,0 +1,8 @@
+-- Events about a user that no single app caused go only to the apps that
+-- user has signed in to or belongs to through an organization.
+ALTER TABLE "outbox_events"
+	ADD COLUMN "user_id" UUID;
+
+UPDATE "outbox_events"
+SET "user_id" = "subject"::uuid
+WHERE "event_type" LIKE 'sso.user.%';
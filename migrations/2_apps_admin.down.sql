@@ -0,0 +1,8 @@
+ALTER TABLE "apps"
+	DROP COLUMN IF EXISTS "updated_at",
+	DROP COLUMN IF EXISTS "created_at",
+	DROP COLUMN IF EXISTS "is_disabled",
+	DROP COLUMN IF EXISTS "refresh_token_ttl_seconds",
+	DROP COLUMN IF EXISTS "access_token_ttl_seconds",
+	DROP COLUMN IF EXISTS "allowed_grant_types",
+	DROP COLUMN IF EXISTS "redirect_uris";
\ No newline at end of file
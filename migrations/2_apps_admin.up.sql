@@ -0,0 +1,8 @@
+ALTER TABLE "apps"
+	ADD COLUMN "redirect_uris" TEXT[] NOT NULL DEFAULT '{}',
+	ADD COLUMN "allowed_grant_types" TEXT[] NOT NULL DEFAULT '{password,refresh_token}',
+	ADD COLUMN "access_token_ttl_seconds" INTEGER CHECK ("access_token_ttl_seconds" > 0),
+	ADD COLUMN "refresh_token_ttl_seconds" INTEGER CHECK ("refresh_token_ttl_seconds" > 0),
+	ADD COLUMN "is_disabled" BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN "created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN "updated_at" TIMESTAMPTZ NOT NULL DEFAULT now();
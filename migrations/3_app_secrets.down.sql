@@ -0,0 +1,6 @@
+-- Plaintext secrets cannot be recovered from their hashes; apps get an
+-- empty secret and must be issued a new one.
+ALTER TABLE "apps" ADD COLUMN "secret" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "apps" ALTER COLUMN "secret" DROP DEFAULT;
+
+DROP TABLE IF EXISTS "app_secrets";
\ No newline at end of file
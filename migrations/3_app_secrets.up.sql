@@ -0,0 +1,17 @@
+CREATE TABLE "app_secrets" (
+	"id" UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"secret_hash" TEXT NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"expires_at" TIMESTAMPTZ,
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_app_secrets_app_id"
+ON "app_secrets" ("app_id");
+
+-- pgcrypto's bcrypt output is compatible with golang.org/x/crypto/bcrypt.
+INSERT INTO "app_secrets" ("app_id", "secret_hash")
+SELECT "id", crypt("secret", gen_salt('bf', 10)) FROM "apps";
+
+ALTER TABLE "apps" DROP COLUMN "secret";
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "user_apps";
+DROP INDEX IF EXISTS "idx_users_email_prefix";
+DROP INDEX IF EXISTS "idx_users_created_at_id";
+
+ALTER TABLE "users"
+	DROP COLUMN IF EXISTS "updated_at",
+	DROP COLUMN IF EXISTS "created_at",
+	DROP COLUMN IF EXISTS "password_reset_required",
+	DROP COLUMN IF EXISTS "status";
\ No newline at end of file
@@ -0,0 +1,30 @@
+ALTER TABLE "users"
+	ADD COLUMN "status" TEXT NOT NULL DEFAULT 'active' CHECK ("status" IN ('active', 'disabled')),
+	ADD COLUMN "password_reset_required" BOOLEAN NOT NULL DEFAULT false,
+	ADD COLUMN "created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN "updated_at" TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX "idx_users_created_at_id"
+ON "users" ("created_at", "id");
+
+-- Supports the admin "email starts with" filter.
+CREATE INDEX "idx_users_email_prefix"
+ON "users" ("email" varchar_pattern_ops);
+
+CREATE TABLE "user_apps" (
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"app_id" INTEGER NOT NULL REFERENCES "apps" ("id") ON DELETE CASCADE,
+	"first_login_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"last_login_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("user_id", "app_id")
+);
+
+CREATE INDEX "idx_user_apps_app_id"
+ON "user_apps" ("app_id");
+
+INSERT INTO "user_apps" ("user_id", "app_id", "first_login_at", "last_login_at")
+SELECT rt."user_id", rt."app_id", min(rt."created_at"), max(rt."created_at")
+FROM "refresh_tokens" rt
+JOIN "users" u ON u."id" = rt."user_id"
+JOIN "apps" a ON a."id" = rt."app_id"
+GROUP BY rt."user_id", rt."app_id";
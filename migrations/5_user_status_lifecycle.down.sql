@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS "user_status_transitions";
+
+-- Statuses unknown to the previous schema fall back to the closest one that
+-- still keeps the user out.
+UPDATE "users" SET "status" = 'disabled'
+WHERE "status" IN ('pending_verification', 'locked', 'deleted');
+
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_status_check";
+
+ALTER TABLE "users"
+	ADD CONSTRAINT "users_status_check" CHECK ("status" IN ('active', 'disabled')),
+	DROP COLUMN IF EXISTS "deleted_at";
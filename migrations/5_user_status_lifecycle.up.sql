@@ -0,0 +1,20 @@
+ALTER TABLE "users" DROP CONSTRAINT IF EXISTS "users_status_check";
+
+ALTER TABLE "users"
+	ADD CONSTRAINT "users_status_check"
+	CHECK ("status" IN ('active', 'pending_verification', 'locked', 'disabled', 'deleted')),
+	ADD COLUMN "deleted_at" TIMESTAMPTZ;
+
+CREATE TABLE "user_status_transitions" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"from_status" TEXT NOT NULL,
+	"to_status" TEXT NOT NULL,
+	"actor" TEXT NOT NULL,
+	"reason" TEXT NOT NULL DEFAULT '',
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY("id")
+);
+
+CREATE INDEX "idx_user_status_transitions_user_id_created_at"
+ON "user_status_transitions" ("user_id", "created_at");
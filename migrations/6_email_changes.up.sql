@@ -0,0 +1,16 @@
+CREATE TABLE "email_changes" (
+	"id" UUID NOT NULL DEFAULT gen_random_uuid(),
+	"user_id" UUID NOT NULL REFERENCES "users" ("id") ON DELETE CASCADE,
+	"new_email" VARCHAR(255) NOT NULL,
+	"token_hash" BYTEA NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"expires_at" TIMESTAMPTZ NOT NULL,
+	"confirmed_at" TIMESTAMPTZ,
+	PRIMARY KEY("id")
+);
+
+CREATE UNIQUE INDEX "idx_email_changes_token_hash"
+ON "email_changes" ("token_hash");
+
+CREATE INDEX "idx_email_changes_user_id"
+ON "email_changes" ("user_id");
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS "idx_users_email_lower_prefix";
+DROP INDEX IF EXISTS "idx_users_email_lower";
+
+CREATE UNIQUE INDEX "idx_users_email"
+ON "users" ("email");
+
+CREATE INDEX "idx_users_email_prefix"
+ON "users" ("email" varchar_pattern_ops);
@@ -0,0 +1,48 @@
+-- Emails are compared case-insensitively from now on. Accounts whose
+-- addresses only differ by case, surrounding whitespace or Unicode
+-- normalization would violate the new unique index, so report them and stop
+-- instead of letting CREATE UNIQUE INDEX fail with a bare constraint error.
+-- Resolve each group by hand (merge, rename or delete) and run again.
+DO $$
+DECLARE
+	report TEXT;
+	groups INTEGER;
+BEGIN
+	SELECT count(*), string_agg(format('%s: %s', normalized, emails), E'\n')
+	INTO groups, report
+	FROM (
+		SELECT lower(normalize(btrim("email"), NFC)) AS normalized,
+			string_agg(format('%s (%s)', "email", "id"), ', ' ORDER BY "email") AS emails
+		FROM "users"
+		GROUP BY 1
+		HAVING count(*) > 1
+	) collisions;
+
+	IF groups > 0 THEN
+		RAISE EXCEPTION '% email collision group(s) must be resolved before migrating', groups
+			USING DETAIL = report,
+			HINT = 'Each line lists accounts that would share one normalized address.';
+	END IF;
+END
+$$;
+
+-- Bring stored addresses in line with what the service writes: trimmed, NFC
+-- and with a lowercase domain.
+UPDATE "users"
+SET "email" = n."email"
+FROM (
+	SELECT "id", substring(e from '^(.*)@[^@]*$') || '@' || lower(substring(e from '@([^@]*)$')) AS "email"
+	FROM (SELECT "id", normalize(btrim("email"), NFC) AS e FROM "users") t
+	WHERE position('@' in e) > 0
+) n
+WHERE "users"."id" = n."id" AND "users"."email" <> n."email";
+
+DROP INDEX IF EXISTS "idx_users_email";
+DROP INDEX IF EXISTS "idx_users_email_prefix";
+
+CREATE UNIQUE INDEX "idx_users_email_lower"
+ON "users" (lower("email"));
+
+-- Supports the admin "email starts with" filter, which is case-insensitive.
+CREATE INDEX "idx_users_email_lower_prefix"
+ON "users" (lower("email") text_pattern_ops);
@@ -0,0 +1,36 @@
+CREATE TABLE "audit_events" (
+	"id" BIGINT NOT NULL GENERATED ALWAYS AS IDENTITY,
+	"occurred_at" TIMESTAMPTZ NOT NULL DEFAULT now(),
+	"event_type" TEXT NOT NULL,
+	"actor" TEXT NOT NULL,
+	"subject_user_id" UUID,
+	"app_id" INTEGER,
+	"ip" TEXT NOT NULL DEFAULT '',
+	"user_agent" TEXT NOT NULL DEFAULT '',
+	"request_id" TEXT NOT NULL DEFAULT '',
+	"outcome" TEXT NOT NULL CHECK ("outcome" IN ('success', 'failure')),
+	"reason" TEXT NOT NULL DEFAULT '',
+	"details" JSONB NOT NULL DEFAULT '{}',
+	PRIMARY KEY("id")
+);
+
+-- Subjects and apps are not foreign keys on purpose: the trail has to outlive
+-- the rows it talks about.
+CREATE INDEX "idx_audit_events_occurred_at"
+ON "audit_events" ("occurred_at");
+
+CREATE INDEX "idx_audit_events_subject_user_id"
+ON "audit_events" ("subject_user_id", "id");
+
+CREATE INDEX "idx_audit_events_event_type"
+ON "audit_events" ("event_type", "id");
+
+CREATE FUNCTION "audit_events_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_events is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_events_append_only"
+BEFORE UPDATE OR DELETE OR TRUNCATE ON "audit_events"
+FOR EACH STATEMENT EXECUTE FUNCTION "audit_events_append_only"();
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "audit_checkpoints";
+DROP FUNCTION IF EXISTS "audit_checkpoints_append_only"();
+DROP INDEX IF EXISTS "idx_audit_events_prev_hash";
+ALTER TABLE "audit_events"
+	DROP COLUMN IF EXISTS "hash",
+	DROP COLUMN IF EXISTS "prev_hash";
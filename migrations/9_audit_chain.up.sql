@@ -0,0 +1,30 @@
+-- Rows written before this migration keep NULL hashes; the chain starts at
+-- the first row inserted afterwards, whose prev_hash is all zeroes.
+ALTER TABLE "audit_events"
+	ADD COLUMN "prev_hash" BYTEA,
+	ADD COLUMN "hash" BYTEA;
+
+-- Two rows claiming the same predecessor would fork the chain.
+CREATE UNIQUE INDEX "idx_audit_events_prev_hash"
+ON "audit_events" ("prev_hash");
+
+CREATE TABLE "audit_checkpoints" (
+	"id" BIGINT NOT NULL GENERATED ALWAYS AS IDENTITY,
+	"event_id" BIGINT NOT NULL,
+	"event_hash" BYTEA NOT NULL,
+	"created_at" TIMESTAMPTZ NOT NULL,
+	"key_id" TEXT NOT NULL,
+	"signature" BYTEA NOT NULL,
+	PRIMARY KEY("id"),
+	UNIQUE("event_id")
+);
+
+CREATE FUNCTION "audit_checkpoints_append_only"() RETURNS TRIGGER AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_checkpoints is append-only';
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_checkpoints_append_only"
+BEFORE UPDATE OR DELETE OR TRUNCATE ON "audit_checkpoints"
+FOR EACH STATEMENT EXECUTE FUNCTION "audit_checkpoints_append_only"();
@@ -0,0 +1,127 @@
+package migrations
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/lib/pq"
+)
+
+// dsnEnv names a Postgres URL for a user allowed to create databases. The
+// test runs against a scratch database created next to it.
+const dsnEnv = "SSO_MIGRATIONS_TEST_DSN"
+
+// leftoverObjectsQuery lists what the migrations created in the public
+// schema, less the migration bookkeeping and the functions extensions own.
+const leftoverObjectsQuery = `
+SELECT c.relname FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = 'public' AND c.relname NOT LIKE 'schema_migrations%'
+UNION ALL
+SELECT p.proname || '()' FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+WHERE n.nspname = 'public'
+	AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e')
+ORDER BY 1`
+
+// TestUpDownUp applies every migration up, down and up again. Down must leave
+// no table, index or function behind.
+func TestUpDownUp(t *testing.T) {
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnEnv)
+	}
+
+	db := scratchDatabase(t, dsn)
+
+	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	if err != nil {
+		t.Fatalf("failed to get postgres driver with instance: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://.", "postgres", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+
+	if err := m.Down(); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if leftovers := leftoverObjects(t, db); len(leftovers) > 0 {
+		t.Fatalf("down: left behind %s", strings.Join(leftovers, ", "))
+	}
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
+
+// scratchDatabase creates an empty database next to the one dsn points at,
+// drops it when the test ends and returns a connection to it.
+func scratchDatabase(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", dsnEnv, err)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open connection with db: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := fmt.Sprintf("sso_migrations_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE DATABASE "` + name + `"`); err != nil {
+		t.Fatalf("failed to create scratch database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(`DROP DATABASE IF EXISTS "` + name + `" WITH (FORCE)`); err != nil {
+			t.Errorf("failed to drop scratch database %s: %v", name, err)
+		}
+	})
+
+	u.Path = "/" + name
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("failed to open scratch database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func leftoverObjects(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(leftoverObjectsQuery)
+	if err != nil {
+		t.Fatalf("failed to list leftover objects: %v", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("failed to list leftover objects: %v", err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to list leftover objects: %v", err)
+	}
+	return names
+}